@@ -0,0 +1,604 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jacksongodsey/SFILS/shared/bench"
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// benchmark to test performance. every query gets warmup runs first and is
+// then timed over several runs, one run on its own mostly shows what was cached.
+func runBenchmark(db *sql.DB, suite *bench.Suite, opts bench.Options) []bench.Result {
+	fmt.Printf("\n=== performance test: %s (%d warmup, %d measured runs per query) ===\n", suite.Name, opts.Warmup, opts.Runs)
+	if opts.Flush {
+		fmt.Println("running FLUSH TABLES before every run. the innodb buffer pool stays warm, only a restart empties it")
+	}
+
+	flush := func() error {
+		_, err := db.Exec("FLUSH TABLES")
+		return err
+	}
+	results := suite.Run(opts, flush, prepareQuery(db))
+	if err := bench.WriteTable(os.Stdout, results); err != nil {
+		fmt.Println("error writing results:", err)
+	}
+
+	fmt.Println("\nbenchmark done")
+	return results
+}
+
+// turns a suite query into a function that runs it
+func prepareQuery(db *sql.DB) bench.Prepare {
+	return func(q bench.SuiteQuery) (func() (int, error), error) {
+		query, args, err := suiteSQL(q)
+		if query == "" || err != nil {
+			return nil, err
+		}
+		return func() (int, error) {
+			return countRows(db, query, args...)
+		}, nil
+	}
+}
+
+// the sql a suite query runs on mysql, the suite's own when it has some and
+// the compiled patron query otherwise. "" when it has neither.
+func suiteSQL(q bench.SuiteQuery) (string, []interface{}, error) {
+	query := q.MySQL
+	var args []interface{}
+	if query == "" && q.Query != "" {
+		parsed, err := dsl.Parse(q.Query)
+		if err != nil {
+			return "", nil, err
+		}
+		query, args = parsed.SQL()
+	}
+	if query != "" && !isReadStatement(query) {
+		return "", nil, fmt.Errorf("only queries that read can be benchmarked")
+	}
+	return query, args, nil
+}
+
+// runs a query and counts the rows it sends back
+func countRows(db *sql.DB, query string, args ...interface{}) (int, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	return count, rows.Err()
+}
+
+// runs every query that worked once more and keeps a fingerprint of what it
+// returned, so bench report can tell whether mongodb got the same answers
+func checkResults(db *sql.DB, suite *bench.Suite, results []bench.Result) {
+	for i, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		for _, q := range suite.Queries {
+			if q.Name != r.Name {
+				continue
+			}
+			query, args, _ := suiteSQL(q)
+			rows, err := queryValues(context.Background(), db, query, args...)
+			if err != nil {
+				fmt.Printf("couldn't check the result of %s: %v\n", r.Name, err)
+				continue
+			}
+			results[i].Checksum = bench.Fingerprint(rows)
+		}
+	}
+}
+
+// runs a query and keeps every value it sends back
+func queryValues(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([][]output.Value, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := resultColumns(rows)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(cols))
+	valuePtrs := make([]interface{}, len(cols))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	var result [][]output.Value
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+		row := make([]output.Value, len(cols))
+		for i, v := range values {
+			row[i] = toValue(v, cols[i])
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// the bench command: runs the benchmark without importing the data again and
+// saves the results to compare with later runs using bench compare
+//
+//	go run . bench -runs 50 -warmup 5
+func runBenchCommand(db *sql.DB, args []string) error {
+	if len(args) > 0 && args[0] == "load" {
+		return runLoadCommand(db, args[1:])
+	}
+	if len(args) > 0 && args[0] == "import" {
+		return runImportBenchCommand(db, args[1:])
+	}
+	if len(args) > 0 && args[0] == "indexes" {
+		return runIndexCommand(db, args[1:])
+	}
+
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	warmup := flags.Int("warmup", bench.DefaultOptions.Warmup, "runs of each query before timing starts")
+	runs := flags.Int("runs", bench.DefaultOptions.Runs, "timed runs of each query")
+	flush := flags.Bool("flush", false, "run FLUSH TABLES before every timed run, needs the RELOAD privilege")
+	save := flags.Bool("save", true, "save the results as json and csv")
+	dir := flags.String("out", "../results", "folder the results are saved in")
+	reimport := flags.Bool("import", false, "create the tables and import the workbook again first")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . bench [flags]")
+		fmt.Fprintln(flags.Output(), "       go run . bench load [-clients N] [-duration D | -requests N] [-mix NAME=WEIGHT,...]")
+		fmt.Fprintln(flags.Output(), "       go run . bench import [-strategies LIST] [-batch N] [-workers N] [-cpuprofile FILE] [-memprofile FILE]")
+		fmt.Fprintln(flags.Output(), "       go run . bench indexes [-runs N] [-warmup N] [-min-speedup X]")
+		fmt.Fprintln(flags.Output(), "       go run . bench compare [-threshold PERCENT] OLD.json NEW.json")
+		fmt.Fprintln(flags.Output(), "       go run . bench report [-format markdown|html] [-out FILE] RUN.json|FOLDER ...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *runs < 1 || *warmup < 0 {
+		return fmt.Errorf("-runs has to be at least 1 and -warmup can't be negative")
+	}
+
+	if *reimport {
+		if err := runScripts(db, "../scripts"); err != nil {
+			return err
+		}
+		if err := importExcel(db, workbookFile); err != nil {
+			return err
+		}
+	}
+
+	suite, err := bench.LoadSuite(*suiteFile)
+	if err != nil {
+		return err
+	}
+	opts := bench.Options{Warmup: *warmup, Runs: *runs, Flush: *flush}
+	results := runBenchmark(db, suite, opts)
+	failed := bench.Failed(results)
+
+	if *save {
+		checkResults(db, suite, results)
+		if err := saveBenchmark(db, suite, opts, results, *dir); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of the %s benchmark queries failed", failed, suite.Name)
+	}
+	return nil
+}
+
+// tags the results with the mysql version and the workbook and saves them
+func saveBenchmark(db *sql.DB, suite *bench.Suite, opts bench.Options, results []bench.Result, dir string) error {
+	var version string
+	err := db.QueryRow("SELECT VERSION()").Scan(&version)
+	if err != nil {
+		fmt.Println("couldn't get the mysql version:", err)
+	}
+	run := bench.NewRun("mysql", suite.Name, bench.Checksum(workbookFile), "mysql "+version, opts, results)
+	run.Storage, err = storageSizes(db)
+	if err != nil {
+		fmt.Println("couldn't get the table sizes:", err)
+	}
+	files, err := run.Save(dir)
+	if err != nil {
+		return fmt.Errorf("couldn't save the results: %v", err)
+	}
+	fmt.Println("results saved to", strings.Join(files, " and "))
+	return nil
+}
+
+// bench load: runs the suite's queries from several clients at once, picked
+// at random by their weights, to see how the server and the connection pool
+// hold up under something like a dashboard full of users
+//
+//	go run . bench load -clients 50 -duration 30s -mix "count by library=5,find SF patrons=1"
+func runLoadCommand(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("bench load", flag.ExitOnError)
+	clients := flags.Int("clients", 10, "queries running at the same time")
+	duration := flags.Duration("duration", 10*time.Second, "how long to run for, 0 to go by -requests")
+	requests := flags.Int("requests", 0, "stop after this many queries instead of after -duration")
+	mix := flags.String("mix", "", "weights like NAME=WEIGHT,NAME=WEIGHT instead of the ones in the suite")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . bench load [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *requests > 0 {
+		*duration = 0
+	}
+
+	suite, err := bench.LoadSuite(*suiteFile)
+	if err != nil {
+		return err
+	}
+	queries, err := suite.LoadQueries(prepareQuery(db))
+	if err != nil {
+		return err
+	}
+	if *mix != "" {
+		if err := bench.ParseMix(*mix, queries); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\n=== load test: %s, %d clients, pool of %d connections ===\n", suite.Name, *clients, db.Stats().MaxOpenConnections)
+	before := db.Stats()
+	res, err := bench.RunLoad(queries, bench.LoadOptions{Clients: *clients, Duration: *duration, Requests: *requests})
+	if err != nil {
+		return err
+	}
+	after := db.Stats()
+	if err := bench.WriteLoad(os.Stdout, res); err != nil {
+		return err
+	}
+	printPoolStats(before, after, res.Requests)
+
+	if res.Errors > 0 {
+		return fmt.Errorf("%d of the %d queries failed", res.Errors, res.Requests)
+	}
+	return nil
+}
+
+// what the connection pool did during the load test. waits are queries that
+// found every connection busy, lots of them means the pool or the server is
+// too small for that many clients.
+func printPoolStats(before, after sql.DBStats, requests int) {
+	waits := after.WaitCount - before.WaitCount
+	waited := after.WaitDuration - before.WaitDuration
+	fmt.Println("\nconnection pool:")
+	fmt.Printf("  open %d (max %d), in use %d, idle %d\n", after.OpenConnections, after.MaxOpenConnections, after.InUse, after.Idle)
+	fmt.Printf("  %d of %d queries waited for a connection, %v in total", waits, requests, waited.Round(time.Millisecond))
+	if waits > 0 {
+		fmt.Printf(", %s ms on average", bench.Millis(waited/time.Duration(waits)))
+	}
+	fmt.Println()
+	fmt.Printf("  closed %d idle and %d expired connections\n",
+		(after.MaxIdleClosed+after.MaxIdleTimeClosed)-(before.MaxIdleClosed+before.MaxIdleTimeClosed),
+		after.MaxLifetimeClosed-before.MaxLifetimeClosed)
+}
+
+// bench import: imports the workbook once per write strategy and times each
+// step on its own. the tables are created again before every strategy, so
+// this replaces whatever is in the database, and the last strategy's
+// import is what's left afterwards.
+//
+//	go run . bench import -strategies batched,concurrent -batch 2000 -workers 8
+func runImportBenchCommand(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("bench import", flag.ExitOnError)
+	list := flags.String("strategies", "all", "comma separated write strategies to compare: single, batched, concurrent or all")
+	batch := flags.Int("batch", 1000, "rows per insert for batched and concurrent")
+	workers := flags.Int("workers", 4, "inserts running at once for concurrent")
+	cpuProfile := flags.String("cpuprofile", "", "write a cpu profile of the whole run to this file")
+	memProfile := flags.String("memprofile", "", "write a heap profile to this file at the end")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . bench import [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	strategies, err := bench.ParseStrategies(*list)
+	if err != nil {
+		return err
+	}
+	// mysql allows 65535 placeholders in a statement and a row takes 11
+	if *batch < 1 || *batch > 5000 || *workers < 1 {
+		return fmt.Errorf("-batch has to be between 1 and 5000 and -workers at least 1")
+	}
+
+	stop, err := bench.StartProfiles(*cpuProfile, *memProfile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n=== import benchmark: %s ===\n", strings.Join(strategies, ", "))
+	var rows [][]string
+	parse, err := bench.TimePhase("parse", func() (int, error) {
+		var err error
+		rows, err = readWorkbook(workbookFile)
+		return max(len(rows)-1, 0), err
+	})
+	if err != nil {
+		// the cpu profile would keep going and its file stay open otherwise
+		stop()
+		return err
+	}
+	var patrons []patronRow
+	clean, _ := bench.TimePhase("clean", func() (int, error) {
+		patrons, _ = cleanRows(rows)
+		return len(patrons), nil
+	})
+	rows = nil
+
+	var results []bench.ImportResult
+	for _, name := range strategies {
+		fmt.Println("importing with", name)
+		results = append(results, importWith(db, patrons, writeStrategy{name: name, batch: *batch, workers: *workers}))
+	}
+
+	if err := stop(); err != nil {
+		return err
+	}
+	if *cpuProfile != "" || *memProfile != "" {
+		fmt.Println("profiles written, open them with go tool pprof")
+	}
+	fmt.Println()
+	if err := bench.WriteImport(os.Stdout, []bench.Phase{parse, clean}, results); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if r.Err != nil {
+			return fmt.Errorf("the %s import failed", r.Strategy)
+		}
+	}
+	return nil
+}
+
+// recreates the tables and runs the lookups and the writes with one strategy
+func importWith(db *sql.DB, patrons []patronRow, strategy writeStrategy) bench.ImportResult {
+	res := bench.ImportResult{Strategy: strategy.name}
+	if res.Err = runScripts(db, "../scripts"); res.Err != nil {
+		return res
+	}
+
+	tx, err := db.Begin()
+	if res.Err = err; err != nil {
+		return res
+	}
+	defer tx.Rollback()
+
+	lookups, err := bench.TimePhase("lookups", func() (int, error) {
+		var err error
+		patrons, _, err = lookupDimensions(tx, patrons)
+		return len(patrons), err
+	})
+	res.Phases = append(res.Phases, lookups)
+	if res.Err = err; err != nil {
+		return res
+	}
+
+	var bad int
+	writes, err := bench.TimePhase("writes", func() (int, error) {
+		good, failed, err := writePatrons(db, tx, patrons, strategy)
+		bad = failed
+		return good, err
+	})
+	res.Phases = append(res.Phases, writes)
+	if err == nil && bad > 0 {
+		err = fmt.Errorf("%d rows weren't written", bad)
+	}
+	res.Err = err
+	return res
+}
+
+// how much space every table and index takes. innodb only updates its
+// statistics now and then, so the tables are analyzed first and the cached
+// numbers in information_schema are skipped.
+func storageSizes(db *sql.DB) ([]bench.Table, error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// older servers don't have the setting and always show fresh numbers
+	conn.ExecContext(ctx, "SET SESSION information_schema_stats_expiry = 0")
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE'
+		ORDER BY table_name`)
+	if err != nil {
+		return nil, err
+	}
+	var tables []bench.Table
+	for rows.Next() {
+		var t bench.Table
+		if err := rows.Scan(&t.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range tables {
+		t := &tables[i]
+		if _, err := conn.ExecContext(ctx, "ANALYZE TABLE "+t.Name); err != nil {
+			return nil, err
+		}
+		if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+t.Name).Scan(&t.Rows); err != nil {
+			return nil, err
+		}
+		err := conn.QueryRowContext(ctx, `
+			SELECT data_length, index_length FROM information_schema.tables
+			WHERE table_schema = DATABASE() AND table_name = ?`, t.Name).Scan(&t.DataBytes, &t.IndexBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the size of each index in pages, reading it needs access to the mysql schema
+	rows, err = conn.QueryContext(ctx, `
+		SELECT table_name, index_name, stat_value * @@innodb_page_size
+		FROM mysql.innodb_index_stats
+		WHERE database_name = DATABASE() AND stat_name = 'size'
+		ORDER BY table_name, index_name`)
+	if err != nil {
+		fmt.Println("couldn't get the index sizes:", err)
+		return tables, nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table string
+		var index bench.Index
+		if err := rows.Scan(&table, &index.Name, &index.Bytes); err != nil {
+			return nil, err
+		}
+		for i := range tables {
+			if tables[i].Name == table {
+				tables[i].Indexes = append(tables[i].Indexes, index)
+			}
+		}
+	}
+	return tables, rows.Err()
+}
+
+// bench indexes: runs the suite without the candidate indexes in the suite
+// file and then with each of them built on its own, and says which ones are
+// worth the space. the schema only has the primary and foreign keys, so
+// that's what the suite runs against first. the candidates are dropped again
+// afterwards.
+//
+//	go run . bench indexes -runs 20 -min-speedup 2
+func runIndexCommand(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("bench indexes", flag.ExitOnError)
+	warmup := flags.Int("warmup", bench.DefaultOptions.Warmup, "runs of each query before timing starts")
+	runs := flags.Int("runs", bench.DefaultOptions.Runs, "timed runs of each query")
+	minSpeedup := flags.Float64("min-speedup", 1.5, "how many times faster a query has to get for an index to be worth keeping")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . bench indexes [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *runs < 1 || *warmup < 0 || *minSpeedup <= 1 {
+		return fmt.Errorf("-runs has to be at least 1, -warmup can't be negative and -min-speedup has to be more than 1")
+	}
+
+	suite, err := bench.LoadSuite(*suiteFile)
+	if err != nil {
+		return err
+	}
+	if len(suite.Indexes) == 0 {
+		return fmt.Errorf("%s has no indexes to try", *suiteFile)
+	}
+	// a run that was stopped halfway leaves its index behind
+	if err := dropBenchIndexes(db); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n=== index benchmark: %s, %d candidates ===\n", suite.Name, len(suite.Indexes))
+	backend := bench.IndexBackend{
+		Name: "mysql",
+		Create: func(c bench.IndexCandidate, fields []string) (int64, error) {
+			fmt.Printf("building %s on %s\n", c.Name, strings.Join(fields, ", "))
+			return createBenchIndex(db, c.Name, fields)
+		},
+		Drop: func(c bench.IndexCandidate) error {
+			return dropBenchIndex(db, c.Name, c.For("mysql"))
+		},
+	}
+	opts := bench.Options{Warmup: *warmup, Runs: *runs}
+	base, results, err := suite.RunIndexes(backend, opts, nil, prepareQuery(db))
+	fmt.Println()
+	if werr := bench.WriteIndexes(os.Stdout, suite, base, results, *minSpeedup); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// the candidates get a prefix so they can't clash with the schema's own
+// indexes and leftovers can be found again
+const benchIndexPrefix = "bench_"
+
+// builds a candidate on patrons and returns its size, -1 when the index
+// statistics can't be read
+func createBenchIndex(db *sql.DB, name string, fields []string) (int64, error) {
+	name = benchIndexPrefix + name
+	if _, err := db.Exec("CREATE INDEX " + name + " ON patrons (" + strings.Join(fields, ", ") + ")"); err != nil {
+		return 0, err
+	}
+	// innodb only works the size out when the table is analyzed
+	if _, err := db.Exec("ANALYZE TABLE patrons"); err != nil {
+		return 0, err
+	}
+	var bytes int64
+	err := db.QueryRow(`
+		SELECT stat_value * @@innodb_page_size FROM mysql.innodb_index_stats
+		WHERE database_name = DATABASE() AND table_name = 'patrons'
+		AND index_name = ? AND stat_name = 'size'`, name).Scan(&bytes)
+	if err != nil {
+		fmt.Println("couldn't get the index size:", err)
+		return -1, nil
+	}
+	return bytes, nil
+}
+
+// drops a candidate again. when it starts with a foreign key column mysql
+// dropped the foreign key's own index for it and won't let it go until the
+// column has another index, so that one gets added back in the same statement.
+func dropBenchIndex(db *sql.DB, name string, fields []string) error {
+	name = benchIndexPrefix + name
+	_, err := db.Exec("ALTER TABLE patrons DROP INDEX " + name)
+	if err == nil || !strings.Contains(err.Error(), "foreign key") {
+		return err
+	}
+	_, err = db.Exec("ALTER TABLE patrons ADD INDEX (" + fields[0] + "), DROP INDEX " + name)
+	return err
+}
+
+// drops every candidate index a run before left on patrons
+func dropBenchIndexes(db *sql.DB) error {
+	rows, err := db.Query(`
+		SELECT DISTINCT index_name, column_name FROM information_schema.statistics
+		WHERE table_schema = DATABASE() AND table_name = 'patrons'
+		AND index_name LIKE 'bench\\_%' AND seq_in_index = 1`)
+	if err != nil {
+		return err
+	}
+	leftovers := map[string]string{}
+	for rows.Next() {
+		var index, column string
+		if err := rows.Scan(&index, &column); err != nil {
+			rows.Close()
+			return err
+		}
+		leftovers[strings.TrimPrefix(index, benchIndexPrefix)] = column
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for name, column := range leftovers {
+		fmt.Println("dropping index", benchIndexPrefix+name, "left from an earlier run")
+		if err := dropBenchIndex(db, name, []string{column}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
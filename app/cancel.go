@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// grabs a connection for a query and remembers its id so the query can be
+// killed on the server if it gets cancelled. the driver only drops the
+// connection when the context ends, mysql would keep running the query.
+func (s *session) pinConnection(ctx context.Context) (*sql.Conn, func(), error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var id int64
+	if err := conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&id); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			// KILL doesn't take placeholders but the id came from the server as a number
+			if _, err := s.db.Exec(fmt.Sprintf("KILL QUERY %d", id)); err != nil {
+				fmt.Println("couldn't stop the query on the server:", err)
+			}
+		case <-done:
+		}
+	}()
+
+	release := func() {
+		close(done)
+		conn.Close()
+	}
+	return conn, release, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+)
+
+// runs a query in the patron query language by compiling it to sql, so it
+// goes through the same read-only transaction as anything typed in
+func (s *session) runDSL(text string) error {
+	q, err := dsl.Parse(text)
+	if err != nil {
+		return err
+	}
+	query, args := q.SQL()
+	return s.runQuery(query, args...)
+}
+
+// :compile QUERY prints the sql a patron query turns into
+func (s *session) showCompiled(text string) {
+	q, err := dsl.Parse(text)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	query, args := q.SQL()
+	fmt.Println(query + ";")
+	if len(args) > 0 {
+		values := make([]string, len(args))
+		for i, a := range args {
+			values[i] = fmt.Sprintf("%v", a)
+		}
+		fmt.Println("-- args:", strings.Join(values, ", "))
+	}
+	fmt.Println()
+}
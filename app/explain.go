@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jacksongodsey/SFILS/shared/interrupt"
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// statements mysql can explain
+var explainableStatements = map[string]bool{
+	"SELECT":  true,
+	"TABLE":   true,
+	"DELETE":  true,
+	"INSERT":  true,
+	"REPLACE": true,
+	"UPDATE":  true,
+}
+
+// \explain query - runs EXPLAIN FORMAT=JSON and prints the plan as a tree.
+// EXPLAIN never runs the statement so this is fine in read-only mode.
+func (s *session) explain(query string) {
+	if !explainableStatements[statementKind(query)] {
+		fmt.Println("only SELECT, TABLE, DELETE, INSERT, REPLACE and UPDATE can be explained")
+		return
+	}
+
+	ctx, done := s.running.Start(s.timeout)
+	defer done()
+	conn, release, err := s.pinConnection(ctx)
+	if err != nil {
+		fmt.Println(interrupt.Error(ctx, err))
+		return
+	}
+	defer release()
+
+	var plan string
+	if err := conn.QueryRowContext(ctx, "EXPLAIN FORMAT=JSON "+query).Scan(&plan); err != nil {
+		fmt.Println(interrupt.Error(ctx, err))
+		return
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(plan), &doc); err != nil {
+		fmt.Println("couldn't read the plan:", err)
+		return
+	}
+
+	root := &output.PlanNode{Label: "query plan"}
+	root.Children = planNodes(doc)
+	if len(root.Children) == 1 {
+		root = root.Children[0]
+	}
+
+	warnings := output.WriteTree(s.display.Out(), root)
+	fmt.Printf("\n%d warnings\n\n", warnings)
+}
+
+// turns a piece of the json plan into tree nodes. mysql nests the operations
+// (grouping, ordering, joins) inside each other with the tables at the bottom,
+// so we look for the keys we know about and walk into them.
+func planNodes(doc map[string]interface{}) []*output.PlanNode {
+	var nodes []*output.PlanNode
+
+	if block, ok := doc["query_block"].(map[string]interface{}); ok {
+		label := "query block"
+		if id, ok := block["select_id"].(float64); ok {
+			label = fmt.Sprintf("query block #%d", int(id))
+		}
+		n := &output.PlanNode{Label: label}
+		if cost := costOf(block, "query_cost"); cost != "" {
+			n.Details = append(n.Details, "cost "+cost)
+		}
+		if msg, ok := block["message"].(string); ok {
+			n.Details = append(n.Details, msg)
+		}
+		n.Children = planNodes(block)
+		nodes = append(nodes, n)
+	}
+
+	operations := []struct {
+		key   string
+		label string
+	}{
+		{"grouping_operation", "group"},
+		{"ordering_operation", "order"},
+		{"duplicates_removal", "remove duplicates"},
+		{"windowing", "window functions"},
+	}
+	for _, op := range operations {
+		sub, ok := doc[op.key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		n := &output.PlanNode{Label: op.label}
+		if b, _ := sub["using_temporary_table"].(bool); b {
+			n.Details = append(n.Details, "uses a temporary table")
+		}
+		if b, _ := sub["using_filesort"].(bool); b {
+			n.Warnings = append(n.Warnings, "sorts the rows itself (filesort), no index gives this order")
+		}
+		n.Children = planNodes(sub)
+		nodes = append(nodes, n)
+	}
+
+	if loop, ok := doc["nested_loop"].([]interface{}); ok {
+		n := &output.PlanNode{Label: "nested loop join"}
+		for _, item := range loop {
+			if m, ok := item.(map[string]interface{}); ok {
+				n.Children = append(n.Children, planNodes(m)...)
+			}
+		}
+		nodes = append(nodes, n)
+	}
+
+	if table, ok := doc["table"].(map[string]interface{}); ok {
+		nodes = append(nodes, tableNode(table))
+	}
+
+	if union, ok := doc["union_result"].(map[string]interface{}); ok {
+		n := &output.PlanNode{Label: "union"}
+		if specs, ok := union["query_specifications"].([]interface{}); ok {
+			for _, spec := range specs {
+				if m, ok := spec.(map[string]interface{}); ok {
+					n.Children = append(n.Children, planNodes(m)...)
+				}
+			}
+		}
+		nodes = append(nodes, n)
+	}
+
+	for _, key := range []string{"attached_subqueries", "optimized_away_subqueries"} {
+		if subs, ok := doc[key].([]interface{}); ok {
+			for _, sub := range subs {
+				if m, ok := sub.(map[string]interface{}); ok {
+					for _, child := range planNodes(m) {
+						child.Label = "subquery: " + child.Label
+						nodes = append(nodes, child)
+					}
+				}
+			}
+		}
+	}
+
+	return nodes
+}
+
+// a single table access, this is where full scans and unused indexes show up
+func tableNode(table map[string]interface{}) *output.PlanNode {
+	name, _ := table["table_name"].(string)
+	access, _ := table["access_type"].(string)
+
+	n := &output.PlanNode{Label: fmt.Sprintf("table %s (%s)", name, access)}
+	if key, ok := table["key"].(string); ok {
+		n.Details = append(n.Details, "using index "+key)
+	}
+	if rows, ok := table["rows_examined_per_scan"].(float64); ok {
+		n.Details = append(n.Details, fmt.Sprintf("~%.0f rows examined per scan", rows))
+	}
+	if filtered, ok := table["filtered"].(string); ok && filtered != "100.00" {
+		n.Details = append(n.Details, filtered+"% of rows kept by the where clause")
+	}
+	if cond, ok := table["attached_condition"].(string); ok {
+		n.Details = append(n.Details, "where "+cond)
+	}
+
+	switch access {
+	case "ALL":
+		n.Warnings = append(n.Warnings, "full table scan")
+	case "index":
+		n.Warnings = append(n.Warnings, "full index scan")
+	}
+
+	// indexes mysql looked at but decided not to use
+	var possible []string
+	if keys, ok := table["possible_keys"].([]interface{}); ok {
+		for _, k := range keys {
+			if s, ok := k.(string); ok && s != table["key"] {
+				possible = append(possible, s)
+			}
+		}
+	}
+	if len(possible) > 0 {
+		sort.Strings(possible)
+		n.Warnings = append(n.Warnings, "possible indexes not used: "+strings.Join(possible, ", "))
+	}
+
+	if sub, ok := table["materialized_from_subquery"].(map[string]interface{}); ok {
+		n.Children = planNodes(sub)
+	}
+	return n
+}
+
+// cost_info values are strings in the json
+func costOf(doc map[string]interface{}, key string) string {
+	info, ok := doc["cost_info"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	cost, _ := info[key].(string)
+	return cost
+}
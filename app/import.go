@@ -0,0 +1,317 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jacksongodsey/SFILS/shared/metrics"
+	"github.com/xuri/excelize/v2"
+)
+
+// the import is split in steps so bench import can time each one:
+// reading the workbook, cleaning the rows, filling the lookup tables and
+// writing the patrons
+
+// a row of the workbook after cleaning, ready to insert
+type patronRow struct {
+	line int // row number in the sheet, for the error messages
+
+	patronTypeCode, patronTypeDesc string
+	checkoutTotal, renewalTotal    string
+	ageRange                       string
+	libraryCode, libraryName       string
+	activeMonth, activeYear        interface{}
+	notificationCode               string
+	notificationDesc               string
+	email                          interface{}
+	withinSFC                      int
+	yearRegistered                 interface{}
+
+	// filled in by the lookups
+	patronTypeID int
+}
+
+// the columns of the patrons insert, in the order of patronRow.values
+const patronColumns = `patron_type_id, checkout_total, renewal_total,
+	age_range, home_library_code, active_month, active_year,
+	notification_type_code, email, within_sfc, year_registered`
+
+func (p *patronRow) values() []interface{} {
+	return []interface{}{
+		p.patronTypeID, p.checkoutTotal, p.renewalTotal,
+		p.ageRange, p.libraryCode, p.activeMonth, p.activeYear,
+		p.notificationCode, p.email, p.withinSFC, p.yearRegistered,
+	}
+}
+
+// reads every row of the first sheet
+func readWorkbook(file string) ([][]string, error) {
+	f, err := excelize.OpenFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return f.GetRows(f.GetSheetName(0))
+}
+
+// cleans the rows up and converts the values, rows that are too short are
+// skipped and counted as bad
+func cleanRows(rows [][]string) (patrons []patronRow, bad int) {
+	for i, row := range rows {
+		if i == 0 { // skip the header here to make sure this all works.
+			continue
+		}
+
+		// make sure we have enough columns
+		if len(row) < 14 {
+			fmt.Printf("skipping row %d: insufficient columns (has %d, needs 14)\n", i, len(row))
+			bad++
+			continue
+		}
+
+		// clean up each cell
+		for j := range row {
+			row[j] = strings.TrimSpace(strings.ReplaceAll(row[j], "\n", " "))
+		}
+
+		// converts bools from true/false to 1/0
+		withinSFC := 0
+		if strings.EqualFold(row[12], "true") {
+			withinSFC = 1
+		}
+
+		patrons = append(patrons, patronRow{
+			line:             i,
+			patronTypeCode:   row[0],
+			patronTypeDesc:   row[1],
+			checkoutTotal:    row[2],
+			renewalTotal:     row[3],
+			ageRange:         row[4],
+			libraryCode:      row[5],
+			libraryName:      row[6],
+			activeMonth:      monthToIntOrNull(row[7]),
+			activeYear:       stringToIntOrNull(row[8]),
+			notificationCode: row[9],
+			notificationDesc: row[10],
+			email:            cleanEmail(row[11]),
+			withinSFC:        withinSFC,
+			yearRegistered:   stringToIntOrNull(row[13]),
+		})
+	}
+	return patrons, bad
+}
+
+// cleans and writes rows that didn't come from the workbook, like the ones
+// sent to the grpc import, the way importExcel does with the workbook's
+func importRows(db *sql.DB, rows [][]string) (good, bad int, err error) {
+	// cleanRows skips the first row as the header
+	patrons, short := cleanRows(append([][]string{nil}, rows...))
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	patrons, lookupFailed, err := lookupDimensions(tx, patrons)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	good, insertFailed, err := writePatrons(db, tx, patrons, writeStrategy{name: "batched", batch: 1000})
+	if err == nil {
+		countImport(len(rows), short, lookupFailed, insertFailed)
+	}
+	return good, short + lookupFailed + insertFailed, err
+}
+
+// adds an import's rows to the metrics
+func countImport(processed, short, lookupFailed, insertFailed int) {
+	metrics.Rows(processed, map[string]int{
+		metrics.ShortRow:     short,
+		metrics.LookupFailed: lookupFailed,
+		metrics.InsertFailed: insertFailed,
+	})
+}
+
+// fills the patron types, libraries and notification types tables with
+// every code the rows use, once per code instead of once per row. rows
+// whose codes couldn't be added are dropped and counted as bad. it's all
+// written in tx, writePatrons commits it.
+func lookupDimensions(tx *sql.Tx, patrons []patronRow) ([]patronRow, int, error) {
+	typeIDs := map[string]int{}
+	libraries := map[string]bool{}
+	notifications := map[string]bool{}
+	failed := map[string]bool{}
+	for _, p := range patrons {
+		if _, ok := typeIDs[p.patronTypeCode]; !ok && !failed["type "+p.patronTypeCode] {
+			id, err := getPatronTypeID(tx, p.patronTypeCode, p.patronTypeDesc)
+			if err != nil {
+				fmt.Printf("failed patron type %q: %v\n", p.patronTypeCode, err)
+				failed["type "+p.patronTypeCode] = true
+			} else {
+				typeIDs[p.patronTypeCode] = id
+			}
+		}
+		if !libraries[p.libraryCode] && !failed["library "+p.libraryCode] {
+			if err := ensureLibrary(tx, p.libraryCode, p.libraryName); err != nil {
+				fmt.Printf("failed library %q: %v\n", p.libraryCode, err)
+				failed["library "+p.libraryCode] = true
+			} else {
+				libraries[p.libraryCode] = true
+			}
+		}
+		if !notifications[p.notificationCode] && !failed["notification "+p.notificationCode] {
+			if err := ensureNotificationType(tx, p.notificationCode, p.notificationDesc); err != nil {
+				fmt.Printf("failed notification %q: %v\n", p.notificationCode, err)
+				failed["notification "+p.notificationCode] = true
+			} else {
+				notifications[p.notificationCode] = true
+			}
+		}
+	}
+	kept := make([]patronRow, 0, len(patrons))
+	bad := 0
+	for _, p := range patrons {
+		id, ok := typeIDs[p.patronTypeCode]
+		if !ok || !libraries[p.libraryCode] || !notifications[p.notificationCode] {
+			bad++
+			continue
+		}
+		p.patronTypeID = id
+		kept = append(kept, p)
+	}
+	return kept, bad, nil
+}
+
+// how the patrons get written. batch is the rows per insert and workers
+// how many inserts run at once, only used by the strategies that need them.
+type writeStrategy struct {
+	name     string
+	batch    int
+	workers  int
+	progress bool // print how far along it is every 10k rows
+}
+
+// writes the patrons with the strategy's inserts and counts the rows that
+// went in and the ones that didn't. tx has the lookups for the rows and
+// gets committed here. single and batched write the patrons in it as well,
+// so an import that fails doesn't leave lookup rows behind. concurrent
+// needs a transaction per worker, so the lookups are committed first and
+// stay even if the workers fail.
+func writePatrons(db *sql.DB, tx *sql.Tx, patrons []patronRow, strategy writeStrategy) (good, bad int, err error) {
+	switch strategy.name {
+	case "single":
+		good, bad, err = writeSingle(tx, patrons, strategy.progress)
+	case "batched":
+		good, bad = insertBatches(tx, patrons, strategy.batch)
+	case "concurrent":
+		if err := tx.Commit(); err != nil {
+			return 0, 0, err
+		}
+		return writeConcurrent(db, patrons, strategy.batch, strategy.workers)
+	default:
+		return 0, 0, fmt.Errorf("unknown write strategy %q", strategy.name)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return good, bad, tx.Commit()
+}
+
+// one insert per row with a prepared statement
+func writeSingle(tx *sql.Tx, patrons []patronRow, progress bool) (good, bad int, err error) {
+	// prepared statement. using the same statement is quicker
+	stmt, err := tx.Prepare("INSERT INTO patrons (" + patronColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stmt.Close()
+
+	for i := range patrons {
+		p := &patrons[i]
+		if _, err := stmt.Exec(p.values()...); err != nil {
+			fmt.Printf("failed to insert row %d: %v\n", p.line, err)
+			if bad < 5 { // Only show the first 5 if we get an error
+				fmt.Printf("row data: %v\n", p.values())
+			}
+			bad++
+			continue
+		}
+
+		good++
+		// print progress every 10k rows so i know it's working
+		if progress && p.line%10000 == 0 {
+			fmt.Printf("processed %d rows (%d successful, %d errors)\n", p.line, good, bad)
+		}
+	}
+	return good, bad, nil
+}
+
+// one insert per batch of rows in a transaction of its own, what each of
+// the concurrent workers does
+func writeBatches(db *sql.DB, patrons []patronRow, batch int) (good, bad int, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	good, bad = insertBatches(tx, patrons, batch)
+	return good, bad, tx.Commit()
+}
+
+// batched inserts from several workers, each with its own transaction and
+// its own share of the rows
+func writeConcurrent(db *sql.DB, patrons []patronRow, batch, workers int) (good, bad int, err error) {
+	if len(patrons) == 0 {
+		return 0, 0, nil
+	}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		per = (len(patrons) + workers - 1) / workers
+	)
+	for start := 0; start < len(patrons); start += per {
+		part := patrons[start:min(start+per, len(patrons))]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g, b, werr := writeBatches(db, part, batch)
+			mu.Lock()
+			defer mu.Unlock()
+			if werr != nil {
+				// the transaction didn't go in, so none of its rows did
+				g, b = 0, len(part)
+				if err == nil {
+					err = werr
+				}
+			}
+			good += g
+			bad += b
+		}()
+	}
+	wg.Wait()
+	return good, bad, err
+}
+
+// inserts the rows batch at a time with multi row inserts. when an insert
+// fails every row in it counts as bad.
+func insertBatches(tx *sql.Tx, patrons []patronRow, batch int) (good, bad int) {
+	for start := 0; start < len(patrons); start += batch {
+		chunk := patrons[start:min(start+batch, len(patrons))]
+		placeholders := strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?), ", len(chunk)), ", ")
+		args := make([]interface{}, 0, len(chunk)*11)
+		for i := range chunk {
+			args = append(args, chunk[i].values()...)
+		}
+		if _, err := tx.Exec("INSERT INTO patrons ("+patronColumns+") VALUES "+placeholders, args...); err != nil {
+			fmt.Printf("failed to insert rows %d to %d: %v\n", chunk[0].line, chunk[len(chunk)-1].line, err)
+			bad += len(chunk)
+			continue
+		}
+		good += len(chunk)
+	}
+	return good, bad
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -11,7 +15,16 @@ import (
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/xuri/excelize/v2"
+	"github.com/jacksongodsey/SFILS/shared/api"
+	"github.com/jacksongodsey/SFILS/shared/bench"
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/interrupt"
+	"github.com/jacksongodsey/SFILS/shared/metrics"
+	"github.com/jacksongodsey/SFILS/shared/named"
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"github.com/jacksongodsey/SFILS/shared/report"
+	"github.com/jacksongodsey/SFILS/shared/verify"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 const (
@@ -19,9 +32,75 @@ const (
 	host   = "127.0.0.1"
 	port   = "3306"
 	dbName = "sfils"
+
+	// the workbook that gets imported
+	workbookFile = "../data/sfpl.xlsx"
+)
+
+// command line flags, these go before the command
+var (
+	allowWrites = flag.Bool("allow-writes", false, "let the query interface run statements that change data")
+	queriesFile = flag.String("queries", "../queries/named.sql", "file with the saved queries")
+	suiteFile   = flag.String("suite", "../queries/bench.yaml", "yaml file with the benchmark queries")
+	metricsAddr = flag.String("metrics-addr", "", "serve the prometheus metrics on this address at /metrics while the command runs")
+	pushGateway = flag.String("push-gateway", "", "push the prometheus metrics to this pushgateway url when the command is done")
 )
 
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "usage: go run . [flags] [command]")
+	fmt.Fprintln(out, "\ncommands:")
+	fmt.Fprintln(out, "  (none)                      load the workbook into mysql and open the query interface")
+	fmt.Fprintln(out, "  import                      load the workbook into mysql and exit, for nightly loads")
+	fmt.Fprintln(out, "  query NAME [key=value ...]  run a saved query and print the result")
+	fmt.Fprintln(out, "  query SQL                   run a read-only sql statement and print the result")
+	fmt.Fprintln(out, "  report [NAME ...]           print the built-in reports, all of them without a name")
+	fmt.Fprintln(out, "  bench                       time the benchmark queries over repeated runs and save the results")
+	fmt.Fprintln(out, "  bench compare OLD NEW       compare two saved runs and fail on regressions")
+	fmt.Fprintln(out, "  bench report RUN|FOLDER ... write saved mysql and mongodb runs side by side as markdown or html")
+	fmt.Fprintln(out, "  verify [-against FILE]      save a snapshot of the data to check it against mongodb's")
+	fmt.Fprintln(out, "  verify compare BASE HEAD    compare two saved snapshots and fail when they differ")
+	fmt.Fprintln(out, "  bench load                  run a weighted mix of the benchmark queries from many clients at once")
+	fmt.Fprintln(out, "  bench import                time each step of the import and compare ways of writing the rows")
+	fmt.Fprintln(out, "  bench indexes               run the benchmark with and without each candidate index and suggest which to keep")
+	fmt.Fprintln(out, "  serve [-addr :8080]         serve the patron data as a json api, the spec is at /openapi.json")
+	fmt.Fprintln(out, "  serve spec [-out FILE]      write the api's OpenAPI spec")
+	fmt.Fprintln(out, "  grpc [-addr :9090]          serve the patron data over grpc for the data-sync jobs")
+	fmt.Fprintln(out, "\nflags:")
+	flag.PrintDefaults()
+}
+
 func main() {
+	flag.Usage = usage
+	flag.Parse()
+	metrics.Setup("mysql")
+
+	// comparing saved benchmark runs doesn't need the database
+	if flag.Arg(0) == "bench" && flag.Arg(1) == "compare" {
+		if err := bench.CompareCommand(flag.Args()[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if flag.Arg(0) == "bench" && flag.Arg(1) == "report" {
+		if err := bench.ReportCommand(flag.Args()[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if flag.Arg(0) == "verify" && flag.Arg(1) == "compare" {
+		if err := verify.CompareCommand(flag.Args()[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if flag.Arg(0) == "serve" && flag.Arg(1) == "spec" {
+		if err := api.SpecCommand("mysql", flag.Args()[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// environment variable grabbing for the password.
 	password := os.Getenv("DB_PASSWORD")
 	if password == "" {
@@ -32,48 +111,89 @@ func main() {
 
 	// connecting to mysql.
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/", user, password, host, port)
-	db, err := sql.Open("mysql", dsn)
+	server, err := openDB(dsn)
 	if err != nil {
-		log.Fatal("couldn't open the database:", err)
-	}
-	defer db.Close()
-
-	// setting conection settings - not sure if these numbers are optimal but they work
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
-
-	if err := db.Ping(); err != nil {
-		log.Fatal("couldn't connect to db:", err)
+		log.Fatal(err)
 	}
 
 	// create the database if it doesn't exist.
-	_, err = db.Exec("CREATE DATABASE IF NOT EXISTS " + dbName)
+	_, err = server.Exec("CREATE DATABASE IF NOT EXISTS " + dbName)
+	server.Close()
 	if err != nil {
 		log.Fatal("couldn't create the database:", err)
 	}
-	fmt.Println("database", dbName, "ready.")
 
-	// switch to the new database
-	_, err = db.Exec("USE " + dbName)
+	// switch to the new database. running USE only switches whichever pooled
+	// connection it happened to run on, which is where the "no database
+	// selected" errors came from, so the database goes in the dsn instead.
+	db, err := openDB(dsn + dbName)
 	if err != nil {
-		log.Fatal("couldn't switch to database:", err)
+		log.Fatal(err)
+	}
+	defer db.Close()
+	metrics.Register(collectors.NewDBStatsCollector(db, dbName))
+	if *metricsAddr != "" {
+		if err := metrics.Serve(*metricsAddr); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	// running all the scripts in the scripts folder.
-	err = runScripts(db, "../scripts")
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+		fmt.Println("database", dbName, "ready.")
+		if err = loadWorkbook(db); err == nil {
+			startTextInterface(db, *allowWrites)
+		}
+	case "import":
+		err = loadWorkbook(db)
+	case "query":
+		err = runQueryCommand(db, flag.Args()[1:])
+	case "report":
+		err = runReportCommand(db, flag.Args()[1:])
+	case "bench":
+		err = runBenchCommand(db, flag.Args()[1:])
+	case "verify":
+		err = runVerifyCommand(db, flag.Args()[1:])
+	case "serve":
+		err = runServeCommand(db, flag.Args()[1:])
+	case "grpc":
+		err = runGRPCCommand(db, flag.Args()[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", cmd)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// pushed even when the command failed, a failed nightly load is what
+	// the dashboards most need to see
+	if *pushGateway != "" {
+		if err := metrics.Push(*pushGateway); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
 	if err != nil {
+		db.Close()
 		log.Fatal(err)
 	}
+}
 
-	// read excel and import data.
-	err = importExcel(db, "../data/sfpl.xlsx")
+// opens a connection pool and makes sure the server is there
+func openDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("mysql", dsn)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("couldn't open the database: %v", err)
 	}
 
-	// start the text interface
-	startTextInterface(db)
+	// setting conection settings - not sure if these numbers are optimal but they work
+	db.SetMaxOpenConns(25)
+	db.SetMaxIdleConns(5)
+	db.SetConnMaxLifetime(5 * time.Minute)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("couldn't connect to db: %v", err)
+	}
+	return db, nil
 }
 
 // running all the scripts in the folder
@@ -215,130 +335,52 @@ func ensureNotificationType(tx *sql.Tx, code, name string) error {
 	return err
 }
 
-// reads an excel file and puts the data into the patrons table
-func importExcel(db *sql.DB, file string) error {
-	f, err := excelize.OpenFile(file)
-	if err != nil {
+// creates the tables again and imports the workbook into them
+func loadWorkbook(db *sql.DB) error {
+	// running all the scripts in the scripts folder.
+	if err := runScripts(db, "../scripts"); err != nil {
 		return err
 	}
-	defer f.Close()
 
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
+	// read excel and import data.
+	return importExcel(db, workbookFile)
+}
+
+// reads an excel file and puts the data into the patrons table
+func importExcel(db *sql.DB, file string) error {
+	done := metrics.Phase("parse")
+	rows, err := readWorkbook(file)
+	done()
 	if err != nil {
 		return err
 	}
 
-	// using a transaction so if something fails we can rollback
+	done = metrics.Phase("clean")
+	patrons, short := cleanRows(rows)
+	done()
+
 	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// TODO: not 100% sure this is the right way to handle errors but it seems to work
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// prepared statement. using the same statement is quicker
-	stmt, err := tx.Prepare(`
-		INSERT INTO patrons (
-			patron_type_id, checkout_total, renewal_total,
-			age_range, home_library_code, active_month, active_year,
-			notification_type_code, email, within_sfc, year_registered
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`)
+	done = metrics.Phase("lookups")
+	patrons, lookupFailed, err := lookupDimensions(tx, patrons)
+	done()
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
-
-	good := 0
-	bad := 0
-
-	for i, row := range rows {
-		if i == 0 { // skip the header here to make sure this all works.
-			continue
-		}
-
-		// make sure we have enough columns
-		if len(row) < 14 {
-			fmt.Printf("skipping row %d: insufficient columns (has %d, needs 14)\n", i, len(row))
-			bad++
-			continue
-		}
-
-		// clean up each cell
-		for j := range row {
-			row[j] = strings.TrimSpace(strings.ReplaceAll(row[j], "\n", " "))
-		}
-
-		// create type and get the id
-		patronTypeID, err := getPatronTypeID(tx, row[0], row[1])
-		if err != nil {
-			fmt.Printf("failed patron type for row %d: %v\n", i, err)
-			bad++
-			continue
-		}
-
-		// ensure library exists
-		if err := ensureLibrary(tx, row[5], row[6]); err != nil {
-			fmt.Printf("failed library for row %d: %v\n", i, err)
-			bad++
-			continue
-		}
-
-		// ensure notification type exists
-		if err := ensureNotificationType(tx, row[9], row[10]); err != nil {
-			fmt.Printf("failed notification for row %d: %v\n", i, err)
-			bad++
-			continue
-		}
-
-		// convert the month to number or a null value
-		activeMonth := monthToIntOrNull(row[7])
-
-		// convert the year to number or a null value
-		activeYear := stringToIntOrNull(row[8])
-		yearRegistered := stringToIntOrNull(row[13])
-
-		// converts bools from true/false to 1/0
-		withinSFC := 0
-		if strings.EqualFold(row[12], "true") {
-			withinSFC = 1
-		}
-
-		// clean email and set to null if it's not in a valid format
-		email := cleanEmail(row[11])
-
-		// insert data with patron_type_id instead of code/def
-		_, err = stmt.Exec(
-			patronTypeID, row[2], row[3],
-			row[4], row[5], activeMonth, activeYear,
-			row[9], email, withinSFC, yearRegistered,
-		)
-		if err != nil {
-			fmt.Printf("failed to insert row %d: %v\n", i, err)
-			if bad < 5 { // Only show the first 5 if we get an error
-				fmt.Printf("row data: %v\n", row)
-			}
-			bad++
-			continue
-		}
-
-		good++
-		// print progress every 10k rows so i know it's working
-		if i%10000 == 0 {
-			fmt.Printf("processed %d rows (%d successful, %d errors)\n", i, good, bad)
-		}
-	}
 
-	err = tx.Commit()
+	done = metrics.Phase("writes")
+	good, insertFailed, err := writePatrons(db, tx, patrons, writeStrategy{name: "single", progress: true})
+	done()
 	if err != nil {
 		return err
 	}
+	countImport(len(rows)-1, short, lookupFailed, insertFailed)
+	metrics.ImportDone()
+	bad := short + lookupFailed + insertFailed
 
 	fmt.Printf("\nexcel import complete:\n")
 	fmt.Printf("  total rows processed: %d\n", len(rows)-1)
@@ -348,19 +390,64 @@ func importExcel(db *sql.DB, file string) error {
 	return nil
 }
 
+// session holds the settings that can be changed from inside the text interface
+type session struct {
+	db          *sql.DB
+	reader      *bufio.Reader
+	display     *output.Settings
+	allowWrites bool
+	timeout     time.Duration
+	timing      bool
+	queries     []*namedQuery
+
+	// where row counts and timings go. stdout in the text interface, stderr
+	// for the query command so only the results end up in a pipe
+	status io.Writer
+
+	// the query that's running, ctrl-c cancels it
+	running interrupt.Running
+}
+
 // providing a very basic text interface
-func startTextInterface(db *sql.DB) {
+func startTextInterface(db *sql.DB, allowWrites bool) {
 	fmt.Println("\n=== Program interface ===")
-	fmt.Println("Type SQL queries to run (best to run select queries)")
+	if allowWrites {
+		fmt.Println("Type SQL queries to run. Writes are allowed, be careful")
+	} else {
+		fmt.Println("Type SQL queries to run (read-only, start with --allow-writes to change data)")
+	}
 	fmt.Println("Type 'exit' or 'quit' to quit")
-	fmt.Println("Type 'help' for example queries")
+	fmt.Println("Type 'help' for saved queries, reports and commands")
 	fmt.Println()
 
-	reader := bufio.NewReader(os.Stdin)
+	s := &session{
+		db:          db,
+		reader:      bufio.NewReader(os.Stdin),
+		display:     output.NewSettings(),
+		allowWrites: allowWrites,
+		timeout:     interrupt.DefaultTimeout,
+		status:      os.Stdout,
+	}
+	defer s.display.Close()
+
+	queries, err := loadNamedQueries(*queriesFile)
+	if err != nil {
+		fmt.Println("couldn't load saved queries:", err)
+	}
+	s.queries = queries
+
+	// ctrl-c stops the running query instead of the whole program
+	stopInterrupts := s.running.Handle()
+	defer stopInterrupts()
 
 	for {
 		fmt.Print("> ")
-		input, _ := reader.ReadString('\n')
+		input, err := s.reader.ReadString('\n')
+		if err != nil && input == "" {
+			// ctrl-d, or stdin ran out
+			fmt.Println("\nSee ya!")
+			break
+		}
 		input = strings.TrimSpace(input)
 
 		if input == "" {
@@ -373,131 +460,311 @@ func startTextInterface(db *sql.DB) {
 		}
 
 		if input == "help" {
-			printHelp()
+			printHelp(s)
 			continue
 		}
 		if input == "benchmark" {
-			runBenchmark(db)
+			if suite, err := bench.LoadSuite(*suiteFile); err != nil {
+				fmt.Println("couldn't load the benchmark suite:", err)
+			} else {
+				runBenchmark(db, suite, bench.DefaultOptions)
+			}
 			continue
 		}
 
-		// run the query
-		rows, err := db.Query(input)
-		if err != nil {
-			fmt.Println("query error:", err)
+		// anything starting with a backslash changes a setting
+		if strings.HasPrefix(input, "\\") {
+			s.metaCommand(input)
 			continue
 		}
 
-		// get the names of columns
-		cols, err := rows.Columns()
-		if err != nil {
-			fmt.Println("error getting columns:", err)
-			rows.Close()
+		if strings.HasPrefix(input, ":") {
+			s.namedCommand(input)
 			continue
 		}
 
-		// print headers of the columns
-		fmt.Println(strings.Repeat("-", 80))
-		for i, col := range cols {
-			if i > 0 {
-				fmt.Print(" | ")
-			}
-			fmt.Print(col)
+		s.execute(input)
+	}
+}
+
+// handles the backslash commands
+func (s *session) metaCommand(input string) {
+	fields := strings.Fields(input)
+	cmd, args := fields[0], fields[1:]
+
+	if s.display.Command(cmd, args) {
+		return
+	}
+
+	switch cmd {
+	case "\\timing":
+		switch {
+		case len(args) == 0:
+			s.timing = !s.timing
+		case args[0] == "on" || args[0] == "off":
+			s.timing = args[0] == "on"
+		default:
+			fmt.Println("usage: \\timing [on|off]")
+			return
+		}
+		if s.timing {
+			fmt.Println("timing is on")
+		} else {
+			fmt.Println("timing is off")
+		}
+	case "\\explain":
+		query := strings.TrimSpace(strings.TrimPrefix(input, cmd))
+		if query == "" {
+			fmt.Println("usage: \\explain SELECT ...")
+			return
+		}
+		s.explain(query)
+	case "\\timeout":
+		if len(args) == 0 {
+			fmt.Println("query timeout is", interrupt.DescribeTimeout(s.timeout))
+			return
+		}
+		d, err := interrupt.ParseTimeout(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		s.timeout = d
+		fmt.Println("query timeout is", interrupt.DescribeTimeout(s.timeout))
+	case "\\dt":
+		s.listTables()
+	case "\\d":
+		if len(args) != 1 {
+			fmt.Println("usage: \\d TABLE")
+			return
 		}
-		fmt.Println()
-		fmt.Println(strings.Repeat("-", 80))
-
-		// making containers for the values. handy go feature
-		values := make([]interface{}, len(cols))
-		valuePtrs := make([]interface{}, len(cols))
-		for i := range values {
-			valuePtrs[i] = &values[i]
+		s.describeTable(args[0])
+	case "\\di":
+		table := ""
+		if len(args) > 0 {
+			table = args[0]
 		}
+		s.listIndexes(table)
+	default:
+		fmt.Println("unknown command:", cmd)
+	}
+}
 
-		// printing the rows
-		rowCount := 0
-		for rows.Next() {
-			err := rows.Scan(valuePtrs...)
-			if err != nil {
-				fmt.Println("error scanning row:", err)
-				continue
-			}
+// works out whether a statement is allowed to run and then runs it
+func (s *session) execute(input string) {
+	if dsl.IsQuery(input) {
+		if err := s.runDSL(input); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+	if isReadStatement(input) {
+		if err := s.runQuery(input); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
 
-			for i, val := range values {
-				if i > 0 {
-					fmt.Print(" | ")
-				}
+	kind := statementKind(input)
+	if kind == "" {
+		fmt.Println("couldn't find a statement to run")
+		return
+	}
+	if !s.allowWrites {
+		fmt.Printf("%s statements aren't allowed in read-only mode. restart with --allow-writes to change data\n", kind)
+		return
+	}
+	if destructiveStatements[kind] && !s.confirm(fmt.Sprintf("this runs %s and can't be undone. type 'yes' to go ahead: ", kind)) {
+		fmt.Println("cancelled")
+		return
+	}
 
-				// null handling
-				if val == nil {
-					fmt.Print("NULL")
-				} else {
-					// converting the byte arrays to strings
-					switch v := val.(type) {
-					case []byte:
-						fmt.Print(string(v))
-					default:
-						fmt.Print(v)
-					}
-				}
-			}
-			fmt.Println()
-			rowCount++
-		}
+	ctx, done := s.running.Start(s.timeout)
+	defer done()
+	conn, release, err := s.pinConnection(ctx)
+	if err != nil {
+		fmt.Println(interrupt.Error(ctx, err))
+		return
+	}
+	defer release()
 
-		fmt.Println(strings.Repeat("-", 80))
-		fmt.Printf("%d rows returned\n\n", rowCount)
-		rows.Close()
+	start := time.Now()
+	res, err := conn.ExecContext(ctx, input)
+	if err != nil {
+		fmt.Println(interrupt.Error(ctx, err))
+		return
 	}
+	affected, _ := res.RowsAffected()
+	fmt.Printf("%d rows affected\n", affected)
+	s.printTiming(time.Since(start))
+	fmt.Println()
 }
 
-// some example queries
-func printHelp() {
-	fmt.Println("\n=== Some example queries you can try ===")
-	fmt.Println("SELECT COUNT(*) FROM patrons;")
-	fmt.Println("SELECT * FROM patrons LIMIT 10;")
-	fmt.Println("SELECT patron_type_def, COUNT(*) as count FROM patrons GROUP BY patron_type_def;")
-	fmt.Println("SELECT age_range, COUNT(*) as count FROM patrons GROUP BY age_range ORDER BY count DESC;")
-	fmt.Println("SELECT home_library_def, COUNT(*) as count FROM patrons WHERE within_sfc = 1 GROUP BY home_library_def;")
-	fmt.Println("SELECT * FROM patrons WHERE email LIKE '%@gmail.com%' LIMIT 5;")
-	fmt.Println("\nType 'benchmark' to run performance tests")
-	fmt.Println()
+// asks a yes/no question, anything but yes counts as no
+func (s *session) confirm(prompt string) bool {
+	fmt.Print(prompt)
+	answer, _ := s.reader.ReadString('\n')
+	return strings.EqualFold(strings.TrimSpace(answer), "yes")
+}
+
+// queryer is what *sql.Conn and *sql.Tx have in common for running queries
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 }
 
-// benchmark to test performance
-func runBenchmark(db *sql.DB) {
-	fmt.Println("\n=== performance test ===")
-
-	tests := []struct {
-		name  string
-		query string
-	}{
-		{"Count all patrons", "SELECT COUNT(*) FROM patrons"},
-		{"Count by patron type", "SELECT pt.description, COUNT(*) FROM patrons p JOIN patron_types pt ON p.patron_type_id = pt.id GROUP BY pt.description"},
-		{"Count by age range", "SELECT age_range, COUNT(*) FROM patrons GROUP BY age_range"},
-		{"Count by library", "SELECT l.name, COUNT(*) FROM patrons p JOIN libraries l ON p.home_library_code = l.code GROUP BY l.name"},
-		{"Find SF patrons", "SELECT COUNT(*) FROM patrons WHERE within_sfc = 1"},
-		{"Active in 2023", "SELECT COUNT(*) FROM patrons WHERE active_year = 2023"},
-	}
-
-	for _, test := range tests {
-		start := time.Now()
-		rows, err := db.Query(test.query)
+// runs a query and writes the result in the current output format
+func (s *session) runQuery(query string, args ...interface{}) error {
+	ctx, done := s.running.Start(s.timeout)
+	defer done()
+	conn, release, err := s.pinConnection(ctx)
+	if err != nil {
+		return errors.New(interrupt.Error(ctx, err))
+	}
+	defer release()
+
+	var q queryer = conn
+	if !s.allowWrites {
+		// the statement check should already have stopped anything that writes,
+		// but running inside a read only transaction means mysql refuses any
+		// changes that slip through. DDL isn't covered since it commits the
+		// transaction before it runs, which is why the check comes first.
+		tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
 		if err != nil {
-			fmt.Printf("%s: error - %v\n", test.name, err)
+			return fmt.Errorf("couldn't start a read-only transaction: %v", err)
+		}
+		defer tx.Rollback()
+		q = tx
+	}
+
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return errors.New(interrupt.Error(ctx, err))
+	}
+	defer rows.Close()
+
+	// get the names of columns and which ones are numbers
+	cols, err := resultColumns(rows)
+	if err != nil {
+		return fmt.Errorf("error getting columns: %v", err)
+	}
+
+	// making containers for the values. handy go feature
+	values := make([]interface{}, len(cols))
+	valuePtrs := make([]interface{}, len(cols))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	w, err := s.display.NewWriter()
+	if err != nil {
+		return err
+	}
+	if err := w.Begin(cols); err != nil {
+		return fmt.Errorf("error writing results: %v", err)
+	}
+
+	// writing the rows
+	rowCount := 0
+	for rows.Next() {
+		err := rows.Scan(valuePtrs...)
+		if err != nil {
+			fmt.Fprintln(s.status, "error scanning row:", err)
 			continue
 		}
 
-		// count results
-		count := 0
-		for rows.Next() {
-			count++
+		vals := make([]output.Value, len(values))
+		for i, val := range values {
+			vals[i] = toValue(val, cols[i])
+		}
+		if err := w.Row(vals); err != nil {
+			return fmt.Errorf("error writing results: %v", err)
+		}
+		rowCount++
+	}
+	if err := w.End(); err != nil {
+		return fmt.Errorf("error writing results: %v", err)
+	}
+
+	if err := rows.Err(); err != nil {
+		return errors.New(interrupt.Error(ctx, err))
+	}
+	s.printRowCount(rowCount)
+	s.printTiming(time.Since(start))
+	fmt.Fprintln(s.status)
+	return nil
+}
+
+// writes rows that were put together in go rather than read from a query
+func (s *session) writeValues(cols []output.Column, rows [][]output.Value) {
+	w, err := s.display.NewWriter()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := w.Begin(cols); err != nil {
+		fmt.Println("error writing results:", err)
+		return
+	}
+	for _, row := range rows {
+		if err := w.Row(row); err != nil {
+			fmt.Println("error writing results:", err)
+			return
 		}
-		rows.Close()
+	}
+	if err := w.End(); err != nil {
+		fmt.Println("error writing results:", err)
+	}
+	s.printRowCount(len(rows))
+	fmt.Println()
+}
+
+// the line under the results, which says where they went if \o is on
+func (s *session) printRowCount(count int) {
+	if file := s.display.Redirected(); file != "" {
+		fmt.Fprintf(s.status, "%d rows written to %s\n", count, file)
+	} else {
+		fmt.Fprintf(s.status, "%d rows returned\n", count)
+	}
+}
 
-		elapsed := time.Since(start)
-		fmt.Printf("✓ %s: %v (%d rows)\n", test.name, elapsed, count)
+// with \timing on, how long the query took. this covers reading and
+// writing out all the rows, not just the time until the first one.
+func (s *session) printTiming(elapsed time.Duration) {
+	if s.timing {
+		fmt.Fprintf(s.status, "Time: %v\n", elapsed.Round(time.Microsecond))
 	}
+}
 
-	fmt.Println("\nbenchmark done")
+// some example queries
+func printHelp(s *session) {
+	fmt.Println("\n=== Saved queries, run one with :run NAME key=value ... ===")
+	if len(s.queries) == 0 {
+		fmt.Println("(none loaded from", *queriesFile+")")
+	}
+	for _, q := range s.queries {
+		fmt.Printf(":run %s\n    %s\n", named.Usage(q.Name, q.Params), q.Description)
+	}
+	fmt.Println(":show NAME - print the sql of a saved query")
+	fmt.Println("\n=== Reports, run with :report [NAME ...] ===")
+	for _, r := range report.Reports {
+		fmt.Printf(":report %s\n    %s\n", r.Name, r.Title)
+	}
+	fmt.Println("\nPatron queries, the same on the mongodb app:")
+	fmt.Println("patrons where within_sfc and active_year = 2023 group by home_library count")
+	fmt.Println("patrons where checkout_total >= 1000 show home_library, checkout_total order by checkout_total desc limit 10")
+	fmt.Println(":compile QUERY - print the sql a patron query turns into")
+	fmt.Println("\nType 'benchmark' to run performance tests, 'go run . bench' takes -runs, -warmup and -flush")
+	fmt.Println("\\timing - show how long each query takes")
+	fmt.Println("\\explain SELECT ... - show the query plan, flagging full scans and unused indexes")
+	fmt.Println("\\timeout 30s - stop queries that run longer than this, 0 means never. ctrl-c cancels a running query")
+	fmt.Println("\nSchema commands:")
+	fmt.Println("\\dt - list tables with their row counts")
+	fmt.Println("\\d TABLE - show the columns, types and foreign keys of a table")
+	fmt.Println("\\di [TABLE] - list indexes")
+	fmt.Println("\nDisplay commands:")
+	for _, line := range s.display.Help() {
+		fmt.Println(line)
+	}
+	fmt.Println()
 }
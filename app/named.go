@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/interrupt"
+	"github.com/jacksongodsey/SFILS/shared/metrics"
+	"github.com/jacksongodsey/SFILS/shared/named"
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// namedQuery is a saved query from the queries file
+type namedQuery struct {
+	Name        string
+	Description string
+	Params      []named.Param
+	SQL         string
+}
+
+// reads saved queries from a .sql file. each one starts with a name line,
+// then optional description and param lines, then the sql:
+//
+//	-- name: top_libraries
+//	-- libraries with the most patrons
+//	-- param: limit int 10
+//	SELECT ... LIMIT :limit;
+//
+// params are "-- param: name type [default]" where type is int, float,
+// string or bool. a param without a default has to be given when running it.
+func loadNamedQueries(path string) ([]*namedQuery, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var queries []*namedQuery
+	var current *namedQuery
+	var body []string
+
+	finish := func() {
+		if current != nil {
+			current.SQL = strings.TrimSuffix(strings.TrimSpace(strings.Join(body, "\n")), ";")
+			queries = append(queries, current)
+		}
+		body = nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if rest, ok := strings.CutPrefix(trimmed, "-- name:"); ok {
+			finish()
+			current = &namedQuery{Name: strings.TrimSpace(rest)}
+			continue
+		}
+		if current == nil {
+			continue // anything before the first query is just a file comment
+		}
+
+		if rest, ok := strings.CutPrefix(trimmed, "-- param:"); ok {
+			fields := strings.Fields(rest)
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("%s:%d: params look like -- param: name type [default]", path, lineNo)
+			}
+			p := named.Param{Name: fields[0], Type: fields[1]}
+			if len(fields) > 2 {
+				def := strings.Join(fields[2:], " ")
+				p.Default = &def
+			}
+			if p.Default != nil {
+				if _, err := p.Convert(*p.Default); err != nil {
+					return nil, fmt.Errorf("%s:%d: default for %v", path, lineNo, err)
+				}
+			}
+			current.Params = append(current.Params, p)
+			continue
+		}
+
+		// comment lines before the sql starts are the description
+		if rest, ok := strings.CutPrefix(trimmed, "--"); ok && len(body) == 0 {
+			if current.Description == "" {
+				current.Description = strings.TrimSpace(rest)
+			}
+			continue
+		}
+
+		if trimmed != "" || len(body) > 0 {
+			body = append(body, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	finish()
+
+	return queries, nil
+}
+
+// finds a saved query by name
+func findNamedQuery(queries []*namedQuery, name string) *namedQuery {
+	for _, q := range queries {
+		if q.Name == name {
+			return q
+		}
+	}
+	return nil
+}
+
+// swaps each :param in the sql for a ? and returns the values in the same
+// order, so the values only ever go to mysql as placeholders. text inside
+// quotes and comments is left alone, and so is := which mysql uses for assignment.
+func (q *namedQuery) bind(args map[string]string) (string, []interface{}, error) {
+	values, err := named.Resolve(q.Params, args)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var out strings.Builder
+	var bound []interface{}
+	runes := []rune(q.SQL)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			// copy quoted text as it is
+			start := i
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' && r != '`' {
+					i++
+				} else if runes[i] == r {
+					break
+				}
+			}
+			end := min(i+1, len(runes))
+			out.WriteString(string(runes[start:end]))
+		case r == '#' || r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			start := i
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			out.WriteString(string(runes[start:min(i+1, len(runes))]))
+		case r == '/' && i+2 < len(runes) && runes[i+1] == '*' && runes[i+2] != '!':
+			// mysql runs what's in /*! ... */ so params in those still get bound
+			start := i
+			for i += 2; i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+			}
+			i = min(i+1, len(runes)-1)
+			out.WriteString(string(runes[start : i+1]))
+		case r == ':' && i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || runes[i+1] == '_') && (i == 0 || runes[i-1] != ':'):
+			start := i + 1
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_') {
+				i++
+			}
+			name := string(runes[start : i+1])
+			v, ok := values[name]
+			if !ok {
+				return "", nil, fmt.Errorf("query %s uses :%s but doesn't declare it as a param", q.Name, name)
+			}
+			out.WriteString("?")
+			bound = append(bound, v)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String(), bound, nil
+}
+
+// :run NAME key=value ..., :show NAME, :compile QUERY and :report [NAME ...]
+// in the text interface
+func (s *session) namedCommand(input string) {
+	if cmd, query, _ := strings.Cut(input, " "); cmd == ":compile" {
+		if strings.TrimSpace(query) == "" {
+			fmt.Println("usage: :compile patrons where ...")
+			return
+		}
+		s.showCompiled(query)
+		return
+	}
+	if cmd, names, _ := strings.Cut(input, " "); cmd == ":report" {
+		s.reportCommand(strings.Fields(names))
+		return
+	}
+
+	args, err := named.SplitArgs(input)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	cmd, args := args[0], args[1:]
+
+	if cmd != ":run" && cmd != ":show" {
+		fmt.Println("unknown command:", cmd)
+		return
+	}
+	if len(args) == 0 {
+		fmt.Printf("usage: %s NAME, type 'help' to see the saved queries\n", cmd)
+		return
+	}
+	q := findNamedQuery(s.queries, args[0])
+	if q == nil {
+		fmt.Printf("no saved query called %q, type 'help' to see them\n", args[0])
+		return
+	}
+
+	if cmd == ":show" {
+		fmt.Printf("-- %s\n", q.Description)
+		for _, p := range q.Params {
+			if p.Default != nil {
+				fmt.Printf("-- param: %s %s %s\n", p.Name, p.Type, *p.Default)
+			} else {
+				fmt.Printf("-- param: %s %s\n", p.Name, p.Type)
+			}
+		}
+		fmt.Println(q.SQL + ";")
+		fmt.Println()
+		return
+	}
+
+	if err := s.runNamed(q, args[1:]); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// binds the arguments and runs a saved query, which follows the same
+// read-only rules as anything typed in
+func (s *session) runNamed(q *namedQuery, args []string) error {
+	values, err := named.ParseArgs(args)
+	if err != nil {
+		return err
+	}
+	query, bound, err := q.bind(values)
+	if err != nil {
+		return fmt.Errorf("%v\nusage: %s", err, named.Usage(q.Name, q.Params))
+	}
+	if !s.allowWrites && !isReadStatement(query) {
+		return fmt.Errorf("%s changes data, which isn't allowed in read-only mode. use --allow-writes", q.Name)
+	}
+	done := metrics.Query("saved", q.Name)
+	err = s.runQuery(query, bound...)
+	done(err)
+	return err
+}
+
+// the query command: runs one saved query or sql statement, prints the result
+// to stdout and exits, so it can be used from scripts without the text interface
+//
+//	go run . query top_libraries limit=5
+//	go run . query -format csv "SELECT * FROM libraries"
+func runQueryCommand(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	format := flags.String("format", "table", "output format ("+strings.Join(output.Formats, ", ")+")")
+	timeout := flags.Duration("timeout", interrupt.DefaultTimeout, "stop the query after this long, 0 means never")
+	timing := flags.Bool("timing", false, "print how long the query took")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . query [flags] NAME [key=value ...]")
+		fmt.Fprintln(flags.Output(), "       go run . query [flags] SQL")
+		fmt.Fprintln(flags.Output(), "       go run . query [flags] patrons where ...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if !output.IsFormat(*format) {
+		return fmt.Errorf("unknown format %q, use one of %s", *format, strings.Join(output.Formats, ", "))
+	}
+
+	s := &session{
+		db:          db,
+		display:     output.NewSettings(),
+		allowWrites: *allowWrites,
+		timeout:     *timeout,
+		timing:      *timing,
+		status:      os.Stderr,
+	}
+	s.display.Format = *format
+
+	queries, err := loadNamedQueries(*queriesFile)
+	if q := findNamedQuery(queries, flags.Arg(0)); q != nil {
+		return s.runNamed(q, flags.Args()[1:])
+	}
+
+	// not a saved query, so it should be a patron query or sql
+	query := strings.Join(flags.Args(), " ")
+	if dsl.IsQuery(query) {
+		return s.runDSL(query)
+	}
+	kind := statementKind(query)
+	if kind == "" || (flags.NArg() == 1 && !strings.ContainsAny(query, " \t\n")) {
+		if err != nil {
+			return fmt.Errorf("couldn't load saved queries: %v", err)
+		}
+		return fmt.Errorf("no saved query called %q", flags.Arg(0))
+	}
+	if !s.allowWrites && !isReadStatement(query) {
+		return fmt.Errorf("%s statements aren't allowed in read-only mode. use --allow-writes to change data", kind)
+	}
+	return s.runQuery(query)
+}
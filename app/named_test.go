@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jacksongodsey/SFILS/shared/named"
+)
+
+func TestBind(t *testing.T) {
+	ten := "10"
+	params := []named.Param{
+		{Name: "library", Type: "string"},
+		{Name: "min", Type: "int", Default: &ten},
+		{Name: "sf", Type: "bool"},
+	}
+	args := map[string]string{"library": "X", "sf": "true"}
+
+	tests := []struct {
+		name string
+		sql  string
+		want string
+		args []interface{}
+	}{
+		{
+			name: "params become placeholders in order",
+			sql:  "SELECT * FROM patrons WHERE home_library_code = :library AND checkout_total >= :min AND within_sfc = :sf AND renewal_total >= :min",
+			want: "SELECT * FROM patrons WHERE home_library_code = ? AND checkout_total >= ? AND within_sfc = ? AND renewal_total >= ?",
+			args: []interface{}{"X", int64(10), true, int64(10)},
+		},
+		{
+			name: "params next to brackets and commas",
+			sql:  "SELECT * FROM patrons WHERE home_library_code IN (:library,'M2') LIMIT :min",
+			want: "SELECT * FROM patrons WHERE home_library_code IN (?,'M2') LIMIT ?",
+			args: []interface{}{"X", int64(10)},
+		},
+		{
+			name: "quotes are left alone",
+			sql:  `SELECT ':library', ":min", ` + "`:sf`" + `, 'it\'s :library', 'it''s :min' FROM patrons WHERE email = :library`,
+			want: `SELECT ':library', ":min", ` + "`:sf`" + `, 'it\'s :library', 'it''s :min' FROM patrons WHERE email = ?`,
+			args: []interface{}{"X"},
+		},
+		{
+			name: "comments are left alone",
+			sql:  "SELECT 1 -- :library\n# :min\n/* :sf */ FROM patrons WHERE email = :library",
+			want: "SELECT 1 -- :library\n# :min\n/* :sf */ FROM patrons WHERE email = ?",
+			args: []interface{}{"X"},
+		},
+		{
+			name: "executable comments still get bound",
+			sql:  "SELECT 1 /*!50000 + :min */",
+			want: "SELECT 1 /*!50000 + ? */",
+			args: []interface{}{int64(10)},
+		},
+		{
+			name: ":: and := aren't params",
+			sql:  "SELECT @x := 1, 'a'::text, a::library FROM patrons",
+			want: "SELECT @x := 1, 'a'::text, a::library FROM patrons",
+		},
+		{
+			name: "a colon before a digit isn't a param",
+			sql:  "SELECT TIME('10:30') FROM patrons WHERE id > :min",
+			want: "SELECT TIME('10:30') FROM patrons WHERE id > ?",
+			args: []interface{}{int64(10)},
+		},
+		{
+			name: "a comment that isn't closed",
+			sql:  "SELECT :library /* :min",
+			want: "SELECT ? /* :min",
+			args: []interface{}{"X"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &namedQuery{Name: "q", Params: params, SQL: tt.sql}
+			got, bound, err := q.bind(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("sql:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if !reflect.DeepEqual(bound, tt.args) {
+				t.Errorf("args %#v, want %#v", bound, tt.args)
+			}
+		})
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	params := []named.Param{{Name: "limit", Type: "int"}}
+	tests := []struct {
+		sql  string
+		args map[string]string
+		err  string
+	}{
+		{"SELECT 1 LIMIT :limit", map[string]string{"limit": "5; DROP TABLE patrons"}, "limit has to be a whole number"},
+		{"SELECT 1 LIMIT :limit", map[string]string{}, "missing limit (int)"},
+		{"SELECT 1 LIMIT :limit", map[string]string{"limit": "5", "offset": "1"}, "unknown parameter offset"},
+		{"SELECT 1 LIMIT :limit OFFSET :offset", map[string]string{"limit": "5"}, "uses :offset but doesn't declare it"},
+	}
+	for _, tt := range tests {
+		q := &namedQuery{Name: "q", Params: params, SQL: tt.sql}
+		_, _, err := q.bind(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("bind(%q, %v): %v, want %q", tt.sql, tt.args, err, tt.err)
+		}
+	}
+}
+
+func TestLoadNamedQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "named.sql")
+	file := `-- saved queries
+
+-- name: by_library
+-- patrons of one library
+-- param: library string
+-- param: limit int 10
+SELECT * FROM patrons
+WHERE home_library_code = :library
+LIMIT :limit;
+
+-- name: everyone
+SELECT COUNT(*) FROM patrons;
+`
+	if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	queries, err := loadNamedQueries(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("got %d queries, want 2", len(queries))
+	}
+	q := queries[0]
+	if q.Name != "by_library" || q.Description != "patrons of one library" || len(q.Params) != 2 || *q.Params[1].Default != "10" {
+		t.Errorf("first query is %+v", q)
+	}
+	if want := "SELECT * FROM patrons\nWHERE home_library_code = :library\nLIMIT :limit"; q.SQL != want {
+		t.Errorf("sql %q, want %q", q.SQL, want)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.sql")
+	os.WriteFile(bad, []byte("-- name: q\n-- param: limit int ten\nSELECT 1"), 0o644)
+	if _, err := loadNamedQueries(bad); err == nil || !strings.Contains(err.Error(), "limit has to be a whole number") {
+		t.Errorf("a bad default should fail to load, got %v", err)
+	}
+
+	// the saved queries that ship with the app load and bind with their defaults
+	shipped, err := loadNamedQueries("../queries/named.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	examples := map[string]string{"int": "1", "float": "1.5", "bool": "true", "string": "x"}
+	for _, q := range shipped {
+		args := map[string]string{}
+		for _, p := range q.Params {
+			if p.Default == nil {
+				args[p.Name] = examples[p.Type]
+			}
+		}
+		if _, _, err := q.bind(args); err != nil {
+			t.Errorf("%s: %v", q.Name, err)
+		}
+	}
+}
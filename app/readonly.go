@@ -0,0 +1,127 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// statements that only read data. these are the only ones the text
+// interface runs unless it was started with --allow-writes.
+var readStatements = map[string]bool{
+	"SELECT":   true,
+	"SHOW":     true,
+	"DESCRIBE": true,
+	"DESC":     true,
+	"EXPLAIN":  true,
+	"TABLE":    true,
+	"VALUES":   true,
+}
+
+// statements that throw data away, these ask before running even with --allow-writes
+var destructiveStatements = map[string]bool{
+	"DROP":     true,
+	"TRUNCATE": true,
+	"DELETE":   true,
+	"UPDATE":   true,
+	"ALTER":    true,
+	"RENAME":   true,
+}
+
+// sqlWord is a bare word from a statement along with how many brackets deep it was
+type sqlWord struct {
+	text  string
+	depth int
+}
+
+// splits a statement into upper case words, skipping strings, quoted
+// identifiers and comments. mysql runs the inside of /*! ... */ comments so
+// those are read like normal sql.
+func sqlWords(query string) []sqlWord {
+	var words []sqlWord
+	depth := 0
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			// skip to the closing quote. backslash escapes and doubled quotes both count
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' && r != '`' {
+					i++
+				} else if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case r == '#' || (r == '-' && i+1 < len(runes) && runes[i+1] == '-'):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			if i+2 < len(runes) && runes[i+2] == '!' {
+				// executable comment, skip the /*! and the version number
+				i += 2
+				for i+1 < len(runes) && unicode.IsDigit(runes[i+1]) {
+					i++
+				}
+				continue
+			}
+			// plain comment, skip to the */. the */ that closes an executable
+			// comment isn't a word so it gets ignored anyway
+			for i += 2; i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+			}
+			i++
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_' || runes[i+1] == '$') {
+				i++
+			}
+			words = append(words, sqlWord{text: strings.ToUpper(string(runes[start : i+1])), depth: depth})
+		}
+	}
+	return words
+}
+
+// works out what a statement does from its first keyword. for WITH it's
+// the statement after the common table expressions that matters.
+func statementKind(query string) string {
+	words := sqlWords(query)
+	if len(words) == 0 {
+		return ""
+	}
+	if words[0].text != "WITH" {
+		return words[0].text
+	}
+	for _, w := range words[1:] {
+		if w.depth != words[0].depth {
+			continue
+		}
+		switch w.text {
+		case "SELECT", "TABLE", "VALUES", "UPDATE", "DELETE", "INSERT", "REPLACE":
+			return w.text
+		}
+	}
+	return "WITH"
+}
+
+// whether a statement is safe to run in read-only mode. SELECT ... INTO
+// OUTFILE writes to the server's disk so that gets turned away as well.
+func isReadStatement(query string) bool {
+	if !readStatements[statementKind(query)] {
+		return false
+	}
+	for _, w := range sqlWords(query) {
+		if w.text == "OUTFILE" || w.text == "DUMPFILE" {
+			return false
+		}
+	}
+	return true
+}
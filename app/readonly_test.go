@@ -0,0 +1,94 @@
+package main
+
+import "testing"
+
+func TestStatementKind(t *testing.T) {
+	tests := []struct {
+		query string
+		kind  string
+	}{
+		{"", ""},
+		{"   \n\t", ""},
+		{"-- nothing but a comment", ""},
+		{"select * from patrons", "SELECT"},
+		{"  Delete FROM patrons", "DELETE"},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", "SELECT"},
+		{"WITH x AS (SELECT 1) UPDATE patrons SET checkout_total = 0", "UPDATE"},
+		{"WITH x AS (SELECT id FROM patrons) DELETE FROM patrons WHERE id IN (SELECT id FROM x)", "DELETE"},
+		{"WITH RECURSIVE n AS (SELECT 1 UNION ALL SELECT n + 1 FROM n WHERE n < 5) SELECT * FROM n", "SELECT"},
+		{"WITH a AS (SELECT 1), b AS (SELECT 2) INSERT INTO t SELECT * FROM a", "INSERT"},
+		{"/*!50000 DELETE */ FROM patrons", "DELETE"},
+		{"/*!DROP*/ TABLE patrons", "DROP"},
+		{"/* DELETE */ SELECT 1", "SELECT"},
+		{"-- DELETE\nSELECT 1", "SELECT"},
+		{"# DROP TABLE patrons\nSELECT 1", "SELECT"},
+		{"-- SELECT\nDELETE FROM patrons", "DELETE"},
+		{"/* SELECT */ DROP TABLE patrons", "DROP"},
+	}
+	for _, tt := range tests {
+		if got := statementKind(tt.query); got != tt.kind {
+			t.Errorf("statementKind(%q) = %q, want %q", tt.query, got, tt.kind)
+		}
+	}
+}
+
+func TestIsReadStatement(t *testing.T) {
+	tests := []struct {
+		query string
+		read  bool
+	}{
+		// what read-only mode is there for
+		{"SELECT * FROM patrons", true},
+		{"SHOW TABLES", true},
+		{"DESCRIBE patrons", true},
+		{"desc patrons", true},
+		{"EXPLAIN SELECT * FROM patrons", true},
+		{"TABLE patrons", true},
+		{"VALUES ROW(1, 2)", true},
+		{"(SELECT 1) UNION (SELECT 2)", true},
+		{"", false},
+		{"   ", false},
+		{"-- only a comment", false},
+
+		// writes
+		{"DELETE FROM patrons", false},
+		{"INSERT INTO patrons VALUES (1)", false},
+		{"DROP TABLE patrons", false},
+		{"SET autocommit = 0", false},
+		{"CALL wipe()", false},
+		{"WITH x AS (SELECT 1) UPDATE patrons SET checkout_total = 0", false},
+		{"WITH x AS (SELECT id FROM patrons) DELETE FROM patrons WHERE id IN (SELECT id FROM x)", false},
+
+		// comments
+		{"/*!50000 DELETE */ FROM patrons", false},
+		{"/*! DELETE */ FROM patrons", false},
+		{"/* DELETE */ SELECT 1", true},
+		{"SELECT 1 /* INTO OUTFILE '/tmp/x' */", true},
+		{"SELECT 1 -- INTO OUTFILE '/tmp/x'", true},
+		{"SELECT 1 # INTO DUMPFILE '/tmp/x'", true},
+		{"SELECT 1 /*!50000 INTO OUTFILE '/tmp/x' */", false},
+
+		// writing files
+		{"SELECT * FROM patrons INTO OUTFILE '/tmp/patrons.csv'", false},
+		{"SELECT * INTO outfile '/tmp/patrons.csv' FROM patrons", false},
+		{"SELECT email FROM patrons LIMIT 1 INTO DUMPFILE '/tmp/x'", false},
+		{"WITH x AS (SELECT 1) SELECT * FROM x INTO OUTFILE '/tmp/x'", false},
+
+		// keywords inside strings and quoted names
+		{"SELECT 'DROP TABLE patrons'", true},
+		{"SELECT \"INTO OUTFILE\" AS note", true},
+		{"SELECT `outfile` FROM t", true},
+		{"SELECT `DELETE` FROM t", true},
+		{"SELECT 'it''s INTO OUTFILE' FROM t", true},
+		{"SELECT \"say \"\"INTO DUMPFILE\"\"\" FROM t", true},
+		{`SELECT 'it\'s INTO OUTFILE' FROM t`, true},
+		{`SELECT 'ends in a backslash\\' INTO OUTFILE '/tmp/x'`, false},
+		{"SELECT `a``b` INTO OUTFILE '/tmp/x'", false},
+		{"SELECT 'never closed INTO OUTFILE", true},
+	}
+	for _, tt := range tests {
+		if got := isReadStatement(tt.query); got != tt.read {
+			t.Errorf("isReadStatement(%q) = %v, want %v", tt.query, got, tt.read)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/interrupt"
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"github.com/jacksongodsey/SFILS/shared/report"
+)
+
+// runs the query for a report and collects its rows instead of printing them
+func (s *session) reportRows(q *dsl.Query) ([][]output.Value, error) {
+	ctx, done := s.running.Start(s.timeout)
+	defer done()
+	conn, release, err := s.pinConnection(ctx)
+	if err != nil {
+		return nil, errors.New(interrupt.Error(ctx, err))
+	}
+	defer release()
+
+	query, args := q.SQL()
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.New(interrupt.Error(ctx, err))
+	}
+	defer rows.Close()
+
+	cols, err := resultColumns(rows)
+	if err != nil {
+		return nil, fmt.Errorf("error getting columns: %v", err)
+	}
+	values := make([]interface{}, len(cols))
+	valuePtrs := make([]interface{}, len(cols))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	var result [][]output.Value
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		vals := make([]output.Value, len(values))
+		for i, val := range values {
+			vals[i] = toValue(val, cols[i])
+		}
+		result = append(result, vals)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New(interrupt.Error(ctx, err))
+	}
+	return result, nil
+}
+
+// runs the named reports, or all of them, and writes them out
+func (s *session) runReports(names []string, format string) error {
+	reports, err := report.Select(names)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	var results []*report.Result
+	for _, r := range reports {
+		res, err := report.Run(r, s.reportRows)
+		if err != nil {
+			return err
+		}
+		results = append(results, res)
+	}
+	if err := report.Write(s.display.Out(), format, results); err != nil {
+		return fmt.Errorf("error writing results: %v", err)
+	}
+	s.printTiming(time.Since(start))
+	return nil
+}
+
+// :report [NAME ...] in the text interface. json and markdown are kept from
+// \format, anything else is shown as tables.
+func (s *session) reportCommand(names []string) {
+	format := s.display.Format
+	if !report.IsFormat(format) {
+		format = "table"
+	}
+	if err := s.runReports(names, format); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// the report command: runs the built-in reports and prints them to stdout
+//
+//	go run . report
+//	go run . report -format markdown libraries ages > reports.md
+func runReportCommand(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	format := flags.String("format", "table", "output format ("+strings.Join(report.Formats, ", ")+")")
+	timeout := flags.Duration("timeout", interrupt.DefaultTimeout, "stop each report's query after this long, 0 means never")
+	timing := flags.Bool("timing", false, "print how long the reports took")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . report [flags] [NAME ...]")
+		fmt.Fprintln(flags.Output(), "reports:", strings.Join(report.Names(), ", "))
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if !report.IsFormat(*format) {
+		return fmt.Errorf("unknown format %q, use one of %s", *format, strings.Join(report.Formats, ", "))
+	}
+
+	s := &session{
+		db:      db,
+		display: output.NewSettings(),
+		timeout: *timeout,
+		timing:  *timing,
+		status:  os.Stderr,
+	}
+	return s.runReports(flags.Args(), *format)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// getting the column names and working out which ones hold numbers
+func resultColumns(rows *sql.Rows) ([]output.Column, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	cols := make([]output.Column, len(types))
+	for i, t := range types {
+		cols[i] = output.Column{Name: t.Name(), Numeric: isNumericType(t.DatabaseTypeName())}
+	}
+	return cols, nil
+}
+
+// mysql type names that should be treated as numbers
+func isNumericType(typeName string) bool {
+	typeName = strings.TrimPrefix(strings.ToUpper(typeName), "UNSIGNED ")
+	switch typeName {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT",
+		"DECIMAL", "NUMERIC", "FLOAT", "DOUBLE", "REAL", "YEAR", "BIT":
+		return true
+	}
+	return false
+}
+
+// turning a scanned value into something the output writers understand.
+// the driver hands most text back as []byte, so the column type decides
+// whether it's a number or a string.
+func toValue(val interface{}, col output.Column) output.Value {
+	switch v := val.(type) {
+	case nil:
+		return output.Value{Kind: output.Null}
+	case []byte:
+		if col.Numeric {
+			return output.Value{Kind: output.Number, Text: string(v)}
+		}
+		return output.Value{Kind: output.String, Text: string(v)}
+	case int64:
+		return output.Value{Kind: output.Number, Text: strconv.FormatInt(v, 10)}
+	case uint64:
+		return output.Value{Kind: output.Number, Text: strconv.FormatUint(v, 10)}
+	case float32:
+		return output.Value{Kind: output.Number, Text: strconv.FormatFloat(float64(v), 'g', -1, 32)}
+	case float64:
+		return output.Value{Kind: output.Number, Text: strconv.FormatFloat(v, 'g', -1, 64)}
+	case bool:
+		return output.Value{Kind: output.Bool, Text: strconv.FormatBool(v)}
+	case time.Time:
+		return output.Value{Kind: output.String, Text: v.Format(time.RFC3339)}
+	case string:
+		return output.Value{Kind: output.String, Text: v}
+	default:
+		return output.Value{Kind: output.String, Text: fmt.Sprint(v)}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// \dt - every table in the database with an exact row count
+func (s *session) listTables() {
+	rows, err := s.db.Query(`
+		SELECT TABLE_NAME FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'
+		ORDER BY TABLE_NAME`, dbName)
+	if err != nil {
+		fmt.Println("query error:", err)
+		return
+	}
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			fmt.Println("error scanning row:", err)
+			continue
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	// information_schema only has an estimate of the row count so we count
+	// them properly. there are only a handful of tables so it's quick enough.
+	var values [][]output.Value
+	for _, table := range tables {
+		var count int64
+		err := s.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM `%s`.`%s`", dbName, table)).Scan(&count)
+		if err != nil {
+			fmt.Printf("couldn't count %s: %v\n", table, err)
+			continue
+		}
+		values = append(values, []output.Value{
+			{Kind: output.String, Text: table},
+			{Kind: output.Number, Text: strconv.FormatInt(count, 10)},
+		})
+	}
+
+	s.writeValues([]output.Column{{Name: "table"}, {Name: "rows", Numeric: true}}, values)
+}
+
+// \d table - columns with their types, nullability and foreign keys
+func (s *session) describeTable(table string) {
+	var exists int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?`, dbName, table).Scan(&exists)
+	if err != nil {
+		fmt.Println("query error:", err)
+		return
+	}
+	if exists == 0 {
+		fmt.Printf("no table called %q, try \\dt to see them all\n", table)
+		return
+	}
+
+	err = s.runQuery(`
+		SELECT c.COLUMN_NAME AS 'column',
+			c.COLUMN_TYPE AS 'type',
+			c.IS_NULLABLE AS 'nullable',
+			c.COLUMN_DEFAULT AS 'default',
+			c.COLUMN_KEY AS 'key',
+			CONCAT(k.REFERENCED_TABLE_NAME, '(', k.REFERENCED_COLUMN_NAME, ')') AS 'references'
+		FROM information_schema.COLUMNS c
+		LEFT JOIN information_schema.KEY_COLUMN_USAGE k
+			ON k.TABLE_SCHEMA = c.TABLE_SCHEMA
+			AND k.TABLE_NAME = c.TABLE_NAME
+			AND k.COLUMN_NAME = c.COLUMN_NAME
+			AND k.REFERENCED_TABLE_NAME IS NOT NULL
+		WHERE c.TABLE_SCHEMA = ? AND c.TABLE_NAME = ?
+		ORDER BY c.ORDINAL_POSITION`, dbName, table)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// \di [table] - indexes, for one table or all of them
+func (s *session) listIndexes(table string) {
+	query := `
+		SELECT TABLE_NAME AS 'table',
+			INDEX_NAME AS 'index',
+			GROUP_CONCAT(COLUMN_NAME ORDER BY SEQ_IN_INDEX SEPARATOR ', ') AS 'columns',
+			IF(NON_UNIQUE = 0, 'yes', 'no') AS 'unique',
+			INDEX_TYPE AS 'type'
+		FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = ?`
+	args := []interface{}{dbName}
+	if table != "" {
+		query += " AND TABLE_NAME = ?"
+		args = append(args, table)
+	}
+	query += `
+		GROUP BY TABLE_NAME, INDEX_NAME, NON_UNIQUE, INDEX_TYPE
+		ORDER BY TABLE_NAME, INDEX_NAME`
+
+	if err := s.runQuery(query, args...); err != nil {
+		fmt.Println(err)
+	}
+}
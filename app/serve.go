@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jacksongodsey/SFILS/shared/api"
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"github.com/jacksongodsey/SFILS/shared/rpc"
+)
+
+// the serve command: the patron data as a json api for other services, see
+// the api package for the endpoints
+//
+//	go run . serve -addr :8080
+func runServeCommand(db *sql.DB, args []string) error {
+	return api.Command(apiBackend(db), args)
+}
+
+// the grpc command: the patron data over grpc for the data-sync jobs, see
+// the rpc package
+//
+//	go run . grpc -addr :9090
+func runGRPCCommand(db *sql.DB, args []string) error {
+	return rpc.Command(rpc.Backend{
+		Backend: apiBackend(db),
+		Import: func(rows [][]string) (int, int, error) {
+			return importRows(db, rows)
+		},
+	}, args)
+}
+
+// how the api and the grpc server read the database
+func apiBackend(db *sql.DB) api.Backend {
+	return api.Backend{
+		Name: "mysql",
+		Query: func(ctx context.Context, q *dsl.Query) ([][]output.Value, error) {
+			query, args := q.SQL()
+			return queryValues(ctx, db, query, args...)
+		},
+		Patrons: func(ctx context.Context, where dsl.Expr, after string, limit int) ([]api.Patron, error) {
+			return listPatrons(ctx, db, where, after, limit)
+		},
+		Lookup: func(ctx context.Context, table string, codes []string) (map[string]string, error) {
+			return lookupNames(ctx, db, table, codes)
+		},
+	}
+}
+
+// the column with the name in each lookup table
+var lookupColumns = map[string]string{
+	"libraries":          "name",
+	"patron_types":       "description",
+	"notification_types": "description",
+}
+
+// the names of codes in a lookup table for graphql, the whole table when codes is nil
+func lookupNames(ctx context.Context, db *sql.DB, table string, codes []string) (map[string]string, error) {
+	column, ok := lookupColumns[table]
+	if !ok {
+		return nil, fmt.Errorf("%s isn't a lookup table", table)
+	}
+	query := "SELECT code, " + column + " FROM " + table
+	var args []interface{}
+	if codes != nil {
+		if len(codes) == 0 {
+			return map[string]string{}, nil
+		}
+		query += " WHERE code IN (?" + strings.Repeat(", ?", len(codes)-1) + ")"
+		for _, c := range codes {
+			args = append(args, c)
+		}
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := map[string]string{}
+	for rows.Next() {
+		var code, name string
+		if err := rows.Scan(&code, &name); err != nil {
+			return nil, err
+		}
+		names[code] = name
+	}
+	return names, rows.Err()
+}
+
+// a page of patrons for /patrons, the ids are the primary key so the next
+// page starts right where the index says this one stopped
+func listPatrons(ctx context.Context, db *sql.DB, where dsl.Expr, after string, limit int) ([]api.Patron, error) {
+	var afterID int64
+	if after != "" {
+		var err error
+		if afterID, err = strconv.ParseInt(after, 10, 64); err != nil {
+			return nil, api.ErrBadCursor
+		}
+	}
+
+	cond, args := "p.id > ?", []interface{}{afterID}
+	if where != nil {
+		filter, filterArgs := dsl.FilterSQL(where)
+		cond += " AND " + filter
+		args = append(args, filterArgs...)
+	}
+	args = append(args, limit)
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, pt.description, pt.code, p.age_range, l.name, p.home_library_code,
+			p.checkout_total, p.renewal_total, p.active_month, p.active_year,
+			nt.description, p.notification_type_code, p.email, p.within_sfc, p.year_registered
+		FROM patrons p
+		`+dsl.Joins()+`
+		WHERE `+cond+`
+		ORDER BY p.id
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var patrons []api.Patron
+	for rows.Next() {
+		var p api.Patron
+		var id int64
+		var checkouts, renewals, month, year, registered sql.NullInt64
+		var email sql.NullString
+		err := rows.Scan(&id, &p.PatronType, &p.PatronTypeCode, &p.AgeRange, &p.HomeLibrary, &p.HomeLibraryCode,
+			&checkouts, &renewals, &month, &year,
+			&p.Notification, &p.NotificationCode, &email, &p.WithinSFC, &registered)
+		if err != nil {
+			return nil, err
+		}
+		p.ID = strconv.FormatInt(id, 10)
+		p.CheckoutTotal = nullInt(checkouts)
+		p.RenewalTotal = nullInt(renewals)
+		p.ActiveMonth = nullInt(month)
+		p.ActiveYear = nullInt(year)
+		p.YearRegistered = nullInt(registered)
+		if email.Valid {
+			p.Email = &email.String
+		}
+		patrons = append(patrons, p)
+	}
+	return patrons, rows.Err()
+}
+
+func nullInt(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
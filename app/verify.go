@@ -0,0 +1,55 @@
+package main
+
+import (
+	"database/sql"
+
+	"github.com/jacksongodsey/SFILS/shared/bench"
+	"github.com/jacksongodsey/SFILS/shared/verify"
+)
+
+// every patron with the descriptions and names joined back in, so the rows
+// look like the documents in mongodb. left joins so a patron whose code is
+// missing from a lookup table still shows up, with empty values.
+const verifyQuery = `
+	SELECT pt.code, pt.description, p.checkout_total, p.renewal_total,
+		p.age_range, p.home_library_code, l.name, p.active_month,
+		p.active_year, p.notification_type_code, nt.description,
+		p.email, p.within_sfc, p.year_registered
+	FROM patrons p
+	LEFT JOIN patron_types pt ON pt.id = p.patron_type_id
+	LEFT JOIN libraries l ON l.code = p.home_library_code
+	LEFT JOIN notification_types nt ON nt.code = p.notification_type_code`
+
+// the verify command: reads every patron back out of mysql and saves a
+// snapshot to compare with the one from mongodb
+//
+//	go run . verify -against ../results/verify-mongodb.json
+func runVerifyCommand(db *sql.DB, args []string) error {
+	return verify.Command("mysql", bench.Checksum(workbookFile), "../results/verify-mysql.json", args, func(add func([]string) error) error {
+		rows, err := db.Query(verifyQuery)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		values := make([]sql.NullString, len(verify.Fields))
+		valuePtrs := make([]interface{}, len(values))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		for rows.Next() {
+			if err := rows.Scan(valuePtrs...); err != nil {
+				return err
+			}
+			// NULL comes out as "", the same as a missing field in mongodb
+			row := make([]string, len(values))
+			for i, v := range values {
+				row[i] = v.String
+			}
+			if err := add(row); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+}
@@ -0,0 +1,17 @@
+#!/bin/sh
+# imports the workbook into mysql and mongodb, benchmarks both and writes
+# them side by side to results/comparison.md and results/comparison.html,
+# then checks both hold the same data with verify.
+# anything after the script name goes to both benchmarks, like -runs 50.
+set -e
+cd "$(dirname "$0")"
+
+(cd app && go run . bench -import "$@")
+(cd mongo/app && go run . bench -import "$@")
+
+(cd app && go run . bench report -out ../results/comparison.md ../results)
+(cd app && go run . bench report -out ../results/comparison.html ../results)
+
+# last so the report is written even when the data doesn't match
+(cd app && go run . verify)
+(cd mongo/app && go run . verify -against ../../results/verify-mysql.json)
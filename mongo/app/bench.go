@@ -0,0 +1,669 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jacksongodsey/SFILS/shared/bench"
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// benchmark to test performance. every query gets warmup runs first and is
+// then timed over several runs, one run on its own mostly shows what was cached.
+func runBenchmark(db *mongo.Database, suite *bench.Suite, opts bench.Options) []bench.Result {
+	fmt.Printf("\n=== performance test: %s (%d warmup, %d measured runs per query) ===\n", suite.Name, opts.Warmup, opts.Runs)
+	if opts.Flush {
+		fmt.Println("clearing the plan cache before every run. the wiredtiger cache stays warm, only a restart empties it")
+	}
+	ctx := context.Background()
+
+	// the plan cache is per collection, so flush clears it on the one the
+	// query that's about to run uses
+	var collection string
+	flush := func() error {
+		return db.RunCommand(ctx, bson.D{{Key: "planCacheClear", Value: collection}}).Err()
+	}
+	results := suite.Run(opts, flush, func(sq bench.SuiteQuery) (func() (int, error), error) {
+		run, q, err := prepareQuery(ctx, db, sq)
+		if q != nil {
+			collection = q.Collection
+		}
+		return run, err
+	})
+	if err := bench.WriteTable(os.Stdout, results); err != nil {
+		fmt.Println("error writing results:", err)
+	}
+
+	fmt.Println("\nbenchmark done")
+	return results
+}
+
+// turns a suite query into a function that runs it. the shell query comes
+// back too so the caller knows which collection it uses.
+func prepareQuery(ctx context.Context, db *mongo.Database, sq bench.SuiteQuery) (func() (int, error), *shellQuery, error) {
+	q, err := benchmarkQuery(sq)
+	if q == nil || err != nil {
+		return nil, nil, err
+	}
+	return func() (int, error) {
+		return countShell(ctx, db, q)
+	}, q, nil
+}
+
+// the query a suite entry runs on mongodb, nil when it only has sql
+func benchmarkQuery(sq bench.SuiteQuery) (*shellQuery, error) {
+	if sq.MongoDB == "" {
+		if sq.Query == "" {
+			return nil, nil
+		}
+		q, err := dsl.Parse(sq.Query)
+		if err != nil {
+			return nil, err
+		}
+		pipeline, err := pipelineOf(q)
+		if err != nil {
+			return nil, err
+		}
+		return &shellQuery{Collection: "patrons", Method: "aggregate", Pipeline: pipeline}, nil
+	}
+
+	q, err := parseShell(sq.MongoDB)
+	if err != nil {
+		return nil, err
+	}
+	if stage := writeStage(q.Pipeline); stage != "" {
+		return nil, fmt.Errorf("%s writes to a collection, only queries that read can be benchmarked", stage)
+	}
+	if q.Explain {
+		return nil, fmt.Errorf("explain can't be benchmarked, take it off the query")
+	}
+	return q, nil
+}
+
+// runs a query and counts what it sends back. counts come back as one row
+// like they do from mysql, and distinct as one row per value.
+func countShell(ctx context.Context, db *mongo.Database, q *shellQuery) (int, error) {
+	coll := db.Collection(q.Collection)
+	var cursor *mongo.Cursor
+	var err error
+	switch q.Method {
+	case "find", "findOne":
+		opts := options.Find().SetSkip(q.Skip)
+		if q.Projection != nil {
+			opts.SetProjection(q.Projection)
+		}
+		if q.Sort != nil {
+			opts.SetSort(q.Sort)
+		}
+		switch {
+		case q.Limit < 0:
+			opts.SetLimit(defaultFindLimit)
+		case q.Limit > 0:
+			opts.SetLimit(q.Limit)
+		}
+		cursor, err = coll.Find(ctx, q.Filter, opts)
+	case "aggregate":
+		cursor, err = coll.Aggregate(ctx, q.Pipeline)
+	case "countDocuments":
+		_, err = coll.CountDocuments(ctx, q.Filter)
+		return 1, err
+	case "estimatedDocumentCount":
+		_, err = coll.EstimatedDocumentCount(ctx)
+		return 1, err
+	case "distinct":
+		values, err := coll.Distinct(ctx, q.Field, q.Filter)
+		return len(values), err
+	default:
+		return 0, fmt.Errorf("%s can't be benchmarked", q.Method)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+	count := 0
+	for cursor.Next(ctx) {
+		count++
+	}
+	return count, cursor.Err()
+}
+
+// runs every query that worked once more and keeps a fingerprint of what it
+// returned, so bench report can tell whether mysql got the same answers
+func checkResults(db *mongo.Database, suite *bench.Suite, results []bench.Result) {
+	ctx := context.Background()
+	for i, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		for _, sq := range suite.Queries {
+			if sq.Name != r.Name {
+				continue
+			}
+			q, err := benchmarkQuery(sq)
+			if q == nil || err != nil {
+				continue
+			}
+			rows, err := shellValues(ctx, db, q)
+			if err != nil {
+				fmt.Printf("couldn't check the result of %s: %v\n", r.Name, err)
+				continue
+			}
+			results[i].Checksum = bench.Fingerprint(rows)
+		}
+	}
+}
+
+// runs a shell query and keeps what it returns as rows, the values of every
+// document in field order. counts are one row with the count, like in mysql.
+func shellValues(ctx context.Context, db *mongo.Database, q *shellQuery) ([][]output.Value, error) {
+	coll := db.Collection(q.Collection)
+	var cursor *mongo.Cursor
+	var err error
+	switch q.Method {
+	case "find", "findOne":
+		opts := options.Find().SetSkip(q.Skip)
+		if q.Projection != nil {
+			opts.SetProjection(q.Projection)
+		}
+		if q.Sort != nil {
+			opts.SetSort(q.Sort)
+		}
+		switch {
+		case q.Limit < 0:
+			opts.SetLimit(defaultFindLimit)
+		case q.Limit > 0:
+			opts.SetLimit(q.Limit)
+		}
+		cursor, err = coll.Find(ctx, q.Filter, opts)
+	case "aggregate":
+		cursor, err = coll.Aggregate(ctx, q.Pipeline)
+	case "countDocuments", "estimatedDocumentCount":
+		var n int64
+		if q.Method == "countDocuments" {
+			n, err = coll.CountDocuments(ctx, q.Filter)
+		} else {
+			n, err = coll.EstimatedDocumentCount(ctx)
+		}
+		return [][]output.Value{{bsonValue(n)}}, err
+	case "distinct":
+		values, err := coll.Distinct(ctx, q.Field, q.Filter)
+		rows := make([][]output.Value, len(values))
+		for i, v := range values {
+			rows[i] = []output.Value{bsonValue(v)}
+		}
+		return rows, err
+	default:
+		return nil, fmt.Errorf("%s can't be benchmarked", q.Method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []bson.D
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	rows := make([][]output.Value, len(docs))
+	for i, doc := range docs {
+		for _, e := range doc {
+			rows[i] = append(rows[i], bsonValue(e.Value))
+		}
+	}
+	return rows, nil
+}
+
+// the bench command: runs the benchmark without importing the data again and
+// saves the results to compare with later runs using bench compare
+//
+//	go run . bench -runs 50 -warmup 5
+func runBenchCommand(db *mongo.Database, args []string) error {
+	if len(args) > 0 && args[0] == "load" {
+		return runLoadCommand(db, args[1:])
+	}
+	if len(args) > 0 && args[0] == "import" {
+		return runImportBenchCommand(db, args[1:])
+	}
+	if len(args) > 0 && args[0] == "indexes" {
+		return runIndexCommand(db, args[1:])
+	}
+
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	warmup := flags.Int("warmup", bench.DefaultOptions.Warmup, "runs of each query before timing starts")
+	runs := flags.Int("runs", bench.DefaultOptions.Runs, "timed runs of each query")
+	flush := flags.Bool("flush", false, "clear the query plan cache before every timed run")
+	save := flags.Bool("save", true, "save the results as json and csv")
+	dir := flags.String("out", "../../results", "folder the results are saved in")
+	reimport := flags.Bool("import", false, "import the workbook again first")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . bench [flags]")
+		fmt.Fprintln(flags.Output(), "       go run . bench load [-clients N] [-duration D | -requests N] [-mix NAME=WEIGHT,...]")
+		fmt.Fprintln(flags.Output(), "       go run . bench import [-strategies LIST] [-batch N] [-workers N] [-cpuprofile FILE] [-memprofile FILE]")
+		fmt.Fprintln(flags.Output(), "       go run . bench indexes [-runs N] [-warmup N] [-min-speedup X]")
+		fmt.Fprintln(flags.Output(), "       go run . bench compare [-threshold PERCENT] OLD.json NEW.json")
+		fmt.Fprintln(flags.Output(), "       go run . bench report [-format markdown|html] [-out FILE] RUN.json|FOLDER ...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *runs < 1 || *warmup < 0 {
+		return fmt.Errorf("-runs has to be at least 1 and -warmup can't be negative")
+	}
+
+	if *reimport {
+		if err := importExcel(db, workbookFile); err != nil {
+			return err
+		}
+	}
+
+	suite, err := bench.LoadSuite(*suiteFile)
+	if err != nil {
+		return err
+	}
+	opts := bench.Options{Warmup: *warmup, Runs: *runs, Flush: *flush}
+	results := runBenchmark(db, suite, opts)
+	failed := bench.Failed(results)
+
+	if *save {
+		checkResults(db, suite, results)
+		if err := saveBenchmark(db, suite, opts, results, *dir); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of the %s benchmark queries failed", failed, suite.Name)
+	}
+	return nil
+}
+
+// tags the results with the mongodb version and the workbook and saves them
+func saveBenchmark(db *mongo.Database, suite *bench.Suite, opts bench.Options, results []bench.Result, dir string) error {
+	var info struct {
+		Version string `bson:"version"`
+	}
+	err := db.RunCommand(context.Background(), bson.D{{Key: "buildInfo", Value: 1}}).Decode(&info)
+	if err != nil {
+		fmt.Println("couldn't get the mongodb version:", err)
+	}
+	run := bench.NewRun("mongodb", suite.Name, bench.Checksum(workbookFile), "mongodb "+info.Version, opts, results)
+	run.Storage, err = storageSizes(db)
+	if err != nil {
+		fmt.Println("couldn't get the collection sizes:", err)
+	}
+	files, err := run.Save(dir)
+	if err != nil {
+		return fmt.Errorf("couldn't save the results: %v", err)
+	}
+	fmt.Println("results saved to", strings.Join(files, " and "))
+	return nil
+}
+
+// how much space every collection and index takes, from $collStats. data is
+// the compressed size on disk so it lines up with mysql's.
+func storageSizes(db *mongo.Database) ([]bench.Table, error) {
+	ctx := context.Background()
+	names, err := db.ListCollectionNames(ctx, bson.D{{Key: "type", Value: "collection"}})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var tables []bench.Table
+	for _, name := range names {
+		if strings.HasPrefix(name, "system.") {
+			continue
+		}
+		cursor, err := db.Collection(name).Aggregate(ctx, bson.A{
+			bson.D{{Key: "$collStats", Value: bson.D{{Key: "storageStats", Value: bson.D{}}}}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var stats []struct {
+			StorageStats struct {
+				Count          int64            `bson:"count"`
+				StorageSize    int64            `bson:"storageSize"`
+				TotalIndexSize int64            `bson:"totalIndexSize"`
+				IndexSizes     map[string]int64 `bson:"indexSizes"`
+			} `bson:"storageStats"`
+		}
+		if err := cursor.All(ctx, &stats); err != nil {
+			return nil, err
+		}
+		if len(stats) == 0 {
+			continue
+		}
+
+		s := stats[0].StorageStats
+		t := bench.Table{Name: name, Rows: s.Count, DataBytes: s.StorageSize, IndexBytes: s.TotalIndexSize}
+		for index, size := range s.IndexSizes {
+			t.Indexes = append(t.Indexes, bench.Index{Name: index, Bytes: size})
+		}
+		sort.Slice(t.Indexes, func(i, j int) bool { return t.Indexes[i].Name < t.Indexes[j].Name })
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// bench import: imports the workbook once per write strategy and times each
+// step on its own. the collections are dropped before every strategy, so
+// this replaces whatever is in the database, and the last strategy's
+// import is what's left afterwards.
+//
+//	go run . bench import -strategies batched,concurrent -batch 2000 -workers 8
+func runImportBenchCommand(db *mongo.Database, args []string) error {
+	flags := flag.NewFlagSet("bench import", flag.ExitOnError)
+	list := flags.String("strategies", "all", "comma separated write strategies to compare: single, batched, concurrent or all")
+	batch := flags.Int("batch", 1000, "documents per InsertMany for batched and concurrent")
+	workers := flags.Int("workers", 4, "inserts running at once for concurrent")
+	cpuProfile := flags.String("cpuprofile", "", "write a cpu profile of the whole run to this file")
+	memProfile := flags.String("memprofile", "", "write a heap profile to this file at the end")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . bench import [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	strategies, err := bench.ParseStrategies(*list)
+	if err != nil {
+		return err
+	}
+	if *batch < 1 || *workers < 1 {
+		return fmt.Errorf("-batch and -workers have to be at least 1")
+	}
+
+	stop, err := bench.StartProfiles(*cpuProfile, *memProfile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n=== import benchmark: %s ===\n", strings.Join(strategies, ", "))
+	var rows [][]string
+	parse, err := bench.TimePhase("parse", func() (int, error) {
+		var err error
+		rows, err = readWorkbook(workbookFile)
+		return max(len(rows)-1, 0), err
+	})
+	if err != nil {
+		// the cpu profile would keep going and its file stay open otherwise
+		stop()
+		return err
+	}
+	var patrons []Patron
+	clean, _ := bench.TimePhase("clean", func() (int, error) {
+		patrons, _ = cleanRows(rows)
+		return len(patrons), nil
+	})
+	rows = nil
+
+	var results []bench.ImportResult
+	for _, name := range strategies {
+		fmt.Println("importing with", name)
+		results = append(results, importWith(db, patrons, writeStrategy{name: name, batch: *batch, workers: *workers}))
+	}
+
+	if err := stop(); err != nil {
+		return err
+	}
+	if *cpuProfile != "" || *memProfile != "" {
+		fmt.Println("profiles written, open them with go tool pprof")
+	}
+	fmt.Println()
+	if err := bench.WriteImport(os.Stdout, []bench.Phase{parse, clean}, results); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if r.Err != nil {
+			return fmt.Errorf("the %s import failed", r.Strategy)
+		}
+	}
+	return nil
+}
+
+// drops the collections and runs the lookups and the writes with one strategy
+func importWith(db *mongo.Database, patrons []Patron, strategy writeStrategy) bench.ImportResult {
+	res := bench.ImportResult{Strategy: strategy.name}
+	if res.Err = resetCollections(db); res.Err != nil {
+		return res
+	}
+
+	lookups, _ := bench.TimePhase("lookups", func() (int, error) {
+		patrons, _ = lookupDimensions(db, patrons)
+		return len(patrons), nil
+	})
+	res.Phases = append(res.Phases, lookups)
+
+	var bad int
+	writes, err := bench.TimePhase("writes", func() (int, error) {
+		good, failed, err := writePatrons(db, patrons, strategy)
+		bad = failed
+		return good, err
+	})
+	res.Phases = append(res.Phases, writes)
+	if err == nil && bad > 0 {
+		err = fmt.Errorf("%d documents weren't written", bad)
+	}
+	res.Err = err
+	return res
+}
+
+// bench load: runs the suite's queries from several clients at once, picked
+// at random by their weights, to see how the server and the connection pool
+// hold up under something like a dashboard full of users
+//
+//	go run . bench load -clients 50 -duration 30s -mix "count by library=5,find SF patrons=1"
+func runLoadCommand(db *mongo.Database, args []string) error {
+	flags := flag.NewFlagSet("bench load", flag.ExitOnError)
+	clients := flags.Int("clients", 10, "queries running at the same time")
+	duration := flags.Duration("duration", 10*time.Second, "how long to run for, 0 to go by -requests")
+	requests := flags.Int("requests", 0, "stop after this many queries instead of after -duration")
+	mix := flags.String("mix", "", "weights like NAME=WEIGHT,NAME=WEIGHT instead of the ones in the suite")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . bench load [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *requests > 0 {
+		*duration = 0
+	}
+
+	suite, err := bench.LoadSuite(*suiteFile)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	queries, err := suite.LoadQueries(func(sq bench.SuiteQuery) (func() (int, error), error) {
+		run, _, err := prepareQuery(ctx, db, sq)
+		return run, err
+	})
+	if err != nil {
+		return err
+	}
+	if *mix != "" {
+		if err := bench.ParseMix(*mix, queries); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\n=== load test: %s, %d clients ===\n", suite.Name, *clients)
+	before := pool.snapshot()
+	pool.peak.Store(pool.inUse.Load())
+	res, err := bench.RunLoad(queries, bench.LoadOptions{Clients: *clients, Duration: *duration, Requests: *requests})
+	if err != nil {
+		return err
+	}
+	after := pool.snapshot()
+	if err := bench.WriteLoad(os.Stdout, res); err != nil {
+		return err
+	}
+
+	fmt.Println("\nconnection pool:")
+	fmt.Printf("  open %d, at most %d in use at once\n", after.open, pool.peak.Load())
+	checkouts := after.checkouts - before.checkouts
+	waited := after.waited - before.waited
+	fmt.Printf("  %d checkouts took %v in total", checkouts, waited.Round(time.Millisecond))
+	if checkouts > 0 {
+		fmt.Printf(", %s ms on average", bench.Millis(waited/time.Duration(checkouts)))
+	}
+	fmt.Println()
+	fmt.Printf("  %d checkouts failed, %d connections opened\n", after.failed-before.failed, after.created-before.created)
+
+	if res.Errors > 0 {
+		return fmt.Errorf("%d of the %d queries failed", res.Errors, res.Requests)
+	}
+	return nil
+}
+
+// the driver has nothing like database/sql's db.Stats(), so main hooks this
+// up as the client's pool monitor and it counts what the pool does
+var pool poolStats
+
+type poolStats struct {
+	checkouts, failed, created, closed atomic.Int64
+	waited                             atomic.Int64 // nanoseconds spent getting a connection
+	inUse, peak                        atomic.Int64
+}
+
+type poolSnapshot struct {
+	checkouts, failed, created, open int64
+	waited                           time.Duration
+}
+
+func (p *poolStats) monitor() *event.PoolMonitor {
+	return &event.PoolMonitor{Event: func(e *event.PoolEvent) {
+		switch e.Type {
+		case event.GetSucceeded:
+			p.checkouts.Add(1)
+			p.waited.Add(int64(e.Duration))
+			n := p.inUse.Add(1)
+			for {
+				peak := p.peak.Load()
+				if n <= peak || p.peak.CompareAndSwap(peak, n) {
+					break
+				}
+			}
+		case event.ConnectionReturned:
+			p.inUse.Add(-1)
+		case event.GetFailed:
+			p.failed.Add(1)
+		case event.ConnectionCreated:
+			p.created.Add(1)
+		case event.ConnectionClosed:
+			p.closed.Add(1)
+		}
+	}}
+}
+
+func (p *poolStats) snapshot() poolSnapshot {
+	return poolSnapshot{
+		checkouts: p.checkouts.Load(),
+		failed:    p.failed.Load(),
+		created:   p.created.Load(),
+		open:      p.created.Load() - p.closed.Load(),
+		waited:    time.Duration(p.waited.Load()),
+	}
+}
+
+// bench indexes: runs the suite without any indexes on patrons but _id and
+// then with each candidate index in the suite file built on its own, and says
+// which ones are worth the space. the indexes createIndexes makes are
+// built again at the end, whatever the advice.
+//
+//	go run . bench indexes -runs 20 -min-speedup 2
+func runIndexCommand(db *mongo.Database, args []string) error {
+	flags := flag.NewFlagSet("bench indexes", flag.ExitOnError)
+	warmup := flags.Int("warmup", bench.DefaultOptions.Warmup, "runs of each query before timing starts")
+	runs := flags.Int("runs", bench.DefaultOptions.Runs, "timed runs of each query")
+	minSpeedup := flags.Float64("min-speedup", 1.5, "how many times faster a query has to get for an index to be worth keeping")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . bench indexes [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *runs < 1 || *warmup < 0 || *minSpeedup <= 1 {
+		return fmt.Errorf("-runs has to be at least 1, -warmup can't be negative and -min-speedup has to be more than 1")
+	}
+
+	suite, err := bench.LoadSuite(*suiteFile)
+	if err != nil {
+		return err
+	}
+	if len(suite.Indexes) == 0 {
+		return fmt.Errorf("%s has no indexes to try", *suiteFile)
+	}
+
+	ctx := context.Background()
+	patrons := db.Collection("patrons")
+	if _, err := patrons.Indexes().DropAll(ctx); err != nil {
+		return fmt.Errorf("couldn't drop the patrons indexes: %v", err)
+	}
+	defer func() {
+		if _, err := patrons.Indexes().CreateMany(ctx, patronIndexes); err != nil {
+			fmt.Println("couldn't build the patrons indexes again:", err)
+			return
+		}
+		fmt.Println("\nthe usual patrons indexes are back")
+	}()
+
+	fmt.Printf("\n=== index benchmark: %s, %d candidates ===\n", suite.Name, len(suite.Indexes))
+	backend := bench.IndexBackend{
+		Name: "mongodb",
+		Create: func(c bench.IndexCandidate, fields []string) (int64, error) {
+			fmt.Printf("building %s on %s\n", c.Name, strings.Join(fields, ", "))
+			keys := bson.D{}
+			for _, f := range fields {
+				keys = append(keys, bson.E{Key: f, Value: 1})
+			}
+			name := "bench_" + c.Name
+			_, err := patrons.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: options.Index().SetName(name)})
+			if err != nil {
+				return 0, err
+			}
+			return indexSize(db, name), nil
+		},
+		Drop: func(c bench.IndexCandidate) error {
+			_, err := patrons.Indexes().DropOne(ctx, "bench_"+c.Name)
+			return err
+		},
+	}
+	prepare := func(sq bench.SuiteQuery) (func() (int, error), error) {
+		run, _, err := prepareQuery(ctx, db, sq)
+		return run, err
+	}
+	opts := bench.Options{Warmup: *warmup, Runs: *runs}
+	base, results, err := suite.RunIndexes(backend, opts, nil, prepare)
+	fmt.Println()
+	if werr := bench.WriteIndexes(os.Stdout, suite, base, results, *minSpeedup); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// the size of an index on patrons, -1 when $collStats doesn't have it
+func indexSize(db *mongo.Database, name string) int64 {
+	tables, err := storageSizes(db)
+	if err != nil {
+		fmt.Println("couldn't get the index size:", err)
+		return -1
+	}
+	for _, t := range tables {
+		if t.Name != "patrons" {
+			continue
+		}
+		for _, index := range t.Indexes {
+			if index.Name == name {
+				return index.Bytes
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// gives a query its context and a comment to tag it with. the context runs
+// out after the timeout and ctrl-c cancels it until done is called. the
+// driver only drops the connection when a context ends and the server keeps
+// going, so the tagged operation gets killed on the server as well.
+func (s *session) startQuery() (ctx context.Context, tag string, done func()) {
+	ctx, stop := s.running.Start(s.timeout)
+	tag = fmt.Sprintf("sfils-repl-%d-%d", os.Getpid(), s.queries.Add(1))
+
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := killTagged(s.db.Client(), tag); err != nil {
+				fmt.Println("couldn't stop the query on the server:", err)
+			}
+		case <-finished:
+		}
+	}()
+
+	return ctx, tag, func() {
+		close(finished)
+		stop()
+	}
+}
+
+// finds operations carrying our comment in $currentOp and kills them.
+// getMore batches keep the comment on the command that opened the cursor.
+func killTagged(client *mongo.Client, tag string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	admin := client.Database("admin")
+	pipeline := mongo.Pipeline{
+		{{Key: "$currentOp", Value: bson.D{}}},
+		{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"command.comment": tag},
+			bson.M{"cursor.originatingCommand.comment": tag},
+		}}}},
+	}
+	cursor, err := admin.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+
+	var ops []bson.M
+	if err := cursor.All(ctx, &ops); err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if err := admin.RunCommand(ctx, bson.D{{Key: "killOp", Value: 1}, {Key: "op", Value: op["opid"]}}).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
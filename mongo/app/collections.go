@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// the collections the query interface can use unless --collections says otherwise
+const defaultCollections = "patrons,patron_types,libraries,notification_types"
+
+// collectionGuard stops queries on collections that don't exist, since
+// db.Collection() takes any name and a typo just quietly finds nothing, and
+// on collections that aren't in the allow-list
+type collectionGuard struct {
+	db *mongo.Database
+
+	// nil means every collection that isn't a system one
+	allowed map[string]bool
+
+	// names from ListCollectionNames, read again when a query uses a name
+	// that isn't here in case it was created since
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+// builds the guard from the --collections flag, a comma separated list or * for everything
+func newCollectionGuard(db *mongo.Database, allowList string) *collectionGuard {
+	g := &collectionGuard{db: db}
+	if strings.TrimSpace(allowList) != "*" {
+		g.allowed = map[string]bool{}
+		for _, name := range strings.Split(allowList, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				g.allowed[name] = true
+			}
+		}
+	}
+	return g
+}
+
+// whether a collection can be listed and queried. system collections like
+// system.profile are hidden unless the allow-list names them.
+func (g *collectionGuard) visible(name string) bool {
+	if g.allowed != nil {
+		return g.allowed[name]
+	}
+	return !isSystemCollection(name)
+}
+
+func isSystemCollection(name string) bool {
+	return strings.HasPrefix(name, "system.")
+}
+
+// makes sure a query can run against a collection, suggesting the closest
+// name when it looks like a typo
+func (g *collectionGuard) check(name string) error {
+	exists, err := g.exists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if match := closestMatch(name, g.visibleNames()); match != "" {
+			return fmt.Errorf("no collection called %q, did you mean %q?", name, match)
+		}
+		return fmt.Errorf("no collection called %q, try \\dt to see them all", name)
+	}
+	if !g.visible(name) {
+		if isSystemCollection(name) && g.allowed == nil {
+			return fmt.Errorf("%s is a system collection, add it to --collections to query it", name)
+		}
+		return fmt.Errorf("%s isn't one of the collections that can be queried, see --collections", name)
+	}
+	return nil
+}
+
+// checks the collection a pipeline runs on and any others it reads from
+// with $lookup, $graphLookup or $unionWith
+func (g *collectionGuard) checkPipeline(collection string, pipeline bson.A) error {
+	if err := g.check(collection); err != nil {
+		return err
+	}
+	for _, name := range pipelineCollections(pipeline) {
+		if err := g.check(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the collections that exist and can be used, sorted
+func (g *collectionGuard) visibleNames() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var names []string
+	for name := range g.names {
+		if g.visible(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lists the collections that can be used, reading them from the server again
+func (g *collectionGuard) list() ([]string, error) {
+	if err := g.refresh(); err != nil {
+		return nil, err
+	}
+	return g.visibleNames(), nil
+}
+
+func (g *collectionGuard) exists(name string) (bool, error) {
+	g.mu.Lock()
+	found := g.names[name]
+	g.mu.Unlock()
+	if found {
+		return true, nil
+	}
+	if err := g.refresh(); err != nil {
+		return false, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.names[name], nil
+}
+
+func (g *collectionGuard) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	names, err := g.db.ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		return fmt.Errorf("couldn't list collections: %v", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.names = map[string]bool{}
+	for _, name := range names {
+		g.names[name] = true
+	}
+	return nil
+}
+
+// every collection a pipeline reads from besides the one it runs on,
+// including ones in the sub-pipelines of $lookup, $unionWith and $facet
+func pipelineCollections(pipeline bson.A) []string {
+	var names []string
+	for _, st := range pipeline {
+		stage, ok := st.(bson.D)
+		if !ok || len(stage) == 0 {
+			continue
+		}
+		body := stage[0].Value
+		switch stage[0].Key {
+		case "$lookup", "$graphLookup":
+			if from, ok := field(body, "from").(string); ok {
+				names = append(names, from)
+			}
+			if sub, ok := field(body, "pipeline").(bson.A); ok {
+				names = append(names, pipelineCollections(sub)...)
+			}
+		case "$unionWith":
+			// either just the name or {coll: name, pipeline: [...]}
+			if from, ok := body.(string); ok {
+				names = append(names, from)
+			}
+			if from, ok := field(body, "coll").(string); ok {
+				names = append(names, from)
+			}
+			if sub, ok := field(body, "pipeline").(bson.A); ok {
+				names = append(names, pipelineCollections(sub)...)
+			}
+		case "$facet":
+			if facets, ok := body.(bson.D); ok {
+				for _, f := range facets {
+					if sub, ok := f.Value.(bson.A); ok {
+						names = append(names, pipelineCollections(sub)...)
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// a field of a document, or nil when it isn't a document or doesn't have it
+func field(doc interface{}, key string) interface{} {
+	d, ok := doc.(bson.D)
+	if !ok {
+		return nil
+	}
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return nil
+}
+
+// the candidate that's only a couple of edits away from name, or "" when
+// nothing is close enough to be a typo
+func closestMatch(name string, candidates []string) string {
+	best, bestDist := "", len(name)/3+2
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(c)); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// levenshtein distance, the number of single letter changes to get from a to b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
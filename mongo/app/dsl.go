@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// runs a query in the patron query language by compiling it to an
+// aggregation pipeline on the patrons collection
+func (s *session) runDSL(text string) error {
+	pipeline, err := compileDSL(text)
+	if err != nil {
+		return err
+	}
+	if err := s.collections.checkPipeline("patrons", pipeline); err != nil {
+		return err
+	}
+	return s.runAggregate("patrons", pipeline)
+}
+
+// parses and compiles a patron query into a pipeline the driver can send
+func compileDSL(text string) (bson.A, error) {
+	q, err := dsl.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return pipelineOf(q)
+}
+
+// turns the compiled pipeline text into bson the driver can send
+func pipelineOf(q *dsl.Query) (bson.A, error) {
+	var doc bson.D
+	wrapped := `{"q":` + q.Pipeline() + `}`
+	if err := bson.UnmarshalExtJSON([]byte(wrapped), false, &doc); err != nil {
+		return nil, fmt.Errorf("couldn't read the compiled pipeline: %v", err)
+	}
+	return doc[0].Value.(bson.A), nil
+}
+
+// :compile QUERY prints the pipeline a patron query turns into
+func (s *session) showCompiled(text string) {
+	q, err := dsl.Parse(text)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var out bytes.Buffer
+	json.Indent(&out, []byte(q.Pipeline()), "", "  ")
+	fmt.Println("db.patrons.aggregate(" + out.String() + ")")
+	fmt.Println()
+}
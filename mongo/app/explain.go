@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jacksongodsey/SFILS/shared/interrupt"
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// runs the explain command with executionStats and prints the plan as a tree
+func (s *session) explainCommand(command bson.D) {
+	ctx, _, done := s.startQuery()
+	defer done()
+
+	var result bson.M
+	err := s.db.RunCommand(ctx, bson.D{
+		{Key: "explain", Value: command},
+		{Key: "verbosity", Value: "executionStats"},
+	}).Decode(&result)
+	if err != nil {
+		fmt.Println(interrupt.Error(ctx, err))
+		return
+	}
+
+	root := explainTree(result)
+	warnings := output.WriteTree(s.display.Out(), root)
+	fmt.Printf("\n%d warnings\n\n", warnings)
+}
+
+// builds the tree from an explain result. find and count put queryPlanner at
+// the top, aggregations that start with a query put it inside the $cursor stage.
+func explainTree(result bson.M) *output.PlanNode {
+	if stages, ok := result["stages"].(bson.A); ok {
+		root := &output.PlanNode{Label: "aggregation pipeline"}
+		for _, st := range stages {
+			stage, ok := st.(bson.M)
+			if !ok {
+				continue
+			}
+			for name, body := range stage {
+				if name == "$cursor" {
+					if m, ok := body.(bson.M); ok {
+						root.Children = append(root.Children, queryNode(m))
+					}
+					continue
+				}
+				if name == "nReturned" || name == "executionTimeMillisEstimate" {
+					continue
+				}
+				root.Children = append(root.Children, &output.PlanNode{Label: name + " " + compactExtJSON(body)})
+			}
+		}
+		return root
+	}
+	return queryNode(result)
+}
+
+// the part of the plan that reads from the collection, with the execution stats on top
+func queryNode(result bson.M) *output.PlanNode {
+	root := &output.PlanNode{Label: "query"}
+
+	planner, _ := result["queryPlanner"].(bson.M)
+	if ns, ok := planner["namespace"].(string); ok {
+		root.Label = "query on " + ns
+	}
+
+	stats, _ := result["executionStats"].(bson.M)
+	if stats != nil {
+		returned := toInt64(stats["nReturned"])
+		docs := toInt64(stats["totalDocsExamined"])
+		root.Details = append(root.Details,
+			fmt.Sprintf("%d returned, %d documents and %d index keys examined in %dms",
+				returned, docs, toInt64(stats["totalKeysExamined"]), toInt64(stats["executionTimeMillis"])))
+		// lots of documents read for very few kept means the index isn't selective enough
+		if returned > 0 && docs > 10*returned && docs > 1000 {
+			root.Warnings = append(root.Warnings, fmt.Sprintf("examined %d documents to return %d", docs, returned))
+		}
+	}
+
+	winning := planStage(planner["winningPlan"])
+	if winning == nil {
+		return root
+	}
+	used := map[string]bool{}
+	root.Children = append(root.Children, stageNode(winning, used))
+
+	// indexes the planner tried and threw away
+	var rejected []string
+	seen := map[string]bool{}
+	if plans, ok := planner["rejectedPlans"].(bson.A); ok {
+		for _, p := range plans {
+			for _, name := range indexNames(planStage(p)) {
+				if !used[name] && !seen[name] {
+					seen[name] = true
+					rejected = append(rejected, name)
+				}
+			}
+		}
+	}
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		root.Warnings = append(root.Warnings, "indexes considered but not used: "+strings.Join(rejected, ", "))
+	}
+	return root
+}
+
+// newer servers wrap the classic plan in queryPlan next to the slot based one
+func planStage(v interface{}) bson.M {
+	plan, ok := v.(bson.M)
+	if !ok {
+		return nil
+	}
+	if inner, ok := plan["queryPlan"].(bson.M); ok {
+		return inner
+	}
+	return plan
+}
+
+// one stage of the plan and everything feeding into it
+func stageNode(stage bson.M, used map[string]bool) *output.PlanNode {
+	name, _ := stage["stage"].(string)
+	n := &output.PlanNode{Label: name}
+
+	switch name {
+	case "COLLSCAN":
+		n.Warnings = append(n.Warnings, "full collection scan")
+	case "IXSCAN", "COUNT_SCAN", "DISTINCT_SCAN":
+		if idx, ok := stage["indexName"].(string); ok {
+			used[idx] = true
+			n.Label += " " + idx
+		}
+		if keys, ok := stage["keyPattern"]; ok {
+			n.Details = append(n.Details, "keys "+compactExtJSON(keys))
+		}
+	}
+	if filter, ok := stage["filter"]; ok {
+		n.Details = append(n.Details, "filter "+compactExtJSON(filter))
+	}
+	if limit, ok := stage["limitAmount"]; ok {
+		n.Details = append(n.Details, fmt.Sprintf("limit %v", limit))
+	}
+
+	if input := planStage(stage["inputStage"]); input != nil {
+		n.Children = append(n.Children, stageNode(input, used))
+	}
+	if inputs, ok := stage["inputStages"].(bson.A); ok {
+		for _, in := range inputs {
+			if m := planStage(in); m != nil {
+				n.Children = append(n.Children, stageNode(m, used))
+			}
+		}
+	}
+	return n
+}
+
+// every index a plan reads from
+func indexNames(stage bson.M) []string {
+	if stage == nil {
+		return nil
+	}
+	var names []string
+	if idx, ok := stage["indexName"].(string); ok {
+		names = append(names, idx)
+	}
+	names = append(names, indexNames(planStage(stage["inputStage"]))...)
+	if inputs, ok := stage["inputStages"].(bson.A); ok {
+		for _, in := range inputs {
+			names = append(names, indexNames(planStage(in))...)
+		}
+	}
+	return names
+}
+
+// numbers in explain output can be int32, int64 or double depending on the server
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jacksongodsey/SFILS/shared/metrics"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// the import is split in steps so bench import can time each one:
+// reading the workbook, cleaning the rows, filling the lookup collections
+// and writing the patrons
+
+// reads every row of the first sheet
+func readWorkbook(file string) ([][]string, error) {
+	f, err := excelize.OpenFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return f.GetRows(f.GetSheetName(0))
+}
+
+// cleans the rows up and turns them into patron documents, rows that are
+// too short are skipped and counted as bad
+func cleanRows(rows [][]string) (patrons []Patron, bad int) {
+	for i, row := range rows {
+		if i == 0 { // skip the header here to make sure this all works
+			continue
+		}
+
+		// make sure we have enough columns
+		if len(row) < 14 {
+			fmt.Printf("skipping row %d: insufficient columns (has %d, needs 14)\n", i, len(row))
+			bad++
+			continue
+		}
+
+		// clean up each cell
+		for j := range row {
+			row[j] = strings.TrimSpace(strings.ReplaceAll(row[j], "\n", " "))
+		}
+
+		// create patron document with embedded reference data
+		patrons = append(patrons, Patron{
+			PatronTypeCode:       row[0],
+			PatronTypeDesc:       row[1],
+			CheckoutTotal:        row[2],
+			RenewalTotal:         row[3],
+			AgeRange:             row[4],
+			HomeLibraryCode:      row[5],
+			HomeLibraryName:      row[6],
+			ActiveMonth:          monthToIntOrNull(row[7]),
+			ActiveYear:           stringToPointerOrNull(row[8]),
+			NotificationTypeCode: row[9],
+			NotificationTypeDesc: row[10],
+			Email:                cleanEmail(row[11]),
+			WithinSFC:            strings.EqualFold(row[12], "true"),
+			YearRegistered:       stringToPointerOrNull(row[13]),
+		})
+	}
+	return patrons, bad
+}
+
+// cleans and writes rows that didn't come from the workbook, like the ones
+// sent to the grpc import, the way importExcel does with the workbook's
+func importRows(db *mongo.Database, rows [][]string) (good, bad int, err error) {
+	// cleanRows skips the first row as the header
+	patrons, short := cleanRows(append([][]string{nil}, rows...))
+	patrons, lookupFailed := lookupDimensions(db, patrons)
+
+	good, insertFailed, err := writePatrons(db, patrons, writeStrategy{name: "batched", batch: 1000})
+	if err == nil {
+		countImport(len(rows), short, lookupFailed, insertFailed)
+	}
+	return good, short + lookupFailed + insertFailed, err
+}
+
+// adds an import's rows to the metrics
+func countImport(processed, short, lookupFailed, insertFailed int) {
+	metrics.Rows(processed, map[string]int{
+		metrics.ShortRow:     short,
+		metrics.LookupFailed: lookupFailed,
+		metrics.InsertFailed: insertFailed,
+	})
+}
+
+// drops the collections and creates the indexes again for a fresh start
+func resetCollections(db *mongo.Database) error {
+	ctx := context.Background()
+	for _, name := range []string{"patrons", "patron_types", "libraries", "notification_types"} {
+		if err := db.Collection(name).Drop(ctx); err != nil {
+			fmt.Printf("note: couldn't drop %s collection\n", name)
+		}
+	}
+	return createIndexes(db)
+}
+
+// fills the patron types, libraries and notification types collections with
+// every code the patrons use, once per code instead of once per row.
+// patrons whose codes couldn't be added are dropped and counted as bad.
+func lookupDimensions(db *mongo.Database, patrons []Patron) ([]Patron, int) {
+	ctx := context.Background()
+	patronTypesColl := db.Collection("patron_types")
+	librariesColl := db.Collection("libraries")
+	notificationTypesColl := db.Collection("notification_types")
+
+	// true when the code is in, false when adding it failed
+	types := map[string]bool{}
+	libraries := map[string]bool{}
+	notifications := map[string]bool{}
+	for _, p := range patrons {
+		if _, seen := types[p.PatronTypeCode]; !seen {
+			err := ensurePatronType(ctx, patronTypesColl, p.PatronTypeCode, p.PatronTypeDesc)
+			if err != nil {
+				fmt.Printf("failed patron type %q: %v\n", p.PatronTypeCode, err)
+			}
+			types[p.PatronTypeCode] = err == nil
+		}
+		if _, seen := libraries[p.HomeLibraryCode]; !seen {
+			err := ensureLibrary(ctx, librariesColl, p.HomeLibraryCode, p.HomeLibraryName)
+			if err != nil {
+				fmt.Printf("failed library %q: %v\n", p.HomeLibraryCode, err)
+			}
+			libraries[p.HomeLibraryCode] = err == nil
+		}
+		if _, seen := notifications[p.NotificationTypeCode]; !seen {
+			err := ensureNotificationType(ctx, notificationTypesColl, p.NotificationTypeCode, p.NotificationTypeDesc)
+			if err != nil {
+				fmt.Printf("failed notification %q: %v\n", p.NotificationTypeCode, err)
+			}
+			notifications[p.NotificationTypeCode] = err == nil
+		}
+	}
+
+	kept := make([]Patron, 0, len(patrons))
+	bad := 0
+	for _, p := range patrons {
+		if !types[p.PatronTypeCode] || !libraries[p.HomeLibraryCode] || !notifications[p.NotificationTypeCode] {
+			bad++
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, bad
+}
+
+// how the patrons get written. batch is the documents per InsertMany and
+// workers how many inserts run at once, only used by the strategies that
+// need them.
+type writeStrategy struct {
+	name     string
+	batch    int
+	workers  int
+	progress bool // print how far along it is every 10k documents
+}
+
+// writes the patrons with the strategy's inserts and counts the documents
+// that went in and the ones that didn't
+func writePatrons(db *mongo.Database, patrons []Patron, strategy writeStrategy) (good, bad int, err error) {
+	coll := db.Collection("patrons")
+	switch strategy.name {
+	case "single":
+		good, bad = writeSingle(coll, patrons)
+		return good, bad, nil
+	case "batched":
+		good, bad = writeBatches(coll, patrons, strategy.batch, strategy.progress)
+		return good, bad, nil
+	case "concurrent":
+		good, bad = writeConcurrent(coll, patrons, strategy.batch, strategy.workers)
+		return good, bad, nil
+	}
+	return 0, 0, fmt.Errorf("unknown write strategy %q", strategy.name)
+}
+
+// one InsertOne per document
+func writeSingle(coll *mongo.Collection, patrons []Patron) (good, bad int) {
+	ctx := context.Background()
+	for i, p := range patrons {
+		if _, err := coll.InsertOne(ctx, p); err != nil {
+			fmt.Printf("failed to insert document %d: %v\n", i+1, err)
+			bad++
+			continue
+		}
+		good++
+	}
+	return good, bad
+}
+
+// one InsertMany per batch of documents. when a batch fails every document
+// in it counts as bad.
+func writeBatches(coll *mongo.Collection, patrons []Patron, batch int, progress bool) (good, bad int) {
+	ctx := context.Background()
+	for start := 0; start < len(patrons); start += batch {
+		chunk := patrons[start:min(start+batch, len(patrons))]
+		docs := make([]interface{}, len(chunk))
+		for i := range chunk {
+			docs[i] = chunk[i]
+		}
+		if _, err := coll.InsertMany(ctx, docs); err != nil {
+			fmt.Printf("failed to insert batch at document %d: %v\n", start+1, err)
+			bad += len(chunk)
+		} else {
+			good += len(chunk)
+		}
+
+		// print progress every 10k documents so i know it's working
+		if done := start + len(chunk); progress && done/10000 > start/10000 {
+			fmt.Printf("processed %d rows (%d successful, %d errors)\n", done, good, bad)
+		}
+	}
+	return good, bad
+}
+
+// batched inserts from several workers, each with its own share of the
+// documents
+func writeConcurrent(coll *mongo.Collection, patrons []Patron, batch, workers int) (good, bad int) {
+	if len(patrons) == 0 {
+		return 0, 0
+	}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		per = (len(patrons) + workers - 1) / workers
+	)
+	for start := 0; start < len(patrons); start += per {
+		part := patrons[start:min(start+per, len(patrons))]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g, b := writeBatches(coll, part, batch, false)
+			mu.Lock()
+			good += g
+			bad += b
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return good, bad
+}
@@ -3,13 +3,25 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
-	"github.com/xuri/excelize/v2"
+	"github.com/jacksongodsey/SFILS/shared/api"
+	"github.com/jacksongodsey/SFILS/shared/bench"
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/interrupt"
+	"github.com/jacksongodsey/SFILS/shared/metrics"
+	"github.com/jacksongodsey/SFILS/shared/named"
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"github.com/jacksongodsey/SFILS/shared/report"
+	"github.com/jacksongodsey/SFILS/shared/verify"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -18,6 +30,9 @@ import (
 const (
 	mongoURI = "mongodb://localhost:27017"
 	dbName   = "sfils"
+
+	// the workbook that gets imported
+	workbookFile = "../data/sfpl.xlsx"
 )
 
 // PatronType represents patron type documents
@@ -56,7 +71,72 @@ type Patron struct {
 	YearRegistered       *string `bson:"year_registered,omitempty"`
 }
 
+// command line flags, these go before the command
+var (
+	allowWrites = flag.Bool("allow-writes", false, "let aggregations use $out and $merge to write to collections")
+	queriesFile = flag.String("queries", "../queries/named.json", "file with the saved queries")
+	suiteFile   = flag.String("suite", "../../queries/bench.yaml", "yaml file with the benchmark queries")
+	collections = flag.String("collections", defaultCollections, "comma separated collections the query interface can use, * for all but the system ones")
+	metricsAddr = flag.String("metrics-addr", "", "serve the prometheus metrics on this address at /metrics while the command runs")
+	pushGateway = flag.String("push-gateway", "", "push the prometheus metrics to this pushgateway url when the command is done")
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "usage: go run . [flags] [command]")
+	fmt.Fprintln(out, "\ncommands:")
+	fmt.Fprintln(out, "  (none)                      load the workbook into mongodb and open the query interface")
+	fmt.Fprintln(out, "  import                      load the workbook into mongodb and exit, for nightly loads")
+	fmt.Fprintln(out, "  query NAME [key=value ...]  run a saved query and print the result")
+	fmt.Fprintln(out, "  query 'db.patrons.find({})' run a mongo shell style query and print the result")
+	fmt.Fprintln(out, "  query 'collection|{filter}' run a find and print the documents")
+	fmt.Fprintln(out, "  report [NAME ...]           print the built-in reports, all of them without a name")
+	fmt.Fprintln(out, "  bench                       time the benchmark queries over repeated runs and save the results")
+	fmt.Fprintln(out, "  bench compare OLD NEW       compare two saved runs and fail on regressions")
+	fmt.Fprintln(out, "  bench report RUN|FOLDER ... write saved mysql and mongodb runs side by side as markdown or html")
+	fmt.Fprintln(out, "  verify [-against FILE]      save a snapshot of the data to check it against mysql's")
+	fmt.Fprintln(out, "  verify compare BASE HEAD    compare two saved snapshots and fail when they differ")
+	fmt.Fprintln(out, "  bench load                  run a weighted mix of the benchmark queries from many clients at once")
+	fmt.Fprintln(out, "  bench import                time each step of the import and compare ways of writing the documents")
+	fmt.Fprintln(out, "  bench indexes               run the benchmark with and without each candidate index and suggest which to keep")
+	fmt.Fprintln(out, "  serve [-addr :8080]         serve the patron data as a json api, the spec is at /openapi.json")
+	fmt.Fprintln(out, "  serve spec [-out FILE]      write the api's OpenAPI spec")
+	fmt.Fprintln(out, "  grpc [-addr :9090]          serve the patron data over grpc for the data-sync jobs")
+	fmt.Fprintln(out, "\nflags:")
+	flag.PrintDefaults()
+}
+
 func main() {
+	flag.Usage = usage
+	flag.Parse()
+	metrics.Setup("mongodb")
+
+	// comparing saved benchmark runs doesn't need the database
+	if flag.Arg(0) == "bench" && flag.Arg(1) == "compare" {
+		if err := bench.CompareCommand(flag.Args()[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if flag.Arg(0) == "bench" && flag.Arg(1) == "report" {
+		if err := bench.ReportCommand(flag.Args()[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if flag.Arg(0) == "verify" && flag.Arg(1) == "compare" {
+		if err := verify.CompareCommand(flag.Args()[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if flag.Arg(0) == "serve" && flag.Arg(1) == "spec" {
+		if err := api.SpecCommand("mongodb", flag.Args()[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// environment variable grabbing for the connection string
 	uri := os.Getenv("MONGO_URI")
 	if uri == "" {
@@ -69,7 +149,7 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetPoolMonitor(pool.monitor()))
 	if err != nil {
 		log.Fatal("couldn't connect to mongodb:", err)
 	}
@@ -85,25 +165,63 @@ func main() {
 	}
 
 	db := client.Database(dbName)
-	fmt.Println("database", dbName, "ready")
+	metrics.Register(newPoolCollector())
+	if *metricsAddr != "" {
+		if err := metrics.Serve(*metricsAddr); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	// create indexes for better query performance
-	err = createIndexes(db)
-	if err != nil {
-		log.Fatal(err)
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+		fmt.Println("database", dbName, "ready")
+		if err = loadWorkbook(db); err == nil {
+			startTextInterface(db, *allowWrites)
+		}
+	case "import":
+		err = loadWorkbook(db)
+	case "query":
+		err = runQueryCommand(db, flag.Args()[1:])
+	case "report":
+		err = runReportCommand(db, flag.Args()[1:])
+	case "bench":
+		err = runBenchCommand(db, flag.Args()[1:])
+	case "verify":
+		err = runVerifyCommand(db, flag.Args()[1:])
+	case "serve":
+		err = runServeCommand(db, flag.Args()[1:])
+	case "grpc":
+		err = runGRPCCommand(db, flag.Args()[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", cmd)
+		flag.Usage()
+		os.Exit(2)
 	}
 
-	// read excel and import data
-	err = importExcel(db, "../data/sfpl.xlsx")
+	// pushed even when the command failed, a failed nightly load is what
+	// the dashboards most need to see
+	if *pushGateway != "" {
+		if err := metrics.Push(*pushGateway); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
 	if err != nil {
+		client.Disconnect(context.Background())
 		log.Fatal(err)
 	}
-
-	// start the text interface
-	startTextInterface(db)
 }
 
 // create indexes on collections for better performance
+// indexes on patrons collection for common queries
+var patronIndexes = []mongo.IndexModel{
+	{Keys: bson.D{{Key: "patron_type_code", Value: 1}}},
+	{Keys: bson.D{{Key: "age_range", Value: 1}}},
+	{Keys: bson.D{{Key: "home_library_code", Value: 1}}},
+	{Keys: bson.D{{Key: "within_sfc", Value: 1}}},
+	{Keys: bson.D{{Key: "active_year", Value: 1}}},
+	{Keys: bson.D{{Key: "email", Value: 1}}},
+}
+
 func createIndexes(db *mongo.Database) error {
 	ctx := context.Background()
 
@@ -134,16 +252,6 @@ func createIndexes(db *mongo.Database) error {
 		return fmt.Errorf("error creating notification_types index: %v", err)
 	}
 
-	// indexes on patrons collection for common queries
-	patronIndexes := []mongo.IndexModel{
-		{Keys: bson.D{{Key: "patron_type_code", Value: 1}}},
-		{Keys: bson.D{{Key: "age_range", Value: 1}}},
-		{Keys: bson.D{{Key: "home_library_code", Value: 1}}},
-		{Keys: bson.D{{Key: "within_sfc", Value: 1}}},
-		{Keys: bson.D{{Key: "active_year", Value: 1}}},
-		{Keys: bson.D{{Key: "email", Value: 1}}},
-	}
-
 	_, err = db.Collection("patrons").Indexes().CreateMany(ctx, patronIndexes)
 	if err != nil {
 		return fmt.Errorf("error creating patrons indexes: %v", err)
@@ -234,150 +342,49 @@ func ensureNotificationType(ctx context.Context, coll *mongo.Collection, code, d
 	return err
 }
 
-// reads an excel file and puts the data into the patrons collection
-func importExcel(db *mongo.Database, file string) error {
-	f, err := excelize.OpenFile(file)
-	if err != nil {
+// creates the indexes and imports the workbook
+func loadWorkbook(db *mongo.Database) error {
+	// create indexes for better query performance
+	if err := createIndexes(db); err != nil {
 		return err
 	}
-	defer f.Close()
 
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
+	// read excel and import data
+	return importExcel(db, workbookFile)
+}
+
+// reads an excel file and puts the data into the patrons collection
+func importExcel(db *mongo.Database, file string) error {
+	done := metrics.Phase("parse")
+	rows, err := readWorkbook(file)
+	done()
 	if err != nil {
 		return err
 	}
 
-	ctx := context.Background()
-
 	// drop existing data for a fresh start
-	if err := db.Collection("patrons").Drop(ctx); err != nil {
-		fmt.Println("note: couldn't drop patrons collection")
-	}
-	if err := db.Collection("patron_types").Drop(ctx); err != nil {
-		fmt.Println("note: couldn't drop patron_types collection")
-	}
-	if err := db.Collection("libraries").Drop(ctx); err != nil {
-		fmt.Println("note: couldn't drop libraries collection")
-	}
-	if err := db.Collection("notification_types").Drop(ctx); err != nil {
-		fmt.Println("note: couldn't drop notification_types collection")
-	}
-
-	// recreate indexes after dropping
-	if err := createIndexes(db); err != nil {
+	if err := resetCollections(db); err != nil {
 		return err
 	}
 
-	patronsColl := db.Collection("patrons")
-	patronTypesColl := db.Collection("patron_types")
-	librariesColl := db.Collection("libraries")
-	notificationTypesColl := db.Collection("notification_types")
+	done = metrics.Phase("clean")
+	patrons, short := cleanRows(rows)
+	done()
 
-	good := 0
-	bad := 0
+	done = metrics.Phase("lookups")
+	patrons, lookupFailed := lookupDimensions(db, patrons)
+	done()
 
 	// using bulk writes for better performance
-	var patronDocs []interface{}
-
-	for i, row := range rows {
-		if i == 0 { // skip the header here to make sure this all works
-			continue
-		}
-
-		// make sure we have enough columns
-		if len(row) < 14 {
-			fmt.Printf("skipping row %d: insufficient columns (has %d, needs 14)\n", i, len(row))
-			bad++
-			continue
-		}
-
-		// clean up each cell
-		for j := range row {
-			row[j] = strings.TrimSpace(strings.ReplaceAll(row[j], "\n", " "))
-		}
-
-		// ensure patron type exists
-		if err := ensurePatronType(ctx, patronTypesColl, row[0], row[1]); err != nil {
-			fmt.Printf("failed patron type for row %d: %v\n", i, err)
-			bad++
-			continue
-		}
-
-		// ensure library exists
-		if err := ensureLibrary(ctx, librariesColl, row[5], row[6]); err != nil {
-			fmt.Printf("failed library for row %d: %v\n", i, err)
-			bad++
-			continue
-		}
-
-		// ensure notification type exists
-		if err := ensureNotificationType(ctx, notificationTypesColl, row[9], row[10]); err != nil {
-			fmt.Printf("failed notification for row %d: %v\n", i, err)
-			bad++
-			continue
-		}
-
-		// convert the month to number or a nil value
-		activeMonth := monthToIntOrNull(row[7])
-
-		// convert the year to pointer or a nil value
-		activeYear := stringToPointerOrNull(row[8])
-		yearRegistered := stringToPointerOrNull(row[13])
-
-		// converts bools from true/false to boolean
-		withinSFC := strings.EqualFold(row[12], "true")
-
-		// clean email and set to nil if it's not in a valid format
-		email := cleanEmail(row[11])
-
-		// create patron document with embedded reference data
-		patron := Patron{
-			PatronTypeCode:       row[0],
-			PatronTypeDesc:       row[1],
-			CheckoutTotal:        row[2],
-			RenewalTotal:         row[3],
-			AgeRange:             row[4],
-			HomeLibraryCode:      row[5],
-			HomeLibraryName:      row[6],
-			ActiveMonth:          activeMonth,
-			ActiveYear:           activeYear,
-			NotificationTypeCode: row[9],
-			NotificationTypeDesc: row[10],
-			Email:                email,
-			WithinSFC:            withinSFC,
-			YearRegistered:       yearRegistered,
-		}
-
-		patronDocs = append(patronDocs, patron)
-		good++
-
-		// batch insert every 1000 documents for better performance
-		if len(patronDocs) >= 1000 {
-			_, err := patronsColl.InsertMany(ctx, patronDocs)
-			if err != nil {
-				fmt.Printf("failed to insert batch at row %d: %v\n", i, err)
-				bad += len(patronDocs)
-				good -= len(patronDocs)
-			}
-			patronDocs = []interface{}{}
-		}
-
-		// print progress every 10k rows so i know it's working
-		if i%10000 == 0 {
-			fmt.Printf("processed %d rows (%d successful, %d errors)\n", i, good, bad)
-		}
-	}
-
-	// insert remaining documents
-	if len(patronDocs) > 0 {
-		_, err := patronsColl.InsertMany(ctx, patronDocs)
-		if err != nil {
-			fmt.Printf("failed to insert final batch: %v\n", err)
-			bad += len(patronDocs)
-			good -= len(patronDocs)
-		}
+	done = metrics.Phase("writes")
+	good, insertFailed, err := writePatrons(db, patrons, writeStrategy{name: "batched", batch: 1000, progress: true})
+	done()
+	if err != nil {
+		return err
 	}
+	countImport(len(rows)-1, short, lookupFailed, insertFailed)
+	metrics.ImportDone()
+	bad := short + lookupFailed + insertFailed
 
 	fmt.Printf("\nexcel import complete:\n")
 	fmt.Printf("  total rows processed: %d\n", len(rows)-1)
@@ -387,20 +394,68 @@ func importExcel(db *mongo.Database, file string) error {
 	return nil
 }
 
+// session holds the settings that can be changed from inside the text interface
+type session struct {
+	db          *mongo.Database
+	display     *output.Settings
+	view        *documentView
+	collections *collectionGuard
+	allowWrites bool
+	timeout     time.Duration
+	timing      bool
+	saved       []*namedQuery
+
+	// where counts and timings go. stdout in the text interface, stderr
+	// for the query command so only the results end up in a pipe
+	status io.Writer
+
+	// the query that's running, ctrl-c cancels it. queries counts them so
+	// each gets its own tag.
+	running interrupt.Running
+	queries atomic.Int64
+}
+
 // providing a very basic text interface
-func startTextInterface(db *mongo.Database) {
+func startTextInterface(db *mongo.Database, allowWrites bool) {
 	fmt.Println("\n=== program interface ===")
-	fmt.Println("type MongoDB queries in JSON format")
-	fmt.Println("format: collection_name|{\"field\": \"value\"}")
+	fmt.Println("type MongoDB queries like the mongo shell: db.patrons.find({within_sfc: true}).limit(5)")
+	fmt.Println("or the short form: collection_name|{\"field\": \"value\"}")
 	fmt.Println("type 'exit' or 'quit' to quit")
-	fmt.Println("type 'help' for example queries")
+	fmt.Println("type 'help' for saved queries, reports and commands")
 	fmt.Println()
 
+	// documents come out indented by default
+	s := &session{
+		db:          db,
+		display:     output.NewSettings(),
+		view:        newDocumentView(),
+		collections: newCollectionGuard(db, *collections),
+		allowWrites: allowWrites,
+		timeout:     interrupt.DefaultTimeout,
+		status:      os.Stdout,
+	}
+	s.display.Format = prettyFormat
+	defer s.display.Close()
+
+	saved, err := loadNamedQueries(*queriesFile)
+	if err != nil {
+		fmt.Println("couldn't load saved queries:", err)
+	}
+	s.saved = saved
 	reader := bufio.NewReader(os.Stdin)
 
+	// ctrl-c stops the running query instead of the whole program
+	stopInterrupts := s.running.Handle()
+	defer stopInterrupts()
+
 	for {
 		fmt.Print("> ")
-		input, _ := reader.ReadString('\n')
+		input, err := reader.ReadString('\n')
+		if err != nil && input == "" {
+			// ctrl-d, or stdin ran out
+			fmt.Println("\nbye")
+			break
+		}
 		input = strings.TrimSpace(input)
 
 		if input == "" {
@@ -413,158 +468,302 @@ func startTextInterface(db *mongo.Database) {
 		}
 
 		if input == "help" {
-			printHelp()
+			printHelp(s)
 			continue
 		}
 		if input == "benchmark" {
-			runBenchmark(db)
+			if suite, err := bench.LoadSuite(*suiteFile); err != nil {
+				fmt.Println("couldn't load the benchmark suite:", err)
+			} else {
+				runBenchmark(db, suite, bench.DefaultOptions)
+			}
 			continue
 		}
 
-		// parse command format: collection|filter
-		parts := strings.SplitN(input, "|", 2)
-		if len(parts) != 2 {
-			fmt.Println("format error: use collection_name|{filter}")
+		// anything starting with a backslash changes a setting
+		if strings.HasPrefix(input, "\\") {
+			s.metaCommand(input)
 			continue
 		}
 
-		collectionName := strings.TrimSpace(parts[0])
-		filterStr := strings.TrimSpace(parts[1])
+		if strings.HasPrefix(input, ":") {
+			s.namedCommand(input)
+			continue
+		}
+
+		if err := s.runQuery(input); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// handles the backslash commands
+func (s *session) metaCommand(input string) {
+	fields := strings.Fields(input)
+	cmd, args := fields[0], fields[1:]
 
-		// parse the filter as BSON
-		var filter bson.M
-		if filterStr != "{}" && filterStr != "" {
-			err := bson.UnmarshalExtJSON([]byte(filterStr), true, &filter)
+	if s.view.Command(cmd, args, s.display) || s.display.Command(cmd, args) {
+		return
+	}
+
+	switch cmd {
+	case "\\timing":
+		switch {
+		case len(args) == 0:
+			s.timing = !s.timing
+		case args[0] == "on" || args[0] == "off":
+			s.timing = args[0] == "on"
+		default:
+			fmt.Println("usage: \\timing [on|off]")
+			return
+		}
+		if s.timing {
+			fmt.Println("timing is on")
+		} else {
+			fmt.Println("timing is off")
+		}
+	case "\\explain":
+		query := strings.TrimSpace(strings.TrimPrefix(input, cmd))
+		if strings.HasPrefix(query, "db.") {
+			q, err := parseShell(query)
 			if err != nil {
-				fmt.Println("filter parse error:", err)
-				continue
+				fmt.Println(err)
+				return
+			}
+			// through runShell so it gets the same collection checks
+			q.Explain = true
+			if err := s.runShell(q); err != nil {
+				fmt.Println(err)
 			}
+			return
+		}
+		collectionName, filter, err := parseQuery(query)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := s.collections.check(collectionName); err != nil {
+			fmt.Println(err)
+			return
+		}
+		s.explainCommand(bson.D{
+			{Key: "find", Value: collectionName},
+			{Key: "filter", Value: filter},
+			{Key: "limit", Value: 100},
+		})
+	case "\\timeout":
+		if len(args) == 0 {
+			fmt.Println("query timeout is", interrupt.DescribeTimeout(s.timeout))
+			return
+		}
+		d, err := interrupt.ParseTimeout(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		s.timeout = d
+		fmt.Println("query timeout is", interrupt.DescribeTimeout(s.timeout))
+	case "\\dt":
+		s.listCollections()
+	case "\\d":
+		if len(args) != 1 {
+			fmt.Println("usage: \\d COLLECTION")
+			return
+		}
+		s.describeCollection(args[0])
+	case "\\di":
+		collection := ""
+		if len(args) > 0 {
+			collection = args[0]
 		}
+		s.listIndexes(collection)
+	default:
+		fmt.Println("unknown command:", cmd)
+	}
+}
 
-		// execute the query
-		ctx := context.Background()
-		cursor, err := db.Collection(collectionName).Find(ctx, filter, options.Find().SetLimit(100))
+// runs a db.collection.method(...) or collection|filter query and writes
+// the result in the current format
+func (s *session) runQuery(input string) error {
+	if dsl.IsQuery(input) {
+		return s.runDSL(input)
+	}
+	if strings.HasPrefix(input, "db.") {
+		q, err := parseShell(input)
 		if err != nil {
-			fmt.Println("query error:", err)
-			continue
+			return err
 		}
+		return s.runShell(q)
+	}
 
-		// decode and print results
-		fmt.Println(strings.Repeat("-", 80))
-		rowCount := 0
-		for cursor.Next(ctx) {
-			var result bson.M
-			if err := cursor.Decode(&result); err != nil {
-				fmt.Println("decode error:", err)
-				continue
-			}
-			fmt.Printf("%v\n", result)
-			rowCount++
+	collectionName, filter, err := parseQuery(input)
+	if err != nil {
+		return err
+	}
+	if err := s.collections.check(collectionName); err != nil {
+		return err
+	}
+	return s.runFind(collectionName, filter)
+}
+
+// runs a find, capped at 100 documents
+func (s *session) runFind(collectionName string, filter interface{}) error {
+	ctx, tag, done := s.startQuery()
+	defer done()
+	start := time.Now()
+	cursor, err := s.db.Collection(collectionName).Find(ctx, filter, options.Find().SetLimit(defaultFindLimit).SetComment(tag))
+	if err != nil {
+		return errors.New(interrupt.Error(ctx, err))
+	}
+	return s.writeCursor(ctx, cursor, start)
+}
+
+// runs an aggregation pipeline
+func (s *session) runAggregate(collectionName string, pipeline interface{}) error {
+	ctx, tag, done := s.startQuery()
+	defer done()
+	start := time.Now()
+	cursor, err := s.db.Collection(collectionName).Aggregate(ctx, pipeline, options.Aggregate().SetComment(tag))
+	if err != nil {
+		return errors.New(interrupt.Error(ctx, err))
+	}
+	return s.writeCursor(ctx, cursor, start)
+}
+
+// decodes and writes everything in the cursor, then the count and timing
+func (s *session) writeCursor(ctx context.Context, cursor *mongo.Cursor, start time.Time) error {
+	defer cursor.Close(context.Background())
+
+	count, err := writeDocuments(ctx, cursor, s.display, s.view)
+	if err != nil {
+		if ctx.Err() != nil {
+			return errors.New(interrupt.Error(ctx, err))
 		}
+		return fmt.Errorf("error writing results: %v", err)
+	}
+	s.printCount(count, "documents")
+	s.printTiming(time.Since(start))
+	fmt.Fprintln(s.status)
+	return nil
+}
 
-		cursor.Close(ctx)
-		fmt.Println(strings.Repeat("-", 80))
-		fmt.Printf("%d documents returned\n\n", rowCount)
+// splits collection|filter into the collection name and the parsed filter
+func parseQuery(input string) (string, bson.M, error) {
+	// parse command format: collection|filter
+	parts := strings.SplitN(input, "|", 2)
+	if len(parts) != 2 {
+		return "", nil, fmt.Errorf("format error: use collection_name|{filter}")
 	}
+
+	collectionName := strings.TrimSpace(parts[0])
+	filterStr := strings.TrimSpace(parts[1])
+
+	// parse the filter as BSON
+	filter := bson.M{}
+	if filterStr != "{}" && filterStr != "" {
+		err := bson.UnmarshalExtJSON([]byte(filterStr), true, &filter)
+		if err != nil {
+			return "", nil, fmt.Errorf("filter parse error: %v", err)
+		}
+	}
+	return collectionName, filter, nil
 }
 
-// some example queries
-func printHelp() {
-	fmt.Println("\n=== Some example queries you can try ===")
-	fmt.Println("patrons|{}  // Get first 100 patrons")
-	fmt.Println("patrons|{\"within_sfc\": true}  // Find SF patrons")
-	fmt.Println("patrons|{\"age_range\": \"25 to 34 years\"}  // Find patrons by age")
-	fmt.Println("patrons|{\"email\": {\"$regex\": \"gmail.com\"}}  // Find gmail users")
-	fmt.Println("patron_types|{}  // List all patron types")
-	fmt.Println("libraries|{}  // List all libraries")
-	fmt.Println("\nType 'benchmark' to run performance tests")
+// writes rows that were put together in go rather than read from a cursor
+func (s *session) writeValues(cols []output.Column, rows [][]output.Value) {
+	if err := s.writeRows(cols, rows); err != nil {
+		fmt.Println(err)
+		return
+	}
+	s.printCount(len(rows), "rows")
 	fmt.Println()
 }
 
-// benchmark to test performance
-func runBenchmark(db *mongo.Database) {
-	fmt.Println("\n=== performance test ===")
-	ctx := context.Background()
-
-	tests := []struct {
-		name       string
-		collection string
-		pipeline   interface{}
-	}{
-		{
-			"count all patrons",
-			"patrons",
-			bson.M{},
-		},
-		{
-			"count by patron type",
-			"patrons",
-			mongo.Pipeline{
-				{{Key: "$group", Value: bson.M{
-					"_id":   "$patron_type_desc",
-					"count": bson.M{"$sum": 1},
-				}}},
-			},
-		},
-		{
-			"count by age range",
-			"patrons",
-			mongo.Pipeline{
-				{{Key: "$group", Value: bson.M{
-					"_id":   "$age_range",
-					"count": bson.M{"$sum": 1},
-				}}},
-			},
-		},
-		{
-			"count by library",
-			"patrons",
-			mongo.Pipeline{
-				{{Key: "$group", Value: bson.M{
-					"_id":   "$home_library_name",
-					"count": bson.M{"$sum": 1},
-				}}},
-			},
-		},
-		{
-			"find SF patrons",
-			"patrons",
-			bson.M{"within_sfc": true},
-		},
-		{
-			"active in 2023",
-			"patrons",
-			bson.M{"active_year": "2023"},
-		},
-	}
-
-	for _, test := range tests {
-		start := time.Now()
-
-		var count int
-		switch p := test.pipeline.(type) {
-		case mongo.Pipeline:
-			cursor, err := db.Collection(test.collection).Aggregate(ctx, p)
-			if err != nil {
-				fmt.Printf("%s: error - %v\n", test.name, err)
-				continue
-			}
-			for cursor.Next(ctx) {
-				count++
-			}
-			cursor.Close(ctx)
-		case bson.M:
-			c, err := db.Collection(test.collection).CountDocuments(ctx, p)
-			if err != nil {
-				fmt.Printf("%s: error - %v\n", test.name, err)
-				continue
-			}
-			count = int(c)
+// writes the rows in the current format without the count underneath.
+// rows aren't documents, so they come out as a table in the pretty format.
+func (s *session) writeRows(cols []output.Column, rows [][]output.Value) error {
+	var w output.Writer
+	var err error
+	if s.display.Format == prettyFormat {
+		w, err = output.New("table", s.display.Out(), output.Options{MaxWidth: s.display.MaxWidth, Expanded: s.display.Expanded})
+	} else {
+		w, err = s.display.NewWriter()
+	}
+	if err != nil {
+		return err
+	}
+	if err := w.Begin(cols); err != nil {
+		return fmt.Errorf("error writing results: %v", err)
+	}
+	for _, row := range rows {
+		if err := w.Row(row); err != nil {
+			return fmt.Errorf("error writing results: %v", err)
 		}
+	}
+	if err := w.End(); err != nil {
+		return fmt.Errorf("error writing results: %v", err)
+	}
+	return nil
+}
 
-		elapsed := time.Since(start)
-		fmt.Printf("%s: %v (%d results)\n", test.name, elapsed, count)
+// the line under the results, which says where they went if \o is on
+func (s *session) printCount(count int, what string) {
+	if file := s.display.Redirected(); file != "" {
+		fmt.Fprintf(s.status, "%d %s written to %s\n", count, what, file)
+	} else {
+		fmt.Fprintf(s.status, "%d %s returned\n", count, what)
 	}
+}
 
-	fmt.Println("\nbenchmark done")
+// with \timing on, how long the query took. this covers reading and
+// writing out all the documents, not just the time until the first one.
+func (s *session) printTiming(elapsed time.Duration) {
+	if s.timing {
+		fmt.Fprintf(s.status, "Time: %v\n", elapsed.Round(time.Microsecond))
+	}
+}
+
+// some example queries
+func printHelp(s *session) {
+	fmt.Println("\n=== Saved queries, run one with :run NAME key=value ... ===")
+	if len(s.saved) == 0 {
+		fmt.Println("(none loaded from", *queriesFile+")")
+	}
+	for _, q := range s.saved {
+		fmt.Printf(":run %s\n    %s\n", named.Usage(q.Name, q.Params), q.Description)
+	}
+	fmt.Println(":show NAME - print the pipeline of a saved query")
+	fmt.Println("\n=== Reports, run with :report [NAME ...] ===")
+	for _, r := range report.Reports {
+		fmt.Printf(":report %s\n    %s\n", r.Name, r.Title)
+	}
+	fmt.Println("\nPatron queries, the same on the mysql app:")
+	fmt.Println("patrons where within_sfc and active_year = 2023 group by home_library count")
+	fmt.Println("patrons where checkout_total >= 1000 show home_library, checkout_total order by checkout_total desc limit 10")
+	fmt.Println(":compile QUERY - print the pipeline a patron query turns into")
+	fmt.Println("\n=== Queries are typed like the mongo shell ===")
+	fmt.Println("db.patrons.find({within_sfc: true}, {email: 1, age_range: 1}).sort({age_range: 1}).skip(10).limit(5)")
+	fmt.Println("db.patrons.findOne({home_library_code: \"X0\"})")
+	fmt.Println("db.patrons.aggregate([{$group: {_id: \"$patron_type_desc\", count: {$sum: 1}}}, {$sort: {count: -1}}])")
+	fmt.Println("db.patrons.countDocuments({active_year: \"2023\"})")
+	fmt.Println("db.patrons.distinct(\"age_range\", {within_sfc: true})")
+	fmt.Println("db.patrons.estimatedDocumentCount()")
+	fmt.Println("find returns 100 documents unless it has a .limit(n), .limit(0) returns them all. add .explain() to see the plan")
+	fmt.Println("patrons|{\"within_sfc\": true} still works as a short way to write a find")
+	fmt.Println("\nType 'benchmark' to run performance tests, 'go run . bench' takes -runs, -warmup and -flush")
+	fmt.Println("\\timing - show how long each query takes")
+	fmt.Println("\\explain db.patrons.find(...) - show the query plan, flagging full scans and unused indexes")
+	fmt.Println("\\timeout 30s - stop queries that run longer than this, 0 means never. ctrl-c cancels a running query")
+	fmt.Println("\nSchema commands:")
+	fmt.Println("\\dt - list collections with their document counts")
+	fmt.Println("\\d COLLECTION - show the fields found in a sample of documents")
+	fmt.Println("\\di [COLLECTION] - list indexes")
+	fmt.Println("\nDisplay commands:")
+	for _, line := range s.display.Help() {
+		fmt.Println(line)
+	}
+	for _, line := range s.view.Help() {
+		fmt.Println(line)
+	}
+	fmt.Println()
 }
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector turns the counts poolStats keeps into prometheus metrics,
+// the mongo side of what collectors.NewDBStatsCollector gives the mysql app
+type poolCollector struct {
+	open, inUse, checkouts, failed, created, waited *prometheus.Desc
+}
+
+func newPoolCollector() *poolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("sfils_mongo_pool_"+name, help, nil, prometheus.Labels{"db_name": dbName})
+	}
+	return &poolCollector{
+		open:      desc("open_connections", "Connections the driver has open to mongodb."),
+		inUse:     desc("in_use_connections", "Connections checked out of the pool right now."),
+		checkouts: desc("checkouts_total", "Connections checked out of the pool."),
+		failed:    desc("checkout_failures_total", "Checkouts that didn't get a connection."),
+		created:   desc("connections_created_total", "Connections the pool has opened."),
+		waited:    desc("wait_seconds_total", "Time spent waiting for a connection from the pool."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.open, c.inUse, c.checkouts, c.failed, c.created, c.waited} {
+		ch <- d
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := pool.snapshot()
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(s.open))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(pool.inUse.Load()))
+	ch <- prometheus.MustNewConstMetric(c.checkouts, prometheus.CounterValue, float64(s.checkouts))
+	ch <- prometheus.MustNewConstMetric(c.failed, prometheus.CounterValue, float64(s.failed))
+	ch <- prometheus.MustNewConstMetric(c.created, prometheus.CounterValue, float64(s.created))
+	ch <- prometheus.MustNewConstMetric(c.waited, prometheus.CounterValue, s.waited.Seconds())
+}
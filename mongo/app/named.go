@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/interrupt"
+	"github.com/jacksongodsey/SFILS/shared/metrics"
+	"github.com/jacksongodsey/SFILS/shared/named"
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// namedQuery is a saved query from the queries file. it has either a
+// pipeline for an aggregation or a filter for a find.
+type namedQuery struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Collection  string          `json:"collection"`
+	Params      []named.Param   `json:"params"`
+	Pipeline    json.RawMessage `json:"pipeline"`
+	Filter      json.RawMessage `json:"filter"`
+}
+
+// reads saved queries from a json file, a list of objects like
+//
+//	{
+//	  "name": "top_libraries",
+//	  "description": "libraries with the most patrons",
+//	  "collection": "patrons",
+//	  "params": [{"name": "limit", "type": "int", "default": "10"}],
+//	  "pipeline": [..., {"$limit": {"$param": "limit"}}]
+//	}
+//
+// the pipeline or filter is extended json, and {"$param": "name"} is swapped
+// for the value of that param when the query runs.
+func loadNamedQueries(path string) ([]*namedQuery, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var queries []*namedQuery
+	if err := json.Unmarshal(data, &queries); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	// check everything up front so a typo shows up when the file loads
+	// rather than the first time someone runs that query
+	for _, q := range queries {
+		if q.Name == "" || q.Collection == "" {
+			return nil, fmt.Errorf("%s: every query needs a name and a collection", path)
+		}
+		if (q.Pipeline == nil) == (q.Filter == nil) {
+			return nil, fmt.Errorf("%s: %s needs either a pipeline or a filter", path, q.Name)
+		}
+		for _, p := range q.Params {
+			if p.Default != nil {
+				if _, err := p.Convert(*p.Default); err != nil {
+					return nil, fmt.Errorf("%s: default for %v", path, err)
+				}
+			}
+		}
+		if _, err := q.parse(); err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", path, q.Name, err)
+		}
+	}
+	return queries, nil
+}
+
+// finds a saved query by name
+func findNamedQuery(queries []*namedQuery, name string) *namedQuery {
+	for _, q := range queries {
+		if q.Name == name {
+			return q
+		}
+	}
+	return nil
+}
+
+// reads the pipeline or filter. extended json only unmarshals into a
+// document, so it gets wrapped in one first. decoding into bson.D keeps
+// the keys in order, which matters for things like $sort.
+func (q *namedQuery) parse() (interface{}, error) {
+	body := q.Filter
+	if q.Pipeline != nil {
+		body = q.Pipeline
+	}
+	var doc bson.D
+	wrapped := append(append([]byte(`{"q":`), body...), '}')
+	if err := bson.UnmarshalExtJSON(wrapped, false, &doc); err != nil {
+		return nil, err
+	}
+	return doc[0].Value, nil
+}
+
+// fills in the params and returns the pipeline or filter ready to send.
+// values go in as typed bson values, never as text pasted into the json.
+func (q *namedQuery) bind(args map[string]string) (interface{}, error) {
+	values, err := named.Resolve(q.Params, args)
+	if err != nil {
+		return nil, err
+	}
+	body, err := q.parse()
+	if err != nil {
+		return nil, err
+	}
+	return substitute(body, values, q.Name)
+}
+
+// walks the documents and arrays swapping {"$param": "name"} for its value
+func substitute(v interface{}, values map[string]interface{}, query string) (interface{}, error) {
+	switch t := v.(type) {
+	case bson.D:
+		if len(t) == 1 && t[0].Key == "$param" {
+			name, _ := t[0].Value.(string)
+			value, ok := values[name]
+			if !ok {
+				return nil, fmt.Errorf("query %s uses $param %q but doesn't declare it as a param", query, name)
+			}
+			return value, nil
+		}
+		out := make(bson.D, len(t))
+		for i, e := range t {
+			sub, err := substitute(e.Value, values, query)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = bson.E{Key: e.Key, Value: sub}
+		}
+		return out, nil
+	case bson.A:
+		out := make(bson.A, len(t))
+		for i, e := range t {
+			sub, err := substitute(e, values, query)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = sub
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// :run NAME key=value ..., :show NAME, :compile QUERY and :report [NAME ...]
+// in the text interface
+func (s *session) namedCommand(input string) {
+	if cmd, query, _ := strings.Cut(input, " "); cmd == ":compile" {
+		if strings.TrimSpace(query) == "" {
+			fmt.Println("usage: :compile patrons where ...")
+			return
+		}
+		s.showCompiled(query)
+		return
+	}
+	if cmd, names, _ := strings.Cut(input, " "); cmd == ":report" {
+		s.reportCommand(strings.Fields(names))
+		return
+	}
+
+	args, err := named.SplitArgs(input)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	cmd, args := args[0], args[1:]
+
+	if cmd != ":run" && cmd != ":show" {
+		fmt.Println("unknown command:", cmd)
+		return
+	}
+	if len(args) == 0 {
+		fmt.Printf("usage: %s NAME, type 'help' to see the saved queries\n", cmd)
+		return
+	}
+	q := findNamedQuery(s.saved, args[0])
+	if q == nil {
+		fmt.Printf("no saved query called %q, type 'help' to see them\n", args[0])
+		return
+	}
+
+	if cmd == ":show" {
+		fmt.Printf("// %s\n", q.Description)
+		for _, p := range q.Params {
+			if p.Default != nil {
+				fmt.Printf("// param: %s %s %s\n", p.Name, p.Type, *p.Default)
+			} else {
+				fmt.Printf("// param: %s %s\n", p.Name, p.Type)
+			}
+		}
+		kind, body := "find", q.Filter
+		if q.Pipeline != nil {
+			kind, body = "aggregate", q.Pipeline
+		}
+		fmt.Printf("%s %s %s\n\n", q.Collection, kind, strings.TrimSpace(string(body)))
+		return
+	}
+
+	if err := s.runNamed(q, args[1:]); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// binds the arguments and runs a saved query
+func (s *session) runNamed(q *namedQuery, args []string) error {
+	values, err := named.ParseArgs(args)
+	if err != nil {
+		return err
+	}
+	body, err := q.bind(values)
+	if err != nil {
+		return fmt.Errorf("%v\nusage: %s", err, named.Usage(q.Name, q.Params))
+	}
+	if q.Pipeline != nil {
+		if err := s.collections.checkPipeline(q.Collection, body.(bson.A)); err != nil {
+			return err
+		}
+		if stage := writeStage(body.(bson.A)); stage != "" && !s.allowWrites {
+			return fmt.Errorf("%s uses %s, which isn't allowed in read-only mode. use --allow-writes", q.Name, stage)
+		}
+		done := metrics.Query("saved", q.Name)
+		err = s.runAggregate(q.Collection, body)
+		done(err)
+		return err
+	}
+	if err := s.collections.check(q.Collection); err != nil {
+		return err
+	}
+	done := metrics.Query("saved", q.Name)
+	err = s.runFind(q.Collection, body)
+	done(err)
+	return err
+}
+
+// the query command: runs one saved query or collection|filter find, prints
+// the documents to stdout and exits, so it can be used from scripts
+//
+//	go run . query top_libraries limit=5
+//	go run . query -format table 'db.patrons.find({within_sfc: true}).limit(5)'
+func runQueryCommand(db *mongo.Database, args []string) error {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	format := flags.String("format", "jsonl", "output format ("+strings.Join(output.Formats, ", ")+", "+prettyFormat+")")
+	canonical := flags.Bool("canonical", false, "write canonical extended json instead of relaxed")
+	flatten := flags.Bool("flatten", false, "give table-like formats a column for every dotted field path")
+	timeout := flags.Duration("timeout", interrupt.DefaultTimeout, "stop the query after this long, 0 means never")
+	timing := flags.Bool("timing", false, "print how long the query took")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . query [flags] NAME [key=value ...]")
+		fmt.Fprintln(flags.Output(), "       go run . query [flags] 'db.collection.find({...})'")
+		fmt.Fprintln(flags.Output(), "       go run . query [flags] 'collection|{filter}'")
+		fmt.Fprintln(flags.Output(), "       go run . query [flags] patrons where ...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if !output.IsFormat(*format) && *format != prettyFormat {
+		return fmt.Errorf("unknown format %q, use one of %s, %s", *format, strings.Join(output.Formats, ", "), prettyFormat)
+	}
+
+	s := &session{
+		db:          db,
+		display:     output.NewSettings(),
+		view:        newDocumentView(),
+		collections: newCollectionGuard(db, *collections),
+		allowWrites: *allowWrites,
+		timeout:     *timeout,
+		timing:      *timing,
+		status:      os.Stderr,
+	}
+	s.display.Format = *format
+	s.view.Canonical = *canonical
+	s.view.Flatten = *flatten
+
+	saved, err := loadNamedQueries(*queriesFile)
+	if q := findNamedQuery(saved, flags.Arg(0)); q != nil {
+		return s.runNamed(q, flags.Args()[1:])
+	}
+
+	// not a saved query, so it should be db.collection.method(...), collection|filter
+	// or a patron query
+	query := strings.Join(flags.Args(), " ")
+	if !strings.HasPrefix(query, "db.") && !strings.Contains(query, "|") && !dsl.IsQuery(query) {
+		if err != nil {
+			return fmt.Errorf("couldn't load saved queries: %v", err)
+		}
+		return fmt.Errorf("no saved query called %q", flags.Arg(0))
+	}
+	return s.runQuery(query)
+}
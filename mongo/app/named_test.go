@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jacksongodsey/SFILS/shared/named"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBind(t *testing.T) {
+	ten := "10"
+	params := []named.Param{
+		{Name: "library", Type: "string"},
+		{Name: "limit", Type: "int", Default: &ten},
+		{Name: "sf", Type: "bool"},
+	}
+
+	tests := []struct {
+		name string
+		args map[string]string
+		body string
+		want interface{}
+	}{
+		{
+			name: "params in a pipeline",
+			args: map[string]string{"library": "X", "sf": "true"},
+			body: `[{"$match": {"home_library_code": {"$param": "library"}, "within_sfc": {"$param": "sf"}}}, {"$limit": {"$param": "limit"}}]`,
+			want: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{{Key: "home_library_code", Value: "X"}, {Key: "within_sfc", Value: true}}}},
+				bson.D{{Key: "$limit", Value: int64(10)}},
+			},
+		},
+		{
+			name: "params in arrays",
+			args: map[string]string{"library": "X", "sf": "false", "limit": "3"},
+			body: `[{"$match": {"home_library_code": {"$in": [{"$param": "library"}, "M2"]}}}, {"$limit": {"$param": "limit"}}]`,
+			want: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{{Key: "home_library_code", Value: bson.D{{Key: "$in", Value: bson.A{"X", "M2"}}}}}}},
+				bson.D{{Key: "$limit", Value: int64(3)}},
+			},
+		},
+		{
+			name: "values stay values, even ones that look like operators",
+			args: map[string]string{"library": `{"$gt": ""}`, "sf": "true"},
+			body: `[{"$match": {"home_library_code": {"$param": "library"}}}]`,
+			want: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{{Key: "home_library_code", Value: `{"$gt": ""}`}}}},
+			},
+		},
+		{
+			name: "only a document with just $param is swapped",
+			args: map[string]string{"library": "X", "sf": "true"},
+			body: `[{"$match": {"a": {"$param": "library", "b": 1}, "c": "$param", "$param": "library"}}]`,
+			want: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{
+					{Key: "a", Value: bson.D{{Key: "$param", Value: "library"}, {Key: "b", Value: int32(1)}}},
+					{Key: "c", Value: "$param"},
+					{Key: "$param", Value: "library"},
+				}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &namedQuery{Name: "q", Collection: "patrons", Params: params, Pipeline: json.RawMessage(tt.body)}
+			got, err := q.bind(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("bind\n got %#v\nwant %#v", got, tt.want)
+			}
+		})
+	}
+
+	// a filter instead of a pipeline
+	q := &namedQuery{Name: "q", Collection: "patrons", Params: params, Filter: json.RawMessage(`{"within_sfc": {"$param": "sf"}}`)}
+	got, err := q.bind(map[string]string{"library": "X", "sf": "false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (bson.D{{Key: "within_sfc", Value: false}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("filter %#v, want %#v", got, want)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	params := []named.Param{{Name: "limit", Type: "int"}}
+	tests := []struct {
+		body string
+		args map[string]string
+		err  string
+	}{
+		{`[{"$limit": {"$param": "limit"}}]`, map[string]string{"limit": "many"}, "limit has to be a whole number"},
+		{`[{"$limit": {"$param": "limit"}}]`, map[string]string{}, "missing limit (int)"},
+		{`[{"$limit": {"$param": "limit"}}]`, map[string]string{"limit": "1", "skip": "2"}, "unknown parameter skip"},
+		{`[{"$skip": {"$param": "skip"}}]`, map[string]string{"limit": "1"}, `uses $param "skip" but doesn't declare it`},
+	}
+	for _, tt := range tests {
+		q := &namedQuery{Name: "q", Collection: "patrons", Params: params, Pipeline: json.RawMessage(tt.body)}
+		_, err := q.bind(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("bind(%s, %v): %v, want %q", tt.body, tt.args, err, tt.err)
+		}
+	}
+}
+
+func TestLoadNamedQueries(t *testing.T) {
+	tests := []struct {
+		file string
+		err  string
+	}{
+		{`[{"name": "q", "collection": "patrons", "filter": {}}]`, ""},
+		{`[{"name": "q", "filter": {}}]`, "every query needs a name and a collection"},
+		{`[{"name": "q", "collection": "patrons"}]`, "q needs either a pipeline or a filter"},
+		{`[{"name": "q", "collection": "patrons", "filter": {}, "pipeline": []}]`, "q needs either a pipeline or a filter"},
+		{`[{"name": "q", "collection": "patrons", "filter": {}, "params": [{"name": "n", "type": "int", "default": "x"}]}]`, "n has to be a whole number"},
+		{`[{"name": "q", "collection": "patrons", "filter": {"_id": {"$oid": "nope"}}}]`, "q:"},
+		{`[{"name": "q",`, "unexpected end of JSON"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "named.json")
+		if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadNamedQueries(path)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.file, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: %v, want %q", tt.file, err, tt.err)
+		}
+	}
+
+	// the saved queries that ship with the app load and bind with their defaults
+	shipped, err := loadNamedQueries("../queries/named.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	examples := map[string]string{"int": "1", "float": "1.5", "bool": "true", "string": "x"}
+	for _, q := range shipped {
+		args := map[string]string{}
+		for _, p := range q.Params {
+			if p.Default == nil {
+				args[p.Name] = examples[p.Type]
+			}
+		}
+		if _, err := q.bind(args); err != nil {
+			t.Errorf("%s: %v", q.Name, err)
+		}
+	}
+}
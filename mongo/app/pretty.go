@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// the format that prints each document as indented extended json. it's only
+// for documents so it lives here rather than with the shared formats.
+const prettyFormat = "pretty"
+
+// documentView is how documents are shown, on top of the shared display settings
+type documentView struct {
+	// canonical extended json keeps the exact bson types, like {"$numberInt": "5"}.
+	// relaxed writes plain json numbers and dates and is easier to read.
+	Canonical bool
+
+	// in the pretty format arrays longer than MaxItems are cut short and
+	// documents nested deeper than MaxDepth are folded up. 0 shows everything.
+	MaxItems int
+	MaxDepth int
+
+	// table-like formats get a column for every dotted path, like
+	// address.city, instead of one column per top level field
+	Flatten bool
+}
+
+func newDocumentView() *documentView {
+	return &documentView{MaxItems: 10, MaxDepth: 4}
+}
+
+// handles \format pretty, \ejson, \items, \depth and \flatten. returns false
+// for anything else so the shared display commands get a go.
+func (v *documentView) Command(cmd string, args []string, display *output.Settings) bool {
+	switch cmd {
+	case `\format`:
+		if len(args) == 0 || args[0] != prettyFormat {
+			return false
+		}
+		display.Format = prettyFormat
+		fmt.Println("output format is", display.Format)
+	case `\ejson`:
+		if len(args) > 0 {
+			switch args[0] {
+			case "relaxed", "canonical":
+				v.Canonical = args[0] == "canonical"
+			default:
+				fmt.Println(`usage: \ejson [relaxed|canonical]`)
+				return true
+			}
+		}
+		if v.Canonical {
+			fmt.Println("extended json is canonical")
+		} else {
+			fmt.Println("extended json is relaxed")
+		}
+	case `\items`, `\depth`:
+		if len(args) != 1 {
+			fmt.Printf("usage: %s N (0 shows everything)\n", cmd)
+			return true
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			fmt.Println("that has to be a number 0 or higher")
+			return true
+		}
+		if cmd == `\items` {
+			v.MaxItems = n
+			fmt.Println("arrays show", describeLimit(n, "items"))
+		} else {
+			v.MaxDepth = n
+			fmt.Println("documents show", describeLimit(n, "levels"))
+		}
+	case `\flatten`:
+		switch {
+		case len(args) == 0:
+			v.Flatten = !v.Flatten
+		case args[0] == "on" || args[0] == "off":
+			v.Flatten = args[0] == "on"
+		default:
+			fmt.Println(`usage: \flatten [on|off]`)
+			return true
+		}
+		if v.Flatten {
+			fmt.Println("tables have a column for every dotted field path")
+		} else {
+			fmt.Println("tables have a column for every top level field")
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// Help describes the commands Command understands
+func (v *documentView) Help() []string {
+	return []string{
+		`\format pretty - indented documents, the default`,
+		`\ejson relaxed|canonical - which extended json to print, canonical keeps the exact types`,
+		`\items N - cut arrays short after N items in pretty output, 0 shows everything`,
+		`\depth N - fold up documents nested deeper than N in pretty output, 0 shows everything`,
+		`\flatten [on|off] - give tables a column for every dotted path like address.city`,
+	}
+}
+
+func describeLimit(n int, what string) string {
+	if n == 0 {
+		return "all their " + what
+	}
+	return fmt.Sprintf("up to %d %s", n, what)
+}
+
+// writes one document as indented extended json, keeping the fields in the
+// order they're stored. folded parts say how much was left out, so the
+// output is for reading rather than for parsing, that's what json is for.
+func (v *documentView) writePretty(out io.Writer, doc bson.D) error {
+	var b strings.Builder
+	v.prettyValue(&b, doc, "", 0)
+	b.WriteString("\n")
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+func (v *documentView) prettyValue(b *strings.Builder, val interface{}, indent string, depth int) {
+	switch t := val.(type) {
+	case bson.D:
+		if len(t) == 0 {
+			b.WriteString("{}")
+			return
+		}
+		if v.MaxDepth > 0 && depth >= v.MaxDepth {
+			fmt.Fprintf(b, "{ … %d %s }", len(t), plural(len(t), "field", "fields"))
+			return
+		}
+		b.WriteString("{\n")
+		for i, e := range t {
+			b.WriteString(indent + "  " + jsonQuote(e.Key) + ": ")
+			v.prettyValue(b, e.Value, indent+"  ", depth+1)
+			if i < len(t)-1 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(indent + "}")
+	case bson.A:
+		if len(t) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		shown := t
+		if v.MaxItems > 0 && len(t) > v.MaxItems {
+			shown = t[:v.MaxItems]
+		}
+		more := ""
+		if len(shown) < len(t) {
+			more = fmt.Sprintf("… %d more", len(t)-len(shown))
+		}
+
+		// arrays of plain values stay on one line, anything holding
+		// documents or arrays gets a line per item
+		nested := false
+		for _, item := range shown {
+			switch item.(type) {
+			case bson.D, bson.A:
+				nested = true
+			}
+		}
+		if !nested {
+			parts := make([]string, 0, len(shown)+1)
+			for _, item := range shown {
+				parts = append(parts, v.extJSON(item))
+			}
+			if more != "" {
+				parts = append(parts, more)
+			}
+			b.WriteString("[" + strings.Join(parts, ", ") + "]")
+			return
+		}
+
+		b.WriteString("[\n")
+		for i, item := range shown {
+			b.WriteString(indent + "  ")
+			v.prettyValue(b, item, indent+"  ", depth+1)
+			if i < len(shown)-1 || more != "" {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		if more != "" {
+			b.WriteString(indent + "  " + more + "\n")
+		}
+		b.WriteString(indent + "]")
+	default:
+		b.WriteString(v.extJSON(val))
+	}
+}
+
+// a single value as relaxed or canonical extended json
+func (v *documentView) extJSON(val interface{}) string {
+	return extJSON(val, v.Canonical)
+}
+
+// turns address: {city: ...} into address.city so every leaf gets its own
+// column. arrays are left whole since they don't have a fixed shape.
+func flattenDocument(doc bson.D, prefix string) bson.D {
+	var out bson.D
+	for _, e := range doc {
+		key := prefix + e.Key
+		if sub, ok := e.Value.(bson.D); ok && len(sub) > 0 {
+			out = append(out, flattenDocument(sub, key+".")...)
+			continue
+		}
+		out = append(out, bson.E{Key: key, Value: e.Value})
+	}
+	return out
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/interrupt"
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"github.com/jacksongodsey/SFILS/shared/report"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// runs the pipeline for a report and collects its rows instead of printing them
+func (s *session) reportRows(q *dsl.Query) ([][]output.Value, error) {
+	pipeline, err := pipelineOf(q)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.collections.checkPipeline("patrons", pipeline); err != nil {
+		return nil, err
+	}
+
+	ctx, tag, done := s.startQuery()
+	defer done()
+	cursor, err := s.db.Collection("patrons").Aggregate(ctx, pipeline, options.Aggregate().SetComment(tag))
+	if err != nil {
+		return nil, errors.New(interrupt.Error(ctx, err))
+	}
+	var docs []bson.D
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, errors.New(interrupt.Error(ctx, err))
+	}
+
+	var cols []output.Column
+	for _, name := range q.Columns() {
+		cols = append(cols, output.Column{Name: name})
+	}
+	rows := make([][]output.Value, len(docs))
+	for i, doc := range docs {
+		rows[i] = documentRow(doc, cols)
+	}
+	return rows, nil
+}
+
+// runs the named reports, or all of them, and writes them out
+func (s *session) runReports(names []string, format string) error {
+	reports, err := report.Select(names)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	var results []*report.Result
+	for _, r := range reports {
+		res, err := report.Run(r, s.reportRows)
+		if err != nil {
+			return err
+		}
+		results = append(results, res)
+	}
+	if err := report.Write(s.display.Out(), format, results); err != nil {
+		return fmt.Errorf("error writing results: %v", err)
+	}
+	s.printTiming(time.Since(start))
+	return nil
+}
+
+// :report [NAME ...] in the text interface. json and markdown are kept from
+// \format, anything else is shown as tables.
+func (s *session) reportCommand(names []string) {
+	format := s.display.Format
+	if !report.IsFormat(format) {
+		format = "table"
+	}
+	if err := s.runReports(names, format); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// the report command: runs the built-in reports and prints them to stdout
+//
+//	go run . report
+//	go run . report -format markdown libraries ages > reports.md
+func runReportCommand(db *mongo.Database, args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	format := flags.String("format", "table", "output format ("+strings.Join(report.Formats, ", ")+")")
+	timeout := flags.Duration("timeout", interrupt.DefaultTimeout, "stop each report's query after this long, 0 means never")
+	timing := flags.Bool("timing", false, "print how long the reports took")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . report [flags] [NAME ...]")
+		fmt.Fprintln(flags.Output(), "reports:", strings.Join(report.Names(), ", "))
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if !report.IsFormat(*format) {
+		return fmt.Errorf("unknown format %q, use one of %s", *format, strings.Join(report.Formats, ", "))
+	}
+
+	s := &session{
+		db:          db,
+		display:     output.NewSettings(),
+		collections: newCollectionGuard(db, *collections),
+		timeout:     *timeout,
+		timing:      *timing,
+		status:      os.Stderr,
+	}
+	return s.runReports(flags.Args(), *format)
+}
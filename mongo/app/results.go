@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// how many documents we look at to work out the columns for the table-like
+// formats. fields that only show up after this are left out.
+const columnSampleDocs = 1000
+
+// writes every document from the cursor in the current output format and
+// returns how many were written
+func writeDocuments(ctx context.Context, cursor *mongo.Cursor, display *output.Settings, view *documentView) (int, error) {
+	switch display.Format {
+	case "json", "jsonl":
+		return writeJSONDocuments(ctx, cursor, display.Out(), display.Format == "jsonl", view.Canonical)
+	case prettyFormat:
+		count := 0
+		for cursor.Next(ctx) {
+			var doc bson.D
+			if err := cursor.Decode(&doc); err != nil {
+				return count, fmt.Errorf("decode error: %v", err)
+			}
+			if err := view.writePretty(display.Out(), doc); err != nil {
+				return count, err
+			}
+			count++
+		}
+		return count, cursor.Err()
+	}
+
+	w, err := display.NewWriter()
+	if err != nil {
+		return 0, err
+	}
+
+	// hold on to the first batch of documents so we know what the columns are
+	var sample []bson.D
+	for len(sample) < columnSampleDocs && cursor.Next(ctx) {
+		var doc bson.D
+		if err := cursor.Decode(&doc); err != nil {
+			return 0, fmt.Errorf("decode error: %v", err)
+		}
+		if view.Flatten {
+			doc = flattenDocument(doc, "")
+		}
+		sample = append(sample, doc)
+	}
+
+	cols := documentColumns(sample)
+	if err := w.Begin(cols); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, doc := range sample {
+		if err := w.Row(documentRow(doc, cols)); err != nil {
+			return count, err
+		}
+		count++
+	}
+	for cursor.Next(ctx) {
+		var doc bson.D
+		if err := cursor.Decode(&doc); err != nil {
+			return count, fmt.Errorf("decode error: %v", err)
+		}
+		if view.Flatten {
+			doc = flattenDocument(doc, "")
+		}
+		if err := w.Row(documentRow(doc, cols)); err != nil {
+			return count, err
+		}
+		count++
+	}
+	// the rows so far still get printed when the cursor fails, the table
+	// would be cut off with the sampled rows lost otherwise
+	if err := w.End(); err != nil {
+		return count, err
+	}
+	return count, cursor.Err()
+}
+
+// json is an array of documents and jsonl is one document per line, both in
+// relaxed or canonical extended json
+func writeJSONDocuments(ctx context.Context, cursor *mongo.Cursor, out io.Writer, lines, canonical bool) (int, error) {
+	if !lines {
+		fmt.Fprint(out, "[")
+	}
+
+	count := 0
+	for cursor.Next(ctx) {
+		var doc bson.D
+		if err := cursor.Decode(&doc); err != nil {
+			return count, fmt.Errorf("decode error: %v", err)
+		}
+		data, err := bson.MarshalExtJSON(doc, canonical, false)
+		if err != nil {
+			return count, err
+		}
+
+		switch {
+		case lines:
+			fmt.Fprintln(out, string(data))
+		case count == 0:
+			fmt.Fprint(out, "\n  "+string(data))
+		default:
+			fmt.Fprint(out, ",\n  "+string(data))
+		}
+		count++
+	}
+
+	if !lines {
+		if count > 0 {
+			fmt.Fprint(out, "\n")
+		}
+		fmt.Fprintln(out, "]")
+	}
+	return count, cursor.Err()
+}
+
+// the columns are every top level field in the order they first show up. a
+// column counts as numeric when all the values we saw in it were numbers.
+func documentColumns(docs []bson.D) []output.Column {
+	var cols []output.Column
+	index := map[string]int{}
+	seen := map[string]bool{}
+
+	for _, doc := range docs {
+		for _, e := range doc {
+			i, ok := index[e.Key]
+			if !ok {
+				i = len(cols)
+				index[e.Key] = i
+				cols = append(cols, output.Column{Name: e.Key, Numeric: true})
+			}
+
+			v := bsonValue(e.Value)
+			if v.Kind == output.Null {
+				continue
+			}
+			seen[e.Key] = true
+			if v.Kind != output.Number {
+				cols[i].Numeric = false
+			}
+		}
+	}
+
+	// a column that was only ever null isn't really a number column
+	for i := range cols {
+		if !seen[cols[i].Name] {
+			cols[i].Numeric = false
+		}
+	}
+	return cols
+}
+
+// lines a document up with the columns, missing fields come out as null
+func documentRow(doc bson.D, cols []output.Column) []output.Value {
+	fields := make(map[string]interface{}, len(doc))
+	for _, e := range doc {
+		fields[e.Key] = e.Value
+	}
+
+	vals := make([]output.Value, len(cols))
+	for i, col := range cols {
+		vals[i] = bsonValue(fields[col.Name])
+	}
+	return vals
+}
+
+// turns a decoded bson value into something the output writers understand.
+// anything nested is kept as compact extended json.
+func bsonValue(val interface{}) output.Value {
+	switch v := val.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return output.Value{Kind: output.Null}
+	case string:
+		return output.Value{Kind: output.String, Text: v}
+	case int32:
+		return output.Value{Kind: output.Number, Text: strconv.FormatInt(int64(v), 10)}
+	case int64:
+		return output.Value{Kind: output.Number, Text: strconv.FormatInt(v, 10)}
+	case float64:
+		return output.Value{Kind: output.Number, Text: strconv.FormatFloat(v, 'g', -1, 64)}
+	case primitive.Decimal128:
+		return output.Value{Kind: output.Number, Text: v.String()}
+	case bool:
+		return output.Value{Kind: output.Bool, Text: strconv.FormatBool(v)}
+	case primitive.ObjectID:
+		return output.Value{Kind: output.String, Text: v.Hex()}
+	case primitive.DateTime:
+		return output.Value{Kind: output.String, Text: v.Time().UTC().Format(time.RFC3339)}
+	default:
+		return output.Value{Kind: output.JSON, Text: compactExtJSON(v)}
+	}
+}
+
+// a value as relaxed extended json on one line
+func compactExtJSON(val interface{}) string {
+	return extJSON(val, false)
+}
+
+// MarshalExtJSON only takes documents so the value gets wrapped and then
+// unwrapped again
+func extJSON(val interface{}, canonical bool) string {
+	data, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: val}}, canonical, false)
+	if err != nil {
+		return fmt.Sprint(val)
+	}
+	s := strings.TrimPrefix(string(data), `{"v":`)
+	return strings.TrimSuffix(s, "}")
+}
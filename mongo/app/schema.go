@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// how many documents \d looks at to guess the fields of a collection
+const schemaSampleDocs = 1000
+
+// \dt - every collection with its document count
+func (s *session) listCollections() {
+	ctx := context.Background()
+	names, err := s.collections.list()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	var values [][]output.Value
+	for _, name := range names {
+		count, err := s.db.Collection(name).EstimatedDocumentCount(ctx)
+		if err != nil {
+			fmt.Printf("couldn't count %s: %v\n", name, err)
+			continue
+		}
+		values = append(values, []output.Value{
+			{Kind: output.String, Text: name},
+			{Kind: output.Number, Text: strconv.FormatInt(count, 10)},
+		})
+	}
+
+	s.writeValues([]output.Column{{Name: "collection"}, {Name: "documents", Numeric: true}}, values)
+}
+
+// \di [collection] - indexes, for one collection or all of them
+func (s *session) listIndexes(collection string) {
+	ctx := context.Background()
+
+	names := []string{collection}
+	if collection == "" {
+		var err error
+		names, err = s.collections.list()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	} else if err := s.collections.check(collection); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	var values [][]output.Value
+	for _, name := range names {
+		cursor, err := s.db.Collection(name).Indexes().List(ctx)
+		if err != nil {
+			fmt.Printf("couldn't list indexes on %s: %v\n", name, err)
+			continue
+		}
+
+		var indexes []bson.M
+		if err := cursor.All(ctx, &indexes); err != nil {
+			fmt.Printf("couldn't read indexes on %s: %v\n", name, err)
+			continue
+		}
+		for _, idx := range indexes {
+			unique := "no"
+			if u, ok := idx["unique"].(bool); ok && u {
+				unique = "yes"
+			}
+			values = append(values, []output.Value{
+				{Kind: output.String, Text: name},
+				{Kind: output.String, Text: fmt.Sprint(idx["name"])},
+				{Kind: output.JSON, Text: compactExtJSON(idx["key"])},
+				{Kind: output.String, Text: unique},
+			})
+		}
+	}
+
+	s.writeValues([]output.Column{{Name: "collection"}, {Name: "index"}, {Name: "keys"}, {Name: "unique"}}, values)
+}
+
+// fieldInfo is what \d found out about one field
+type fieldInfo struct {
+	path  string
+	types map[string]int
+	count int
+}
+
+// \d collection - there's no fixed schema so we sample some documents and
+// report every field we see, the types it had and how often it was there
+func (s *session) describeCollection(collection string) {
+	ctx := context.Background()
+
+	if err := s.collections.check(collection); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$sample", Value: bson.M{"size": schemaSampleDocs}}}}
+	cursor, err := s.db.Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		fmt.Println("query error:", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	fields := map[string]*fieldInfo{}
+	var order []string
+	sampled := 0
+	for cursor.Next(ctx) {
+		var doc bson.D
+		if err := cursor.Decode(&doc); err != nil {
+			fmt.Println("decode error:", err)
+			continue
+		}
+		collectFields(doc, "", fields, &order)
+		sampled++
+	}
+
+	var values [][]output.Value
+	for _, path := range order {
+		f := fields[path]
+
+		types := make([]string, 0, len(f.types))
+		for t := range f.types {
+			types = append(types, t)
+		}
+		sort.Slice(types, func(i, j int) bool { return f.types[types[i]] > f.types[types[j]] })
+
+		values = append(values, []output.Value{
+			{Kind: output.String, Text: path},
+			{Kind: output.String, Text: strings.Join(types, ", ")},
+			{Kind: output.Number, Text: fmt.Sprintf("%.1f", 100*float64(f.count)/float64(sampled))},
+		})
+	}
+
+	fmt.Printf("fields seen in %d sampled documents from %s\n", sampled, collection)
+	s.writeValues([]output.Column{{Name: "field"}, {Name: "types"}, {Name: "present %", Numeric: true}}, values)
+}
+
+// walks a document and records each field as a dotted path. arrays of
+// documents are walked too so their fields show up as path.field.
+func collectFields(doc bson.D, prefix string, fields map[string]*fieldInfo, order *[]string) {
+	for _, e := range doc {
+		path := prefix + e.Key
+		f, ok := fields[path]
+		if !ok {
+			f = &fieldInfo{path: path, types: map[string]int{}}
+			fields[path] = f
+			*order = append(*order, path)
+		}
+		f.count++
+		f.types[bsonTypeName(e.Value)]++
+
+		switch v := e.Value.(type) {
+		case bson.D:
+			collectFields(v, path+".", fields, order)
+		case bson.A:
+			for _, item := range v {
+				if sub, ok := item.(bson.D); ok {
+					collectFields(sub, path+".", fields, order)
+				}
+			}
+		}
+	}
+}
+
+// the names the mongo shell uses for each type
+func bsonTypeName(val interface{}) string {
+	switch val.(type) {
+	case nil, primitive.Null:
+		return "null"
+	case string:
+		return "string"
+	case int32:
+		return "int"
+	case int64:
+		return "long"
+	case float64:
+		return "double"
+	case primitive.Decimal128:
+		return "decimal"
+	case bool:
+		return "bool"
+	case primitive.ObjectID:
+		return "objectId"
+	case primitive.DateTime:
+		return "date"
+	case bson.D:
+		return "object"
+	case bson.A:
+		return "array"
+	}
+	return fmt.Sprintf("%T", val)
+}
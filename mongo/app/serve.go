@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jacksongodsey/SFILS/shared/api"
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"github.com/jacksongodsey/SFILS/shared/rpc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// the serve command: the patron data as a json api for other services, see
+// the api package for the endpoints
+//
+//	go run . serve -addr :8080
+func runServeCommand(db *mongo.Database, args []string) error {
+	return api.Command(apiBackend(db), args)
+}
+
+// the grpc command: the patron data over grpc for the data-sync jobs, see
+// the rpc package
+//
+//	go run . grpc -addr :9090
+func runGRPCCommand(db *mongo.Database, args []string) error {
+	return rpc.Command(rpc.Backend{
+		Backend: apiBackend(db),
+		Import: func(rows [][]string) (int, int, error) {
+			return importRows(db, rows)
+		},
+	}, args)
+}
+
+// how the api and the grpc server read the database
+func apiBackend(db *mongo.Database) api.Backend {
+	return api.Backend{
+		Name: "mongodb",
+		Query: func(ctx context.Context, q *dsl.Query) ([][]output.Value, error) {
+			return pipelineRows(ctx, db, q)
+		},
+		Patrons: func(ctx context.Context, where dsl.Expr, after string, limit int) ([]api.Patron, error) {
+			return listPatrons(ctx, db, where, after, limit)
+		},
+		Lookup: func(ctx context.Context, table string, codes []string) (map[string]string, error) {
+			return lookupNames(ctx, db, table, codes)
+		},
+	}
+}
+
+// the field with the name in each lookup collection
+var lookupFields = map[string]string{
+	"libraries":          "name",
+	"patron_types":       "description",
+	"notification_types": "description",
+}
+
+// the names of codes in a lookup collection for graphql, the whole
+// collection when codes is nil
+func lookupNames(ctx context.Context, db *mongo.Database, collection string, codes []string) (map[string]string, error) {
+	field, ok := lookupFields[collection]
+	if !ok {
+		return nil, fmt.Errorf("%s isn't a lookup collection", collection)
+	}
+	filter := bson.D{}
+	if codes != nil {
+		filter = bson.D{{Key: "code", Value: bson.D{{Key: "$in", Value: codes}}}}
+	}
+	cursor, err := db.Collection(collection).Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var docs []bson.M
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	names := map[string]string{}
+	for _, d := range docs {
+		code, _ := d["code"].(string)
+		name, _ := d[field].(string)
+		names[code] = name
+	}
+	return names, nil
+}
+
+// runs a patron query and collects its rows
+func pipelineRows(ctx context.Context, db *mongo.Database, q *dsl.Query) ([][]output.Value, error) {
+	pipeline, err := pipelineOf(q)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := db.Collection("patrons").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var docs []bson.D
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	var cols []output.Column
+	for _, name := range q.Columns() {
+		cols = append(cols, output.Column{Name: name})
+	}
+	rows := make([][]output.Value, len(docs))
+	for i, doc := range docs {
+		rows[i] = documentRow(doc, cols)
+	}
+	return rows, nil
+}
+
+// a page of patrons for /patrons. the ids are object ids, which go up in the
+// order the documents were inserted, so the next page starts after the last one.
+func listPatrons(ctx context.Context, db *mongo.Database, where dsl.Expr, after string, limit int) ([]api.Patron, error) {
+	filter := bson.D{}
+	if after != "" {
+		id, err := primitive.ObjectIDFromHex(after)
+		if err != nil {
+			return nil, api.ErrBadCursor
+		}
+		filter = bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: id}}}}
+	}
+	if where != nil {
+		var match bson.D
+		if err := bson.UnmarshalExtJSON([]byte(dsl.Filter(where)), false, &match); err != nil {
+			return nil, fmt.Errorf("couldn't read the compiled filter: %v", err)
+		}
+		filter = bson.D{{Key: "$and", Value: bson.A{filter, match}}}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := db.Collection("patrons").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		ID     primitive.ObjectID `bson:"_id"`
+		Patron `bson:",inline"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	patrons := make([]api.Patron, len(docs))
+	for i, d := range docs {
+		p := api.Patron{
+			ID:               d.ID.Hex(),
+			PatronType:       d.PatronTypeDesc,
+			PatronTypeCode:   d.PatronTypeCode,
+			AgeRange:         d.AgeRange,
+			HomeLibrary:      d.HomeLibraryName,
+			HomeLibraryCode:  d.HomeLibraryCode,
+			CheckoutTotal:    api.Number(d.CheckoutTotal),
+			RenewalTotal:     api.Number(d.RenewalTotal),
+			Notification:     d.NotificationTypeDesc,
+			NotificationCode: d.NotificationTypeCode,
+			Email:            d.Email,
+			WithinSFC:        d.WithinSFC,
+		}
+		if d.ActiveMonth != nil {
+			month := int64(*d.ActiveMonth)
+			p.ActiveMonth = &month
+		}
+		if d.ActiveYear != nil {
+			p.ActiveYear = api.Number(*d.ActiveYear)
+		}
+		if d.YearRegistered != nil {
+			p.YearRegistered = api.Number(*d.YearRegistered)
+		}
+		patrons[i] = p
+	}
+	return patrons, nil
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jacksongodsey/SFILS/shared/interrupt"
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// how many documents a find returns when it doesn't say, so a find on the
+// whole patrons collection doesn't scroll for minutes. .limit(0) means all of them.
+const defaultFindLimit = 100
+
+// shellQuery is a db.collection.method(...) call typed the way it would be in
+// the mongo shell, like
+//
+//	db.patrons.find({within_sfc: true}, {email: 1}).sort({checkout_total: -1}).limit(5)
+//	db.patrons.aggregate([{$group: {_id: "$age_range", count: {$sum: 1}}}])
+//	db.patrons.countDocuments({active_year: "2023"})
+//	db.patrons.distinct("age_range")
+type shellQuery struct {
+	Collection string
+	Method     string
+	Filter     interface{}
+	Projection interface{}
+	Sort       interface{}
+	Skip       int64
+	Limit      int64
+	Pipeline   bson.A
+	Field      string
+	Explain    bool
+}
+
+// one method call in the chain, with its arguments already parsed
+type shellCall struct {
+	name string
+	args bson.A
+}
+
+// what each method takes. find and findOne can be followed by the cursor methods.
+var shellMethods = map[string]string{
+	"find":                   "find(filter, projection)",
+	"findOne":                "findOne(filter, projection)",
+	"aggregate":              "aggregate([stages])",
+	"countDocuments":         "countDocuments(filter)",
+	"count":                  "count(filter)",
+	"estimatedDocumentCount": "estimatedDocumentCount()",
+	"distinct":               "distinct(\"field\", filter)",
+}
+
+// parses db.collection.method(args).chained(args)...
+func parseShell(input string) (*shellQuery, error) {
+	text := strings.TrimSuffix(strings.TrimSpace(input), ";")
+	rest, ok := strings.CutPrefix(text, "db.")
+	if !ok {
+		return nil, fmt.Errorf("shell queries start with db., like db.patrons.find({})")
+	}
+
+	calls, collection, err := splitCalls(rest)
+	if err != nil {
+		return nil, err
+	}
+	if collection == "" {
+		return nil, fmt.Errorf("missing the collection, like db.patrons.find({})")
+	}
+
+	q := &shellQuery{Collection: collection, Method: calls[0].name, Limit: -1}
+	if _, ok := shellMethods[q.Method]; !ok {
+		return nil, fmt.Errorf("unknown method %s, use one of %s", q.Method, strings.Join(methodNames(), ", "))
+	}
+	args := calls[0].args
+	usage := fmt.Errorf("usage: db.%s.%s", collection, shellMethods[q.Method])
+
+	switch q.Method {
+	case "find", "findOne":
+		if len(args) > 2 {
+			return nil, usage
+		}
+		q.Filter = argOr(args, 0, bson.D{})
+		q.Projection = argOr(args, 1, nil)
+		if q.Method == "findOne" {
+			q.Limit = 1
+		}
+	case "aggregate":
+		if len(args) != 1 {
+			return nil, usage
+		}
+		pipeline, ok := args[0].(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("aggregate takes a list of stages, %v", usage)
+		}
+		q.Pipeline = pipeline
+	case "countDocuments", "count":
+		if len(args) > 1 {
+			return nil, usage
+		}
+		q.Method = "countDocuments"
+		q.Filter = argOr(args, 0, bson.D{})
+	case "estimatedDocumentCount":
+		if len(args) > 0 {
+			return nil, usage
+		}
+	case "distinct":
+		if len(args) < 1 || len(args) > 2 {
+			return nil, usage
+		}
+		field, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("distinct needs a field name in quotes, %v", usage)
+		}
+		q.Field = field
+		q.Filter = argOr(args, 1, bson.D{})
+	}
+
+	for _, c := range calls[1:] {
+		if c.name == "explain" {
+			q.Explain = true
+			continue
+		}
+		if q.Method != "find" {
+			return nil, fmt.Errorf(".%s() only goes after find()", c.name)
+		}
+		if len(c.args) != 1 {
+			return nil, fmt.Errorf(".%s() takes one argument", c.name)
+		}
+		switch c.name {
+		case "sort":
+			q.Sort = c.args[0]
+		case "projection":
+			q.Projection = c.args[0]
+		case "skip", "limit":
+			n, ok := wholeNumber(c.args[0])
+			if !ok || n < 0 {
+				return nil, fmt.Errorf(".%s() takes a number that isn't negative", c.name)
+			}
+			if c.name == "skip" {
+				q.Skip = n
+			} else {
+				q.Limit = n
+			}
+		default:
+			return nil, fmt.Errorf("unknown cursor method .%s(), use sort, skip, limit, projection or explain", c.name)
+		}
+	}
+	return q, nil
+}
+
+// splits the chain into calls and pulls the collection name off the front.
+// collection names can have dots in them, so the method is whatever comes
+// right before the first bracket. db.getCollection("name") works for names
+// that aren't valid after a dot.
+func splitCalls(text string) ([]shellCall, string, error) {
+	var calls []shellCall
+	collection := ""
+
+	for i := 0; i < len(text); {
+		open := strings.IndexByte(text[i:], '(')
+		if open < 0 {
+			return nil, "", fmt.Errorf("expected a method call at %q", text[i:])
+		}
+		open += i
+		name := strings.TrimSpace(text[i:open])
+
+		first := len(calls) == 0 && collection == ""
+		switch {
+		case first && name == "getCollection":
+		case first:
+			dot := strings.LastIndexByte(name, '.')
+			if dot < 0 {
+				return nil, "", fmt.Errorf("expected db.collection.method(...)")
+			}
+			collection, name = name[:dot], name[dot+1:]
+		case strings.HasPrefix(name, "."):
+			name = strings.TrimPrefix(name, ".")
+		default:
+			return nil, "", fmt.Errorf("expected .method(...) at %q", text[i:])
+		}
+
+		end, err := closingBracket(text, open)
+		if err != nil {
+			return nil, "", err
+		}
+		args, err := parseShellArgs(text[open+1 : end])
+		if err != nil {
+			return nil, "", fmt.Errorf("%s(): %v", name, err)
+		}
+		i = end + 1
+		for i < len(text) && unicode.IsSpace(rune(text[i])) {
+			i++
+		}
+
+		if first && name == "getCollection" {
+			coll, ok := argOr(args, 0, nil).(string)
+			if !ok || len(args) != 1 {
+				return nil, "", fmt.Errorf("usage: db.getCollection(\"name\").find({})")
+			}
+			collection = coll
+			continue
+		}
+		calls = append(calls, shellCall{name: name, args: args})
+	}
+
+	if len(calls) == 0 {
+		return nil, "", fmt.Errorf("expected a method call, like db.%s.find({})", collection)
+	}
+	return calls, collection, nil
+}
+
+// finds the bracket that closes the one at open, skipping anything in quotes
+func closingBracket(text string, open int) (int, error) {
+	depth := 0
+	for i := open; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '"', '\'':
+			for i++; i < len(text) && text[i] != c; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				if c != ')' {
+					return 0, fmt.Errorf("brackets don't match up")
+				}
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("missing )")
+}
+
+// parses the arguments of a call as a list of extended json values
+func parseShellArgs(text string) (bson.A, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	converted, err := shellToExtJSON(text)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.D
+	if err := bson.UnmarshalExtJSON([]byte(`{"args": [`+converted+`]}`), false, &doc); err != nil {
+		return nil, err
+	}
+	args, _ := doc[0].Value.(bson.A)
+	return args, nil
+}
+
+// the mongo shell is javascript so people leave the quotes off keys, use
+// single quotes and write ObjectId("...") instead of {"$oid": "..."}. this
+// turns those into extended json so they can be parsed the same way.
+func shellToExtJSON(text string) (string, error) {
+	var out strings.Builder
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '"' || c == '\'':
+			s, end, err := readShellString(text, i)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(jsonQuote(s))
+			i = end
+		case isWordByte(c) && !('0' <= c && c <= '9'):
+			start := i
+			for i+1 < len(text) && (isWordByte(text[i+1]) || text[i+1] == '.') {
+				i++
+			}
+			word := text[start : i+1]
+			next := i + 1
+			for next < len(text) && unicode.IsSpace(rune(text[next])) {
+				next++
+			}
+
+			switch {
+			case next < len(text) && text[next] == ':':
+				// a key without quotes
+				out.WriteString(jsonQuote(word))
+			case word == "new":
+				// new Date(...) is the same as Date(...)
+			case next < len(text) && text[next] == '(':
+				end, err := closingBracket(text, next)
+				if err != nil {
+					return "", err
+				}
+				value, err := shellHelper(word, strings.TrimSpace(text[next+1:end]))
+				if err != nil {
+					return "", err
+				}
+				out.WriteString(value)
+				i = end
+			default:
+				// true, false, null
+				out.WriteString(word)
+			}
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String(), nil
+}
+
+// a string in double quotes, escaped the way json wants
+func jsonQuote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// letters, digits, _ and $ can be part of a key without quotes
+func isWordByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '$'
+}
+
+// reads a string in single or double quotes starting at i and returns it
+// along with where it ends
+func readShellString(text string, i int) (string, int, error) {
+	quote := text[i]
+	var s strings.Builder
+	for i++; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			if i+1 < len(text) {
+				i++
+				switch text[i] {
+				case 'n':
+					s.WriteByte('\n')
+				case 't':
+					s.WriteByte('\t')
+				default:
+					s.WriteByte(text[i])
+				}
+			}
+		case quote:
+			return s.String(), i, nil
+		default:
+			s.WriteByte(text[i])
+		}
+	}
+	return "", 0, fmt.Errorf("missing closing %c", quote)
+}
+
+// the shell helpers for types json doesn't have
+func shellHelper(name, arg string) (string, error) {
+	if strings.HasPrefix(arg, "'") || strings.HasPrefix(arg, "\"") {
+		s, _, err := readShellString(arg, 0)
+		if err != nil {
+			return "", err
+		}
+		arg = s
+	}
+	quoted := jsonQuote(arg)
+
+	switch name {
+	case "ObjectId":
+		return `{"$oid": ` + quoted + `}`, nil
+	case "ISODate", "Date":
+		return `{"$date": ` + quoted + `}`, nil
+	case "NumberLong":
+		return `{"$numberLong": ` + quoted + `}`, nil
+	case "NumberInt":
+		return `{"$numberInt": ` + quoted + `}`, nil
+	case "NumberDecimal":
+		return `{"$numberDecimal": ` + quoted + `}`, nil
+	}
+	return "", fmt.Errorf("unknown helper %s(), use ObjectId, ISODate, NumberLong, NumberInt or NumberDecimal", name)
+}
+
+// runs a parsed shell query and writes the result
+func (s *session) runShell(q *shellQuery) error {
+	if err := s.collections.checkPipeline(q.Collection, q.Pipeline); err != nil {
+		return err
+	}
+	if q.Method == "aggregate" && !s.allowWrites {
+		if stage := writeStage(q.Pipeline); stage != "" {
+			return fmt.Errorf("%s writes to a collection, which isn't allowed in read-only mode. restart with --allow-writes", stage)
+		}
+	}
+	if q.Explain {
+		s.explainCommand(q.explainCommand())
+		return nil
+	}
+
+	coll := s.db.Collection(q.Collection)
+
+	switch q.Method {
+	case "find", "findOne":
+		ctx, tag, done := s.startQuery()
+		defer done()
+		opts := options.Find().SetComment(tag).SetSkip(q.Skip)
+		if q.Projection != nil {
+			opts.SetProjection(q.Projection)
+		}
+		if q.Sort != nil {
+			opts.SetSort(q.Sort)
+		}
+		switch {
+		case q.Limit < 0:
+			opts.SetLimit(defaultFindLimit)
+		case q.Limit > 0:
+			opts.SetLimit(q.Limit)
+		}
+		start := time.Now()
+		cursor, err := coll.Find(ctx, q.Filter, opts)
+		if err != nil {
+			return errors.New(interrupt.Error(ctx, err))
+		}
+		return s.writeCursor(ctx, cursor, start)
+	case "aggregate":
+		return s.runAggregate(q.Collection, q.Pipeline)
+	}
+
+	// counts and distinct come back as plain values rather than a cursor
+	ctx, tag, done := s.startQuery()
+	defer done()
+	start := time.Now()
+
+	var cols []output.Column
+	var rows [][]output.Value
+	switch q.Method {
+	case "countDocuments", "estimatedDocumentCount":
+		var n int64
+		var err error
+		if q.Method == "countDocuments" {
+			n, err = coll.CountDocuments(ctx, q.Filter, options.Count().SetComment(tag))
+		} else {
+			n, err = coll.EstimatedDocumentCount(ctx, options.EstimatedDocumentCount().SetComment(tag))
+		}
+		if err != nil {
+			return errors.New(interrupt.Error(ctx, err))
+		}
+		cols = []output.Column{{Name: "count", Numeric: true}}
+		rows = [][]output.Value{{bsonValue(n)}}
+	case "distinct":
+		values, err := coll.Distinct(ctx, q.Field, q.Filter, options.Distinct().SetComment(tag))
+		if err != nil {
+			return errors.New(interrupt.Error(ctx, err))
+		}
+		numeric := len(values) > 0
+		for _, v := range values {
+			val := bsonValue(v)
+			numeric = numeric && val.Kind == output.Number
+			rows = append(rows, []output.Value{val})
+		}
+		cols = []output.Column{{Name: q.Field, Numeric: numeric}}
+	}
+
+	if err := s.writeRows(cols, rows); err != nil {
+		return err
+	}
+	s.printCount(len(rows), "rows")
+	s.printTiming(time.Since(start))
+	fmt.Fprintln(s.status)
+	return nil
+}
+
+// the explain command for the same query
+func (q *shellQuery) explainCommand() bson.D {
+	switch q.Method {
+	case "aggregate":
+		return bson.D{
+			{Key: "aggregate", Value: q.Collection},
+			{Key: "pipeline", Value: q.Pipeline},
+			{Key: "cursor", Value: bson.D{}},
+		}
+	case "countDocuments":
+		return bson.D{{Key: "count", Value: q.Collection}, {Key: "query", Value: q.Filter}}
+	case "estimatedDocumentCount":
+		return bson.D{{Key: "count", Value: q.Collection}}
+	case "distinct":
+		return bson.D{{Key: "distinct", Value: q.Collection}, {Key: "key", Value: q.Field}, {Key: "query", Value: q.Filter}}
+	}
+
+	cmd := bson.D{{Key: "find", Value: q.Collection}, {Key: "filter", Value: q.Filter}}
+	if q.Projection != nil {
+		cmd = append(cmd, bson.E{Key: "projection", Value: q.Projection})
+	}
+	if q.Sort != nil {
+		cmd = append(cmd, bson.E{Key: "sort", Value: q.Sort})
+	}
+	if q.Skip > 0 {
+		cmd = append(cmd, bson.E{Key: "skip", Value: q.Skip})
+	}
+	switch {
+	case q.Limit < 0:
+		cmd = append(cmd, bson.E{Key: "limit", Value: defaultFindLimit})
+	case q.Limit > 0:
+		cmd = append(cmd, bson.E{Key: "limit", Value: q.Limit})
+	}
+	return cmd
+}
+
+// $out and $merge are the stages that write, and they can only go last
+// but it's cheap to look at all of them
+func writeStage(pipeline bson.A) string {
+	for _, st := range pipeline {
+		stage, ok := st.(bson.D)
+		if !ok || len(stage) == 0 {
+			continue
+		}
+		if stage[0].Key == "$out" || stage[0].Key == "$merge" {
+			return stage[0].Key
+		}
+	}
+	return ""
+}
+
+// the argument at i, or def when it wasn't given
+func argOr(args bson.A, i int, def interface{}) interface{} {
+	if i < len(args) {
+		return args[i]
+	}
+	return def
+}
+
+// numbers from extended json can be int32, int64 or double
+func wholeNumber(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), n == float64(int64(n))
+	}
+	return 0, false
+}
+
+func methodNames() []string {
+	return []string{"find", "findOne", "aggregate", "countDocuments", "estimatedDocumentCount", "distinct"}
+}
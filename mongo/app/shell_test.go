@@ -0,0 +1,209 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseShell(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("5f1d7f3e8b3e4a2b9c0d1e2f")
+	day := primitive.NewDateTimeFromTime(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name  string
+		input string
+		want  *shellQuery
+	}{
+		{
+			name:  "unquoted keys",
+			input: "db.patrons.find({within_sfc: true, active_year: '2023'})",
+			want: &shellQuery{Collection: "patrons", Method: "find", Limit: -1,
+				Filter: bson.D{{Key: "within_sfc", Value: true}, {Key: "active_year", Value: "2023"}}},
+		},
+		{
+			name:  "quoted keys and operators",
+			input: `db.patrons.find({"checkout_total": {$gt: 10}}, {email: 1, _id: 0});`,
+			want: &shellQuery{Collection: "patrons", Method: "find", Limit: -1,
+				Filter:     bson.D{{Key: "checkout_total", Value: bson.D{{Key: "$gt", Value: int32(10)}}}},
+				Projection: bson.D{{Key: "email", Value: int32(1)}, {Key: "_id", Value: int32(0)}}},
+		},
+		{
+			name:  "single quoted strings with escapes",
+			input: `db.patrons.find({age_range: '25 to 34 years', note: 'it\'s "fine"'})`,
+			want: &shellQuery{Collection: "patrons", Method: "find", Limit: -1,
+				Filter: bson.D{{Key: "age_range", Value: "25 to 34 years"}, {Key: "note", Value: `it's "fine"`}}},
+		},
+		{
+			name:  "a colon inside a string isn't a key",
+			input: `db.patrons.find({email: 'a: b'})`,
+			want: &shellQuery{Collection: "patrons", Method: "find", Limit: -1,
+				Filter: bson.D{{Key: "email", Value: "a: b"}}},
+		},
+		{
+			name:  "ObjectId and ISODate",
+			input: `db.patrons.find({_id: ObjectId("5f1d7f3e8b3e4a2b9c0d1e2f"), seen: {$gte: ISODate('2023-01-02T00:00:00Z')}, since: new Date("2023-01-02T00:00:00Z")})`,
+			want: &shellQuery{Collection: "patrons", Method: "find", Limit: -1,
+				Filter: bson.D{
+					{Key: "_id", Value: id},
+					{Key: "seen", Value: bson.D{{Key: "$gte", Value: day}}},
+					{Key: "since", Value: day},
+				}},
+		},
+		{
+			name:  "NumberLong",
+			input: `db.patrons.find({checkout_total: NumberLong("9000000000")})`,
+			want: &shellQuery{Collection: "patrons", Method: "find", Limit: -1,
+				Filter: bson.D{{Key: "checkout_total", Value: int64(9000000000)}}},
+		},
+		{
+			name:  "nested arrays",
+			input: `db.patrons.aggregate([{$match: {age_range: {$in: ['0 to 9 years', '10 to 19 years']}}}, {$project: {pair: [[1, 2], ["$age_range"]]}}])`,
+			want: &shellQuery{Collection: "patrons", Method: "aggregate", Limit: -1,
+				Pipeline: bson.A{
+					bson.D{{Key: "$match", Value: bson.D{{Key: "age_range", Value: bson.D{{Key: "$in", Value: bson.A{"0 to 9 years", "10 to 19 years"}}}}}}},
+					bson.D{{Key: "$project", Value: bson.D{{Key: "pair", Value: bson.A{bson.A{int32(1), int32(2)}, bson.A{"$age_range"}}}}}},
+				}},
+		},
+		{
+			name:  "cursor methods",
+			input: "db.patrons.find({}).sort({checkout_total: -1}).skip(10).limit(5)",
+			want: &shellQuery{Collection: "patrons", Method: "find", Filter: bson.D{},
+				Sort: bson.D{{Key: "checkout_total", Value: int32(-1)}}, Skip: 10, Limit: 5},
+		},
+		{
+			name:  "trailing explain",
+			input: "db.patrons.find({within_sfc: true}).limit(5).explain()",
+			want: &shellQuery{Collection: "patrons", Method: "find", Limit: 5, Explain: true,
+				Filter: bson.D{{Key: "within_sfc", Value: true}}},
+		},
+		{
+			name:  "explain after aggregate",
+			input: "db.patrons.aggregate([{$count: 'n'}]).explain()",
+			want: &shellQuery{Collection: "patrons", Method: "aggregate", Limit: -1, Explain: true,
+				Pipeline: bson.A{bson.D{{Key: "$count", Value: "n"}}}},
+		},
+		{
+			name:  "findOne and count",
+			input: "db.patrons.findOne()",
+			want:  &shellQuery{Collection: "patrons", Method: "findOne", Filter: bson.D{}, Limit: 1},
+		},
+		{
+			name:  "count is countDocuments",
+			input: "db.patrons.count({within_sfc: false})",
+			want: &shellQuery{Collection: "patrons", Method: "countDocuments", Limit: -1,
+				Filter: bson.D{{Key: "within_sfc", Value: false}}},
+		},
+		{
+			name:  "distinct",
+			input: "db.patrons.distinct('age_range', {within_sfc: true})",
+			want: &shellQuery{Collection: "patrons", Method: "distinct", Field: "age_range", Limit: -1,
+				Filter: bson.D{{Key: "within_sfc", Value: true}}},
+		},
+		{
+			name:  "collection names with dots and getCollection",
+			input: `db.getCollection("system.profile").find()`,
+			want:  &shellQuery{Collection: "system.profile", Method: "find", Filter: bson.D{}, Limit: -1},
+		},
+		{
+			name:  "dotted collection",
+			input: "db.old.patrons.estimatedDocumentCount()",
+			want:  &shellQuery{Collection: "old.patrons", Method: "estimatedDocumentCount", Limit: -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseShell(tt.input)
+			if err != nil {
+				t.Fatalf("parseShell(%q): %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseShell(%q)\n got %#v\nwant %#v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseShellErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"patrons.find({})", "shell queries start with db."},
+		{"db.find({})", "expected db.collection.method(...)"},
+		{"db.patrons", "expected a method call"},
+		{"db.patrons.find({}", "missing )"},
+		{"db.patrons.find({]})", "brackets don't match up"},
+		{"db.patrons.find({email: 'open})", "missing )"},
+		{"db.patrons.find({a: 1,, b: 2})", "find()"},
+		{"db.patrons.drop()", "unknown method drop"},
+		{"db.patrons.find({}, {}, {})", "usage: db.patrons.find(filter, projection)"},
+		{"db.patrons.aggregate({$match: {}})", "aggregate takes a list of stages"},
+		{"db.patrons.distinct(age_range)", "distinct()"},
+		{"db.patrons.distinct(1)", "distinct needs a field name in quotes"},
+		{"db.patrons.find({}).limit(-1)", ".limit() takes a number that isn't negative"},
+		{"db.patrons.find({}).limit('5')", ".limit() takes a number that isn't negative"},
+		{"db.patrons.count({}).limit(5)", ".limit() only goes after find()"},
+		{"db.patrons.find({}).toArray(1)", "unknown cursor method .toArray()"},
+		{"db.patrons.find({}) .sort({a: 1})", ""},
+		{"db.patrons.find({}) sort({a: 1})", "expected .method(...)"},
+		{"db.patrons.find({_id: UUID('x')})", "unknown helper UUID()"},
+		{"db.getCollection(1).find()", "usage: db.getCollection"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseShell(tt.input)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("parseShell(%q): %v", tt.input, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("parseShell(%q) worked, want an error with %q", tt.input, tt.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parseShell(%q): %v, want %q", tt.input, err, tt.err)
+		}
+	}
+}
+
+func TestWriteStages(t *testing.T) {
+	// the collections are already known so the guard doesn't need a server
+	guard := &collectionGuard{names: map[string]bool{"patrons": true, "summary": true}}
+
+	tests := []struct {
+		input string
+		stage string
+	}{
+		{"db.patrons.aggregate([{$match: {}}, {$out: 'summary'}])", "$out"},
+		{"db.patrons.aggregate([{$group: {_id: '$age_range'}}, {$merge: {into: 'summary'}}])", "$merge"},
+		{"db.patrons.aggregate([{$out: 'summary'}]).explain()", "$out"},
+		{"db.patrons.aggregate([{$match: {note: '$out'}}])", ""},
+	}
+
+	for _, tt := range tests {
+		q, err := parseShell(tt.input)
+		if err != nil {
+			t.Fatalf("parseShell(%q): %v", tt.input, err)
+		}
+		if got := writeStage(q.Pipeline); got != tt.stage {
+			t.Errorf("writeStage(%q) = %q, want %q", tt.input, got, tt.stage)
+		}
+		if tt.stage == "" {
+			continue
+		}
+
+		s := &session{collections: guard}
+		err = s.runShell(q)
+		if err == nil || !strings.Contains(err.Error(), "isn't allowed in read-only mode") {
+			t.Errorf("runShell(%q) in read-only mode: %v, want it turned away", tt.input, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/jacksongodsey/SFILS/shared/bench"
+	"github.com/jacksongodsey/SFILS/shared/verify"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// the verify command: reads every patron back out of mongodb and saves a
+// snapshot to compare with the one from mysql
+//
+//	go run . verify -against ../../results/verify-mysql.json
+func runVerifyCommand(db *mongo.Database, args []string) error {
+	return verify.Command("mongodb", bench.Checksum(workbookFile), "../../results/verify-mongodb.json", args, func(add func([]string) error) error {
+		ctx := context.Background()
+		cursor, err := db.Collection("patrons").Find(ctx, bson.D{})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var p Patron
+			if err := cursor.Decode(&p); err != nil {
+				return err
+			}
+			// a missing field comes out as "", the same as NULL in mysql
+			activeMonth := ""
+			if p.ActiveMonth != nil {
+				activeMonth = strconv.Itoa(*p.ActiveMonth)
+			}
+			err := add([]string{
+				p.PatronTypeCode, p.PatronTypeDesc, p.CheckoutTotal, p.RenewalTotal,
+				p.AgeRange, p.HomeLibraryCode, p.HomeLibraryName, activeMonth,
+				stringOrEmpty(p.ActiveYear), p.NotificationTypeCode, p.NotificationTypeDesc,
+				stringOrEmpty(p.Email), strconv.FormatBool(p.WithinSFC), stringOrEmpty(p.YearRegistered),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return cursor.Err()
+	})
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
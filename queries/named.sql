@@ -0,0 +1,77 @@
+-- saved queries for the query interface. run them with
+--   :run top_libraries limit=10
+-- or from the command line with
+--   go run . query top_libraries limit=10
+--
+-- each query starts with "-- name:", then a description line and any
+-- "-- param: name type [default]" lines. params are used as :name in the sql
+-- and always go to mysql as placeholders.
+
+-- name: top_libraries
+-- libraries with the most patrons
+-- param: limit int 10
+SELECT l.name AS library, COUNT(*) AS patrons
+FROM patrons p
+JOIN libraries l ON p.home_library_code = l.code
+GROUP BY l.name
+ORDER BY patrons DESC
+LIMIT :limit;
+
+-- name: sf_age_distribution
+-- patrons in each age range who live in san francisco, within_sfc=false for everyone else
+-- param: within_sfc bool true
+SELECT age_range, COUNT(*) AS patrons
+FROM patrons
+WHERE within_sfc = :within_sfc
+GROUP BY age_range
+ORDER BY patrons DESC;
+
+-- name: library_patrons
+-- patrons with a given home library, by library code
+-- param: library string
+-- param: limit int 20
+SELECT p.id, pt.description AS patron_type, p.age_range, p.checkout_total, p.renewal_total, p.active_year
+FROM patrons p
+JOIN patron_types pt ON p.patron_type_id = pt.id
+WHERE p.home_library_code = :library
+ORDER BY p.checkout_total DESC
+LIMIT :limit;
+
+-- name: active_in_year
+-- how many patrons were last active in a year, by library
+-- param: year int 2023
+SELECT l.name AS library, COUNT(*) AS patrons
+FROM patrons p
+JOIN libraries l ON p.home_library_code = l.code
+WHERE p.active_year = :year
+GROUP BY l.name
+ORDER BY patrons DESC;
+
+-- name: registrations_by_year
+-- new patrons registered each year
+SELECT year_registered, COUNT(*) AS patrons
+FROM patrons
+WHERE year_registered IS NOT NULL
+GROUP BY year_registered
+ORDER BY year_registered;
+
+-- name: heavy_users
+-- patrons with at least this many checkouts
+-- param: min_checkouts int 1000
+-- param: limit int 20
+SELECT p.id, l.name AS library, p.age_range, p.checkout_total, p.renewal_total
+FROM patrons p
+JOIN libraries l ON p.home_library_code = l.code
+WHERE p.checkout_total >= :min_checkouts
+ORDER BY p.checkout_total DESC
+LIMIT :limit;
+
+-- name: email_domain
+-- patrons whose email ends with a domain
+-- param: domain string gmail.com
+-- param: limit int 20
+SELECT p.id, p.email, l.name AS library
+FROM patrons p
+JOIN libraries l ON p.home_library_code = l.code
+WHERE p.email LIKE CONCAT('%@', :domain)
+LIMIT :limit;
@@ -0,0 +1,392 @@
+// Package api serves the patron data over http as json, so other services
+// can read it without database credentials. the mysql and mongodb apps serve
+// the same api: the lookups and stats are patron queries and built-in
+// reports, so only the /patrons listing and the lookup tables need code of
+// their own on each backend. /graphql serves the same data for clients that
+// want it nested in one round trip, /dashboard/ charts it for people and
+// /metrics has the prometheus metrics.
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/metrics"
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"github.com/jacksongodsey/SFILS/shared/report"
+)
+
+// Backend is how the api reads one of the stores
+type Backend struct {
+	Name string // mysql or mongodb
+
+	// Query runs a patron query and returns its rows, with the values in the
+	// order of the query's columns
+	Query func(ctx context.Context, q *dsl.Query) ([][]output.Value, error)
+
+	// Patrons lists up to limit patrons that match where, nil for all of
+	// them, ordered by id and starting after the id in after, "" for the
+	// first page. an id it can't read is ErrBadCursor.
+	Patrons func(ctx context.Context, where dsl.Expr, after string, limit int) ([]Patron, error)
+
+	// Lookup reads the names of codes in a lookup table, libraries,
+	// patron_types or notification_types, nil codes for the whole table.
+	// codes that aren't there are left out.
+	Lookup func(ctx context.Context, table string, codes []string) (map[string]string, error)
+}
+
+// ErrBadCursor is what Backend.Patrons returns for an id that isn't one of its own
+var ErrBadCursor = errors.New("the cursor isn't valid")
+
+// Patron is one patron the way the api hands it out. the names are the
+// fields of the patron query language.
+type Patron struct {
+	ID               string  `json:"id"`
+	PatronType       string  `json:"patron_type"`
+	PatronTypeCode   string  `json:"patron_type_code"`
+	AgeRange         string  `json:"age_range"`
+	HomeLibrary      string  `json:"home_library"`
+	HomeLibraryCode  string  `json:"home_library_code"`
+	CheckoutTotal    *int64  `json:"checkout_total"`
+	RenewalTotal     *int64  `json:"renewal_total"`
+	ActiveMonth      *int64  `json:"active_month"`
+	ActiveYear       *int64  `json:"active_year"`
+	Notification     string  `json:"notification"`
+	NotificationCode string  `json:"notification_code"`
+	Email            *string `json:"email"`
+	WithinSFC        bool    `json:"within_sfc"`
+	YearRegistered   *int64  `json:"year_registered"`
+}
+
+// Number reads a number mongodb keeps as a string, nil when it isn't one
+func Number(s string) *int64 {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &n
+}
+
+// what the endpoints send back
+type (
+	patronPage struct {
+		Patrons []Patron `json:"patrons"`
+		Next    *string  `json:"next"` // cursor for the next page, null on the last one
+	}
+	library struct {
+		Code    string `json:"code"`
+		Name    string `json:"name"`
+		Patrons int64  `json:"patrons"`
+	}
+	libraryList struct {
+		Libraries []library `json:"libraries"`
+	}
+	patronType struct {
+		Code        string `json:"code"`
+		Description string `json:"description"`
+		Patrons     int64  `json:"patrons"`
+	}
+	patronTypeList struct {
+		PatronTypes []patronType `json:"patron_types"`
+	}
+	statsLink struct {
+		Name  string `json:"name"`
+		Title string `json:"title"`
+		Path  string `json:"path"`
+	}
+	statsList struct {
+		Stats []statsLink `json:"stats"`
+	}
+	stats struct {
+		Name    string                   `json:"name"`
+		Title   string                   `json:"title"`
+		Columns []string                 `json:"columns"`
+		Rows    []map[string]interface{} `json:"rows"`
+	}
+	errorResponse struct {
+		Error string `json:"error"`
+	}
+)
+
+// DefaultLimit and MaxLimit are the patrons per page when limit isn't given
+// and the most a page can have
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Handler serves the api on top of a backend. a request that takes longer
+// than timeout is stopped and gets a 504.
+func Handler(b Backend, timeout time.Duration) http.Handler {
+	s := &server{backend: b, timeout: timeout, schema: newSchema()}
+	mux := http.NewServeMux()
+	for _, r := range s.routes() {
+		mux.HandleFunc(r.method()+" "+r.pattern, r.handle)
+	}
+	s.dashboard(mux)
+	mux.Handle("GET /metrics", metrics.Handler())
+	return logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the mux answers paths and methods it doesn't have in plain text,
+		// everything else is json so these are too
+		if _, pattern := mux.Handler(r); pattern == "" {
+			for _, method := range []string{http.MethodGet, http.MethodPost} {
+				other := r.Clone(r.Context())
+				other.Method = method
+				if _, pattern := mux.Handler(other); pattern != "" {
+					allow := method
+					if method == http.MethodGet {
+						allow = "GET, HEAD"
+					}
+					w.Header().Set("Allow", allow)
+					writeJSON(w, http.StatusMethodNotAllowed, errorResponse{"only " + method + " is supported"})
+					return
+				}
+			}
+			writeJSON(w, http.StatusNotFound, errorResponse{"there's nothing at " + r.URL.Path + ", see /openapi.json"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+type server struct {
+	backend Backend
+	timeout time.Duration
+	schema  *graphql.Schema
+}
+
+// an error that already knows its status code
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+func badRequest(format string, args ...interface{}) error {
+	return &httpError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+// wraps a handler so it can return its response and errors instead of
+// writing them, and gives it the request timeout
+func (s *server) handle(fn func(r *http.Request) (interface{}, error)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+		defer cancel()
+		body, err := fn(r.WithContext(ctx))
+		if err == nil {
+			writeJSON(w, http.StatusOK, body)
+			return
+		}
+
+		var he *httpError
+		switch {
+		case errors.As(err, &he):
+			writeJSON(w, he.status, errorResponse{he.msg})
+		case errors.Is(err, ErrBadCursor):
+			writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		case ctx.Err() == context.DeadlineExceeded:
+			writeJSON(w, http.StatusGatewayTimeout, errorResponse{fmt.Sprintf("the query took longer than %v", s.timeout)})
+		case r.Context().Err() != nil:
+			// the client went away, there's no one to answer
+		default:
+			// the details stay in the server's log, they can have table names and the like
+			fmt.Printf("%s %s: %v\n", r.Method, r.URL.Path, err)
+			writeJSON(w, http.StatusInternalServerError, errorResponse{"the " + s.backend.Name + " query failed"})
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(body)
+}
+
+// GET /patrons
+func (s *server) patrons(r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	if err := checkParams(query, "limit", "cursor"); err != nil {
+		return nil, err
+	}
+	where, err := parseFilters(query)
+	if err != nil {
+		return nil, err
+	}
+	limit := DefaultLimit
+	if text := query.Get("limit"); text != "" {
+		limit, err = strconv.Atoi(text)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return nil, badRequest("limit has to be a number from 1 to %d", MaxLimit)
+		}
+	}
+	after := ""
+	if cursor := query.Get("cursor"); cursor != "" {
+		id, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || len(id) == 0 {
+			return nil, ErrBadCursor
+		}
+		after = string(id)
+	}
+
+	// one more than asked for tells whether there's another page
+	patrons, err := s.backend.Patrons(r.Context(), where, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := patronPage{Patrons: patrons}
+	if len(patrons) > limit {
+		page.Patrons = patrons[:limit]
+		next := base64.RawURLEncoding.EncodeToString([]byte(patrons[limit-1].ID))
+		page.Next = &next
+	}
+	if page.Patrons == nil {
+		page.Patrons = []Patron{}
+	}
+	return page, nil
+}
+
+// runs a patron query for the lookups on the patrons that match the filters
+func (s *server) rows(r *http.Request, text string) ([][]output.Value, error) {
+	query := r.URL.Query()
+	if err := checkParams(query); err != nil {
+		return nil, err
+	}
+	where, err := parseFilters(query)
+	if err != nil {
+		return nil, err
+	}
+	q, err := dsl.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	q.Where = where
+	return s.backend.Query(r.Context(), q)
+}
+
+// GET /libraries, the libraries with how many of the patrons use them
+func (s *server) libraries(r *http.Request) (interface{}, error) {
+	rows, err := s.rows(r, "patrons group by home_library_code, home_library count order by home_library_code")
+	if err != nil {
+		return nil, err
+	}
+	list := libraryList{Libraries: []library{}}
+	for _, row := range rows {
+		n, _ := strconv.ParseInt(row[2].Text, 10, 64)
+		list.Libraries = append(list.Libraries, library{Code: row[0].Text, Name: row[1].Text, Patrons: n})
+	}
+	return list, nil
+}
+
+// GET /patron-types, the patron types with how many patrons have them
+func (s *server) patronTypes(r *http.Request) (interface{}, error) {
+	rows, err := s.rows(r, "patrons group by patron_type_code, patron_type count order by patron_type_code")
+	if err != nil {
+		return nil, err
+	}
+	list := patronTypeList{PatronTypes: []patronType{}}
+	for _, row := range rows {
+		n, _ := strconv.ParseInt(row[2].Text, 10, 64)
+		list.PatronTypes = append(list.PatronTypes, patronType{Code: row[0].Text, Description: row[1].Text, Patrons: n})
+	}
+	return list, nil
+}
+
+// GET /stats
+func (s *server) statsList(r *http.Request) (interface{}, error) {
+	if err := checkParams(r.URL.Query()); err != nil {
+		return nil, err
+	}
+	list := statsList{}
+	for _, rep := range report.Reports {
+		list.Stats = append(list.Stats, statsLink{Name: rep.Name, Title: rep.Title, Path: "/stats/" + rep.Name})
+	}
+	return list, nil
+}
+
+// GET /stats/{name}, one of the built-in reports
+func (s *server) stats(r *http.Request) (interface{}, error) {
+	rep := report.Find(r.PathValue("name"))
+	if rep == nil {
+		return nil, &httpError{http.StatusNotFound, fmt.Sprintf("no stats called %q, see /stats for the list", r.PathValue("name"))}
+	}
+	query := r.URL.Query()
+	if err := checkParams(query); err != nil {
+		return nil, err
+	}
+	where, err := parseFilters(query)
+	if err != nil {
+		return nil, err
+	}
+	res, err := report.RunWhere(rep, where, func(q *dsl.Query) ([][]output.Value, error) {
+		return s.backend.Query(r.Context(), q)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := stats{Name: rep.Name, Title: rep.Title, Rows: []map[string]interface{}{}}
+	for _, c := range res.Columns {
+		out.Columns = append(out.Columns, c.Name)
+	}
+	for _, row := range res.Rows {
+		obj := map[string]interface{}{}
+		for i, v := range row {
+			obj[res.Columns[i].Name] = jsonValue(v)
+		}
+		out.Rows = append(out.Rows, obj)
+	}
+	return out, nil
+}
+
+// a cell as a json value, numbers stay numbers
+func jsonValue(v output.Value) interface{} {
+	switch v.Kind {
+	case output.Null:
+		return nil
+	case output.Number:
+		return json.Number(v.Text)
+	case output.Bool:
+		return v.Text == "true"
+	case output.JSON:
+		return json.RawMessage(v.Text)
+	}
+	return v.Text
+}
+
+// GET /openapi.json
+func (s *server) spec(r *http.Request) (interface{}, error) {
+	return Spec(s.backend.Name), nil
+}
+
+// remembers the status code so it can be logged
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// prints a line per request so it's clear what the other services are asking for
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		fmt.Printf("%s %s %d %v\n", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+)
+
+// the dashboard is plain html, css and js with the charts drawn as svg, so
+// it's all in the binary and works without internet
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+var dashboardPage = template.Must(template.ParseFS(dashboardFiles, "dashboard/index.html"))
+
+// serves the dashboard on /dashboard/. it draws its charts from /stats and
+// /patron-types, so it shows whichever backend is serving it.
+func (s *server) dashboard(mux *http.ServeMux) {
+	static, _ := fs.Sub(dashboardFiles, "dashboard")
+	mux.Handle("GET /dashboard/", http.StripPrefix("/dashboard/", http.FileServerFS(static)))
+	mux.HandleFunc("GET /dashboard/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		dashboardPage.Execute(w, struct{ Backend string }{s.backend.Name})
+	})
+	mux.Handle("GET /{$}", http.RedirectHandler("/dashboard/", http.StatusFound))
+}
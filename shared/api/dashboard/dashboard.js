@@ -0,0 +1,242 @@
+// the dashboard. every chart is one of the built-in reports from /stats,
+// drawn as svg by hand so there's nothing to download from a cdn.
+
+const svgNS = "http://www.w3.org/2000/svg";
+const colors = ["#3d6a94", "#e07b39", "#5a9e6f", "#c44e52", "#8172b2", "#ccb974", "#64b5cd", "#937860", "#8c8c8c"];
+const months = ["Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"];
+
+const form = document.getElementById("filters");
+
+// makes an svg element with its attributes
+function svg(name, attrs, parent) {
+  const el = document.createElementNS(svgNS, name);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    el.setAttribute(k, v);
+  }
+  if (parent) {
+    parent.appendChild(el);
+  }
+  return el;
+}
+
+function text(parent, x, y, value, attrs) {
+  const t = svg("text", Object.assign({x: x, y: y, class: "label"}, attrs), parent);
+  t.textContent = value;
+  return t;
+}
+
+// hovering shows the exact numbers
+function tooltip(el, value) {
+  svg("title", {}, el).textContent = value;
+}
+
+function number(n) {
+  return n == null ? "-" : Number(n).toLocaleString();
+}
+
+function empty(el) {
+  el.replaceChildren();
+  const p = document.createElement("p");
+  p.className = "empty";
+  p.textContent = "No patrons match the filters.";
+  el.appendChild(p);
+}
+
+// one bar per item, sideways so long library names fit
+function barsAcross(el, items) {
+  if (items.length == 0) {
+    return empty(el);
+  }
+  const row = 20, labelWidth = 190, width = 760;
+  const max = Math.max(...items.map(d => d.value));
+  const s = svg("svg", {width: width, height: items.length * row});
+  items.forEach((d, i) => {
+    const y = i * row;
+    const w = Math.max(1, (width - labelWidth - 70) * d.value / max);
+    text(s, labelWidth - 6, y + 14, d.label, {"text-anchor": "end"});
+    const bar = svg("rect", {x: labelWidth, y: y + 3, width: w, height: row - 6, class: "bar"}, s);
+    tooltip(bar, d.title);
+    text(s, labelWidth + w + 4, y + 14, number(d.value));
+  });
+  el.replaceChildren(s);
+}
+
+// one bar per item, upright for things in order like ages and years
+function barsUp(el, items) {
+  if (items.length == 0) {
+    return empty(el);
+  }
+  const height = 220, bottom = 40, left = 50;
+  const slot = Math.max(18, Math.min(60, 700 / items.length));
+  const width = left + items.length * slot;
+  const max = Math.max(...items.map(d => d.value));
+  const s = svg("svg", {width: width, height: height});
+  svg("line", {x1: left, y1: height - bottom, x2: width, y2: height - bottom, class: "axis"}, s);
+  text(s, left - 6, 12, number(max), {"text-anchor": "end"});
+  text(s, left - 6, height - bottom, "0", {"text-anchor": "end"});
+  items.forEach((d, i) => {
+    const h = Math.max(1, (height - bottom - 10) * d.value / max);
+    const x = left + i * slot;
+    const bar = svg("rect", {x: x + 2, y: height - bottom - h, width: slot - 4, height: h, class: "bar"}, s);
+    tooltip(bar, d.title);
+    // labels lean over when there are too many to fit flat
+    const lean = slot < 50 ? {transform: `rotate(-45 ${x + slot / 2} ${height - bottom + 12})`, "text-anchor": "end"} : {"text-anchor": "middle"};
+    text(s, x + slot / 2, height - bottom + 14, d.label, lean);
+  });
+  el.replaceChildren(s);
+}
+
+// a ring with a slice per item, the small ones go together as other
+function donut(el, items) {
+  if (items.length == 0) {
+    return empty(el);
+  }
+  const top = items.slice(0, colors.length - 1);
+  const rest = items.slice(colors.length - 1);
+  if (rest.length > 0) {
+    const value = rest.reduce((sum, d) => sum + d.value, 0);
+    top.push({label: "Other", value: value, title: `Other: ${number(value)} patrons`});
+  }
+
+  const size = 220, r = 100, inner = 60, c = size / 2;
+  const total = top.reduce((sum, d) => sum + d.value, 0);
+  const s = svg("svg", {width: size, height: size});
+  const legend = document.createElement("div");
+  legend.className = "legend";
+  let angle = -Math.PI / 2;
+  top.forEach((d, i) => {
+    const share = d.value / total;
+    const end = angle + share * 2 * Math.PI;
+    const big = share > 0.5 ? 1 : 0;
+    const p = (a, radius) => `${c + radius * Math.cos(a)} ${c + radius * Math.sin(a)}`;
+    // a full circle can't be one arc, so a single slice stops just short of it
+    const stop = share >= 1 ? end - 0.0001 : end;
+    const path = `M ${p(angle, r)} A ${r} ${r} 0 ${big} 1 ${p(stop, r)} L ${p(stop, inner)} A ${inner} ${inner} 0 ${big} 0 ${p(angle, inner)} Z`;
+    tooltip(svg("path", {d: path, fill: colors[i]}, s), d.title);
+    angle = end;
+
+    const item = document.createElement("span");
+    const swatch = document.createElement("i");
+    swatch.style.background = colors[i];
+    item.append(swatch, `${d.label} ${(share * 100).toFixed(1)}%`);
+    legend.appendChild(item);
+  });
+  text(s, c, c + 5, number(total), {"text-anchor": "middle", "font-size": "16"});
+  el.replaceChildren(s, legend);
+}
+
+// a square per month of each year, darker for more patrons
+function heatmap(el, rows) {
+  const years = {};
+  let unknown = 0, max = 0;
+  for (const row of rows) {
+    if (row.active_year == null || row.active_month == null) {
+      unknown += row.count;
+      continue;
+    }
+    years[row.active_year] = years[row.active_year] || {};
+    years[row.active_year][row.active_month] = row;
+    max = Math.max(max, row.count);
+  }
+  const list = Object.keys(years).sort();
+  if (list.length == 0) {
+    return empty(el);
+  }
+
+  const cell = 28, left = 44, top = 18;
+  const s = svg("svg", {width: left + 12 * cell, height: top + list.length * cell});
+  months.forEach((m, i) => text(s, left + i * cell + cell / 2, 12, m, {"text-anchor": "middle"}));
+  list.forEach((year, j) => {
+    const y = top + j * cell;
+    text(s, left - 6, y + cell / 2 + 4, year, {"text-anchor": "end"});
+    for (let m = 1; m <= 12; m++) {
+      const row = years[year][m];
+      const shade = row ? 0.12 + 0.88 * row.count / max : 0.04;
+      const square = svg("rect", {x: left + (m - 1) * cell + 1, y: y + 1, width: cell - 2, height: cell - 2, fill: "#1f3b57", "fill-opacity": shade}, s);
+      tooltip(square, `${months[m - 1]} ${year}: ${number(row ? row.count : 0)} patrons`);
+    }
+  });
+  const note = document.createElement("p");
+  note.className = "empty";
+  note.textContent = unknown > 0 ? `${number(unknown)} patrons have never been active or have no month.` : "";
+  el.replaceChildren(s, note);
+}
+
+// the rows of a report as chart items
+function items(rows, key, label) {
+  return rows.map(row => {
+    const name = row[key] == null ? "Unknown" : (label ? label(row[key]) : String(row[key]));
+    return {
+      label: name,
+      value: row.count,
+      title: `${name}: ${number(row.count)} patrons (${row.percent}%), ${number(row.avg_checkout_total)} checkouts on average`,
+    };
+  });
+}
+
+function query() {
+  const params = new URLSearchParams();
+  for (const [k, v] of new FormData(form)) {
+    if (v != "") {
+      params.set(k, v);
+    }
+  }
+  const q = params.toString();
+  return q ? "?" + q : "";
+}
+
+async function get(path) {
+  const res = await fetch(path);
+  const body = await res.json();
+  if (!res.ok) {
+    throw new Error(body.error || res.statusText);
+  }
+  return body;
+}
+
+async function stats(name) {
+  return (await get(`../stats/${name}${query()}`)).rows;
+}
+
+// draws everything again for the current filters
+async function load() {
+  const error = document.getElementById("error");
+  try {
+    const [summary, libraries, ages, types, registrations, activity] = await Promise.all(
+      ["summary", "libraries", "ages", "patron_types", "registrations", "activity"].map(stats));
+    error.hidden = true;
+
+    const total = summary[0] || {};
+    document.getElementById("count").textContent = number(total.count);
+    document.getElementById("checkouts").textContent = number(total.avg_checkout_total);
+    document.getElementById("renewals").textContent = number(total.avg_renewal_total);
+
+    barsAcross(document.getElementById("libraries"), items(libraries, "home_library"));
+    barsUp(document.getElementById("ages"), items(ages, "age_range"));
+    donut(document.getElementById("patron_types"), items(types, "patron_type"));
+    barsUp(document.getElementById("registrations"), items(registrations, "year_registered"));
+    heatmap(document.getElementById("activity"), activity);
+  } catch (err) {
+    error.textContent = `Couldn't load the stats: ${err.message}`;
+    error.hidden = false;
+  }
+}
+
+// the patron types for the filter, then the charts
+async function start() {
+  try {
+    const select = form.elements.patron_type;
+    for (const t of (await get("../patron-types")).patron_types) {
+      const option = document.createElement("option");
+      option.value = t.code;
+      option.textContent = t.description;
+      select.appendChild(option);
+    }
+  } catch (err) {
+    // the charts still work without the list
+  }
+  form.addEventListener("change", load);
+  load();
+}
+
+start();
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+)
+
+// Filter is the filters every api takes: the query parameters here, the
+// filter argument in graphql and the Filter message in grpc. each one reads
+// its own into a Filter so they all match the same patrons.
+type Filter struct {
+	Library      []string // home library codes
+	AgeRange     []string
+	PatronType   []string // patron type codes
+	SF           *bool
+	ActiveYear   []int64
+	MinCheckouts *int64 // graphql and grpc only
+}
+
+// Where is the filter as a condition, nil when nothing in it is set. a
+// filter with several values matches any of them.
+func (f Filter) Where() dsl.Expr {
+	var where dsl.Expr
+	match := func(name string, values []interface{}) {
+		if len(values) > 0 {
+			field, _ := dsl.LookupField(name)
+			where = and(where, matchAny(field, values))
+		}
+	}
+	strs := func(list []string) []interface{} {
+		var values []interface{}
+		for _, s := range list {
+			values = append(values, s)
+		}
+		return values
+	}
+
+	match("home_library_code", strs(f.Library))
+	match("age_range", strs(f.AgeRange))
+	match("patron_type_code", strs(f.PatronType))
+	if f.SF != nil {
+		match("within_sfc", []interface{}{*f.SF})
+	}
+	var years []interface{}
+	for _, y := range f.ActiveYear {
+		years = append(years, y)
+	}
+	match("active_year", years)
+	if f.MinCheckouts != nil {
+		field, _ := dsl.LookupField("checkout_total")
+		where = and(where, &dsl.Compare{Field: field, Op: ">=", Values: []interface{}{*f.MinCheckouts}})
+	}
+	return where
+}
+
+// a query parameter that filters the patrons, on /patrons and /stats/{name}
+type filter struct {
+	param       string
+	description string
+	example     string
+
+	// puts the values of the parameter, split on commas, into the filter
+	set func(f *Filter, values []string) error
+}
+
+// filters are the filters in the order the spec lists them
+var filters = []filter{
+	{"library", "home library code, several are separated by commas", "X,N4",
+		func(f *Filter, values []string) error { f.Library = values; return nil }},
+	{"age_range", "age range, several are separated by commas", "25 to 34 years",
+		func(f *Filter, values []string) error { f.AgeRange = values; return nil }},
+	{"patron_type", "patron type code, several are separated by commas", "0",
+		func(f *Filter, values []string) error { f.PatronType = values; return nil }},
+	{"sf", "true for patrons who live in San Francisco, false for everyone else", "true",
+		func(f *Filter, values []string) error {
+			if len(values) > 1 {
+				return badRequest("expected true or false, got %q", strings.Join(values, ","))
+			}
+			sf, err := boolValue(values[0])
+			f.SF = &sf
+			return err
+		}},
+	{"active_year", "year the patron was last active, several are separated by commas", "2023",
+		func(f *Filter, values []string) error {
+			for _, text := range values {
+				n, err := strconv.ParseInt(text, 10, 64)
+				if err != nil {
+					return badRequest("expected a number, got %q", text)
+				}
+				f.ActiveYear = append(f.ActiveYear, n)
+			}
+			return nil
+		}},
+}
+
+// reads the filters into a condition, nil when there aren't any
+func parseFilters(query url.Values) (dsl.Expr, error) {
+	var f Filter
+	for _, p := range filters {
+		text := query.Get(p.param)
+		if text == "" {
+			continue
+		}
+		values := strings.Split(text, ",")
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
+		if err := p.set(&f, values); err != nil {
+			return nil, badRequest("%s: %v", p.param, err)
+		}
+	}
+	return f.Where(), nil
+}
+
+// field = value, or field in (values) when there's more than one
+func matchAny(field *dsl.Field, values []interface{}) dsl.Expr {
+	cond := &dsl.Compare{Field: field, Op: "=", Values: values}
+	if len(values) > 1 {
+		cond.Op = "in"
+	}
+	return cond
+}
+
+// joins two conditions, either can be nil
+func and(a, b dsl.Expr) dsl.Expr {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	return &dsl.And{Left: a, Right: b}
+}
+
+// reads true or false the way the query language does
+func boolValue(text string) (bool, error) {
+	switch strings.ToLower(text) {
+	case "true", "1", "yes":
+		return true, nil
+	case "false", "0", "no":
+		return false, nil
+	}
+	return false, badRequest("expected true or false, got %q", text)
+}
+
+// makes sure every parameter is a filter or one of the extra ones the
+// endpoint takes, so a typo doesn't quietly return everything
+func checkParams(query url.Values, extra ...string) error {
+	for name := range query {
+		known := false
+		for _, f := range filters {
+			known = known || f.param == name
+		}
+		for _, e := range extra {
+			known = known || e == name
+		}
+		if !known {
+			return badRequest("unknown parameter %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+)
+
+func TestFilterWhere(t *testing.T) {
+	sf := true
+	min := int64(100)
+	f := Filter{
+		Library:      []string{"X", "N4"},
+		AgeRange:     []string{"25 to 34 years"},
+		SF:           &sf,
+		ActiveYear:   []int64{2023},
+		MinCheckouts: &min,
+	}
+	sql, args := dsl.FilterSQL(f.Where())
+	wantSQL := "((((p.home_library_code IN (?, ?) AND p.age_range = ?) AND p.within_sfc = ?) AND p.active_year = ?) AND p.checkout_total >= ?)"
+	if sql != wantSQL {
+		t.Errorf("sql %s, want %s", sql, wantSQL)
+	}
+	if want := []interface{}{"X", "N4", "25 to 34 years", true, int64(2023), int64(100)}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args %#v, want %#v", args, want)
+	}
+	if (Filter{}).Where() != nil {
+		t.Error("an empty filter should be no condition at all")
+	}
+}
+
+// the json api and graphql read their filters into the same Filter, so the
+// same filter has to come out as the same condition
+func TestFiltersMatch(t *testing.T) {
+	sf := false
+	years := []int32{2022, 2023}
+	types := []string{"0"}
+	input := &filterInput{PatronType: &types, SF: &sf, ActiveYear: &years}
+	query := url.Values{"patron_type": {"0"}, "sf": {"false"}, "active_year": {"2022, 2023"}}
+
+	rest, err := parseFilters(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restSQL, restArgs := dsl.FilterSQL(rest)
+	gqlSQL, gqlArgs := dsl.FilterSQL(input.where())
+	if restSQL != gqlSQL || !reflect.DeepEqual(restArgs, gqlArgs) {
+		t.Errorf("rest %s %v\ngraphql %s %v", restSQL, restArgs, gqlSQL, gqlArgs)
+	}
+}
+
+func TestParseFiltersErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"sf=maybe", "sf: expected true or false"},
+		{"sf=true,false", "sf: expected true or false"},
+		{"active_year=2023,soon", `active_year: expected a number, got "soon"`},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		if _, err := parseFilters(query); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: %v, want %q", tt.query, err, tt.err)
+		}
+	}
+}
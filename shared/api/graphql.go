@@ -0,0 +1,569 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// the graphql schema served on /graphql. the lookups hang off the patrons
+// and the stats hang off the lookups, so a page of patrons with their
+// libraries, or every library with its counts by age range, is one request.
+const schemaText = `
+schema {
+	query: Query
+}
+
+type Query {
+	"patrons that match the filter, a page at a time in the order they were imported"
+	patrons(filter: PatronFilter, first: Int = 100, after: String): PatronPage!
+	libraries: [Library!]!
+	library(code: String!): Library
+	patronTypes: [PatronType!]!
+	notificationTypes: [NotificationType!]!
+	"counts and averages over the patrons that match the filter"
+	stats(filter: PatronFilter): Stats!
+}
+
+"every field that's given has to match, a list matches any of its values"
+input PatronFilter {
+	"home library codes"
+	library: [String!]
+	ageRange: [String!]
+	"patron type codes"
+	patronType: [String!]
+	"true for patrons who live in San Francisco"
+	sf: Boolean
+	activeYear: [Int!]
+	"at least this many checkouts"
+	minCheckouts: Int
+}
+
+type PatronPage {
+	patrons: [Patron!]!
+	"pass it as after for the next page, null on the last one"
+	next: String
+}
+
+type Patron {
+	id: ID!
+	ageRange: String!
+	checkoutTotal: Int
+	renewalTotal: Int
+	activeMonth: Int
+	activeYear: Int
+	email: String
+	withinSfc: Boolean!
+	yearRegistered: Int
+	library: Library
+	patronType: PatronType
+	notificationType: NotificationType
+}
+
+type Library {
+	code: String!
+	name: String!
+	"how many of its patrons match the filter"
+	patronCount(filter: PatronFilter): Int!
+	"stats over its patrons"
+	stats(filter: PatronFilter): Stats!
+}
+
+type PatronType {
+	code: String!
+	description: String!
+	patronCount(filter: PatronFilter): Int!
+	stats(filter: PatronFilter): Stats!
+}
+
+type NotificationType {
+	code: String!
+	description: String!
+	patronCount(filter: PatronFilter): Int!
+	stats(filter: PatronFilter): Stats!
+}
+
+type Stats {
+	count: Int!
+	avgCheckoutTotal: Float
+	avgRenewalTotal: Float
+	byAgeRange: [Group!]!
+	"by home library name"
+	byLibrary: [Group!]!
+	byPatronType: [Group!]!
+	byNotificationType: [Group!]!
+	"key is true for patrons who live in San Francisco"
+	byResidency: [Group!]!
+	byActiveYear: [Group!]!
+}
+
+type Group {
+	key: String
+	count: Int!
+	avgCheckoutTotal: Float
+	avgRenewalTotal: Float
+}
+`
+
+// the lookup tables, with the patron field that points into each
+type lookupTable struct {
+	name  string // table or collection
+	field string // field of the patron query language holding the code
+}
+
+var (
+	libraries         = lookupTable{"libraries", "home_library_code"}
+	patronTypes       = lookupTable{"patron_types", "patron_type_code"}
+	notificationTypes = lookupTable{"notification_types", "notification_code"}
+)
+
+func newSchema() *graphql.Schema {
+	return graphql.MustParseSchema(schemaText, &queryResolver{},
+		graphql.UseStringDescriptions(), graphql.MaxDepth(10))
+}
+
+// what a client posts to /graphql
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// POST /graphql. every request gets its own loaders so lookups are only
+// shared within it.
+func (s *server) graphql(w http.ResponseWriter, r *http.Request) {
+	var req graphqlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Query == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{`the body has to be json like {"query": "..."}`})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+	b := &batches{backend: s.backend, timeout: s.timeout, loaders: map[string]*loader{}}
+	ctx = context.WithValue(ctx, batchesKey{}, b)
+
+	res := s.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	status := http.StatusOK
+	switch {
+	case b.hasFailed() && ctx.Err() == context.DeadlineExceeded:
+		status = http.StatusGatewayTimeout
+	case b.hasFailed():
+		// the query was fine, the database wasn't
+		status = http.StatusInternalServerError
+	case res.Data == nil:
+		// it didn't get as far as running, the query itself is wrong
+		status = http.StatusBadRequest
+	}
+	if r.Context().Err() != nil {
+		return
+	}
+	writeJSON(w, status, res)
+}
+
+// runs a query for a resolver. the details of a failure stay in the
+// server's log like they do for the rest of the api.
+func runQuery(ctx context.Context, q *dsl.Query) ([][]output.Value, error) {
+	rows, err := backendOf(ctx).Query(ctx, q)
+	if err != nil {
+		return nil, queryFailed(ctx, err)
+	}
+	return rows, nil
+}
+
+// what the client is told when a query fails
+func queryFailed(ctx context.Context, err error) error {
+	b := ctx.Value(batchesKey{}).(*batches)
+	b.mu.Lock()
+	b.failed = true
+	b.mu.Unlock()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("the query took longer than %v", b.timeout)
+	}
+	fmt.Printf("graphql: %v\n", err)
+	return fmt.Errorf("the %s query failed", b.backend.Name)
+}
+
+// the arguments the resolvers take
+type (
+	filterInput struct {
+		Library      *[]string
+		AgeRange     *[]string
+		PatronType   *[]string
+		SF           *bool
+		ActiveYear   *[]int32
+		MinCheckouts *int32
+	}
+	filterArgs struct {
+		Filter *filterInput
+	}
+)
+
+// the filter as a condition, nil when it's empty
+func (f *filterInput) where() dsl.Expr {
+	if f == nil {
+		return nil
+	}
+	filter := Filter{SF: f.SF}
+	if f.Library != nil {
+		filter.Library = *f.Library
+	}
+	if f.AgeRange != nil {
+		filter.AgeRange = *f.AgeRange
+	}
+	if f.PatronType != nil {
+		filter.PatronType = *f.PatronType
+	}
+	if f.ActiveYear != nil {
+		for _, y := range *f.ActiveYear {
+			filter.ActiveYear = append(filter.ActiveYear, int64(y))
+		}
+	}
+	if f.MinCheckouts != nil {
+		n := int64(*f.MinCheckouts)
+		filter.MinCheckouts = &n
+	}
+	return filter.Where()
+}
+
+// tells filters apart in loader names, the same filter gives the same key
+func (f *filterInput) key() string {
+	data, _ := json.Marshal(f)
+	return string(data)
+}
+
+type queryResolver struct{}
+
+func (q *queryResolver) Patrons(ctx context.Context, args struct {
+	Filter *filterInput
+	First  int32
+	After  *string
+}) (*patronPageResolver, error) {
+	if args.First < 1 || args.First > MaxLimit {
+		return nil, fmt.Errorf("first has to be from 1 to %d", MaxLimit)
+	}
+	after := ""
+	if args.After != nil && *args.After != "" {
+		id, err := base64.RawURLEncoding.DecodeString(*args.After)
+		if err != nil || len(id) == 0 {
+			return nil, ErrBadCursor
+		}
+		after = string(id)
+	}
+
+	limit := int(args.First)
+	patrons, err := backendOf(ctx).Patrons(ctx, args.Filter.where(), after, limit+1)
+	if errors.Is(err, ErrBadCursor) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, queryFailed(ctx, err)
+	}
+	page := &patronPageResolver{}
+	if len(patrons) > limit {
+		patrons = patrons[:limit]
+		next := base64.RawURLEncoding.EncodeToString([]byte(patrons[limit-1].ID))
+		page.next = &next
+	}
+
+	// the patrons' lookups and their stats are fetched for the whole page at once
+	codes := map[lookupTable][]string{}
+	for _, p := range patrons {
+		codes[libraries] = append(codes[libraries], p.HomeLibraryCode)
+		codes[patronTypes] = append(codes[patronTypes], p.PatronTypeCode)
+		codes[notificationTypes] = append(codes[notificationTypes], p.NotificationCode)
+	}
+	for t, list := range codes {
+		codes[t] = distinct(list)
+		lookupLoader(ctx, t).want(codes[t]...)
+	}
+	for _, p := range patrons {
+		page.patrons = append(page.patrons, &patronResolver{p: p, codes: codes})
+	}
+	return page, nil
+}
+
+func (q *queryResolver) Libraries(ctx context.Context) ([]*lookupResolver, error) {
+	return allLookups(ctx, libraries)
+}
+
+func (q *queryResolver) Library(ctx context.Context, args struct{ Code string }) (*lookupResolver, error) {
+	return findLookup(ctx, libraries, args.Code, []string{args.Code})
+}
+
+func (q *queryResolver) PatronTypes(ctx context.Context) ([]*lookupResolver, error) {
+	return allLookups(ctx, patronTypes)
+}
+
+func (q *queryResolver) NotificationTypes(ctx context.Context) ([]*lookupResolver, error) {
+	return allLookups(ctx, notificationTypes)
+}
+
+func (q *queryResolver) Stats(args filterArgs) *statsResolver {
+	return &statsResolver{filter: args.Filter}
+}
+
+type patronPageResolver struct {
+	patrons []*patronResolver
+	next    *string
+}
+
+func (p *patronPageResolver) Patrons() []*patronResolver {
+	if p.patrons == nil {
+		return []*patronResolver{}
+	}
+	return p.patrons
+}
+
+func (p *patronPageResolver) Next() *string { return p.next }
+
+type patronResolver struct {
+	p     Patron
+	codes map[lookupTable][]string // the codes of the whole page, by table
+}
+
+func (p *patronResolver) ID() graphql.ID         { return graphql.ID(p.p.ID) }
+func (p *patronResolver) AgeRange() string       { return p.p.AgeRange }
+func (p *patronResolver) CheckoutTotal() *int32  { return int32Of(p.p.CheckoutTotal) }
+func (p *patronResolver) RenewalTotal() *int32   { return int32Of(p.p.RenewalTotal) }
+func (p *patronResolver) ActiveMonth() *int32    { return int32Of(p.p.ActiveMonth) }
+func (p *patronResolver) ActiveYear() *int32     { return int32Of(p.p.ActiveYear) }
+func (p *patronResolver) Email() *string         { return p.p.Email }
+func (p *patronResolver) WithinSfc() bool        { return p.p.WithinSFC }
+func (p *patronResolver) YearRegistered() *int32 { return int32Of(p.p.YearRegistered) }
+
+func (p *patronResolver) Library(ctx context.Context) (*lookupResolver, error) {
+	return findLookup(ctx, libraries, p.p.HomeLibraryCode, p.codes[libraries])
+}
+
+func (p *patronResolver) PatronType(ctx context.Context) (*lookupResolver, error) {
+	return findLookup(ctx, patronTypes, p.p.PatronTypeCode, p.codes[patronTypes])
+}
+
+func (p *patronResolver) NotificationType(ctx context.Context) (*lookupResolver, error) {
+	return findLookup(ctx, notificationTypes, p.p.NotificationCode, p.codes[notificationTypes])
+}
+
+func int32Of(n *int64) *int32 {
+	if n == nil {
+		return nil
+	}
+	v := int32(*n)
+	return &v
+}
+
+// the loader for a lookup table, code to name
+func lookupLoader(ctx context.Context, t lookupTable) *loader {
+	return loaderFor(ctx, "lookup "+t.name, func(ctx context.Context, codes []string) (map[string]interface{}, error) {
+		names, err := backendOf(ctx).Lookup(ctx, t.name, codes)
+		if err != nil {
+			return nil, queryFailed(ctx, err)
+		}
+		values := map[string]interface{}{}
+		for code, name := range names {
+			values[code] = name
+		}
+		return values, nil
+	})
+}
+
+// every row of a lookup table in code order
+func allLookups(ctx context.Context, t lookupTable) ([]*lookupResolver, error) {
+	names, err := backendOf(ctx).Lookup(ctx, t.name, nil)
+	if err != nil {
+		return nil, queryFailed(ctx, err)
+	}
+	var codes []string
+	for code := range names {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	list := []*lookupResolver{}
+	for _, code := range codes {
+		list = append(list, &lookupResolver{table: t, code: code, name: names[code], siblings: codes})
+	}
+	return list, nil
+}
+
+// one row of a lookup table, nil when the code isn't in it. siblings are the
+// codes the other resolvers of the same list have, they're loaded together.
+func findLookup(ctx context.Context, t lookupTable, code string, siblings []string) (*lookupResolver, error) {
+	if code == "" {
+		return nil, nil
+	}
+	name, err := lookupLoader(ctx, t).load(ctx, code)
+	if err != nil || name == nil {
+		return nil, err
+	}
+	return &lookupResolver{table: t, code: code, name: name.(string), siblings: siblings}, nil
+}
+
+// a library, patron type or notification type
+type lookupResolver struct {
+	table    lookupTable
+	code     string
+	name     string
+	siblings []string
+}
+
+func (l *lookupResolver) Code() string        { return l.code }
+func (l *lookupResolver) Name() string        { return l.name }
+func (l *lookupResolver) Description() string { return l.name }
+
+func (l *lookupResolver) PatronCount(ctx context.Context, args filterArgs) (int32, error) {
+	return l.Stats(args).Count(ctx)
+}
+
+func (l *lookupResolver) Stats(args filterArgs) *statsResolver {
+	return &statsResolver{filter: args.Filter, parent: &l.table, code: l.code, siblings: l.siblings}
+}
+
+// stats over the patrons that match the filter. under a lookup they're only
+// that lookup's patrons, and every lookup in the list gets its stats from
+// the same query, grouped by the lookup's code.
+type statsResolver struct {
+	filter   *filterInput
+	parent   *lookupTable
+	code     string
+	siblings []string
+}
+
+type groupResolver struct {
+	key              *string
+	count            int32
+	avgCheckoutTotal *float64
+	avgRenewalTotal  *float64
+}
+
+func (g *groupResolver) Key() *string               { return g.key }
+func (g *groupResolver) Count() int32               { return g.count }
+func (g *groupResolver) AvgCheckoutTotal() *float64 { return g.avgCheckoutTotal }
+func (g *groupResolver) AvgRenewalTotal() *float64  { return g.avgRenewalTotal }
+
+// the groups by field, or the one row for everything when field is ""
+func (s *statsResolver) groups(ctx context.Context, field string) ([]*groupResolver, error) {
+	var group []string
+	if s.parent != nil {
+		group = append(group, s.parent.field)
+	}
+	if field != "" {
+		group = append(group, field)
+	}
+	name := fmt.Sprintf("stats by %s %s", strings.Join(group, ", "), s.filter.key())
+
+	l := loaderFor(ctx, name, func(ctx context.Context, codes []string) (map[string]interface{}, error) {
+		where := s.filter.where()
+		if s.parent != nil {
+			parent, _ := dsl.LookupField(s.parent.field)
+			var values []interface{}
+			for _, c := range codes {
+				values = append(values, c)
+			}
+			where = and(where, matchAny(parent, values))
+		}
+		rows, err := Groups(ctx, runQuery, where, group...)
+		if err != nil {
+			return nil, err
+		}
+
+		// the groups by the code of the lookup they belong to, "" at the top
+		values := map[string]interface{}{}
+		for _, row := range rows {
+			code, keys := "", row.Keys
+			if s.parent != nil {
+				if keys[0] != nil {
+					code = *keys[0]
+				}
+				keys = keys[1:]
+			}
+			g := &groupResolver{count: int32(row.Count), avgCheckoutTotal: row.AvgCheckoutTotal, avgRenewalTotal: row.AvgRenewalTotal}
+			if field != "" {
+				g.key = keys[0]
+			}
+			list, _ := values[code].([]*groupResolver)
+			values[code] = append(list, g)
+		}
+		return values, nil
+	})
+	if s.parent != nil {
+		l.want(s.siblings...)
+	}
+	v, err := l.load(ctx, s.code)
+	if err != nil {
+		return nil, err
+	}
+	list, _ := v.([]*groupResolver)
+	if list == nil {
+		list = []*groupResolver{}
+	}
+	return list, nil
+}
+
+// the one group of the overall stats, an empty one when nothing matched
+func (s *statsResolver) total(ctx context.Context) (*groupResolver, error) {
+	list, err := s.groups(ctx, "")
+	if err != nil || len(list) == 0 {
+		return &groupResolver{}, err
+	}
+	return list[0], nil
+}
+
+func (s *statsResolver) Count(ctx context.Context) (int32, error) {
+	g, err := s.total(ctx)
+	return g.count, err
+}
+
+func (s *statsResolver) AvgCheckoutTotal(ctx context.Context) (*float64, error) {
+	g, err := s.total(ctx)
+	return g.avgCheckoutTotal, err
+}
+
+func (s *statsResolver) AvgRenewalTotal(ctx context.Context) (*float64, error) {
+	g, err := s.total(ctx)
+	return g.avgRenewalTotal, err
+}
+
+func (s *statsResolver) ByAgeRange(ctx context.Context) ([]*groupResolver, error) {
+	return s.groups(ctx, "age_range")
+}
+
+func (s *statsResolver) ByLibrary(ctx context.Context) ([]*groupResolver, error) {
+	return s.groups(ctx, "home_library")
+}
+
+func (s *statsResolver) ByPatronType(ctx context.Context) ([]*groupResolver, error) {
+	return s.groups(ctx, "patron_type")
+}
+
+func (s *statsResolver) ByNotificationType(ctx context.Context) ([]*groupResolver, error) {
+	return s.groups(ctx, "notification")
+}
+
+func (s *statsResolver) ByResidency(ctx context.Context) ([]*groupResolver, error) {
+	return s.groups(ctx, "within_sfc")
+}
+
+func (s *statsResolver) ByActiveYear(ctx context.Context) ([]*groupResolver, error) {
+	return s.groups(ctx, "active_year")
+}
+
+func distinct(list []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, s := range list {
+		if s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
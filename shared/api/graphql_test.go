@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// the status of a graphql request says whose fault a failure was
+func TestGraphQLStatus(t *testing.T) {
+	works := func(ctx context.Context, q *dsl.Query) ([][]output.Value, error) {
+		return [][]output.Value{{output.Value{}}}, nil
+	}
+	fails := func(ctx context.Context, q *dsl.Query) ([][]output.Value, error) {
+		return nil, errors.New("connection refused")
+	}
+	hangs := func(ctx context.Context, q *dsl.Query) ([][]output.Value, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	tests := []struct {
+		name  string
+		query func(ctx context.Context, q *dsl.Query) ([][]output.Value, error)
+		body  string
+		want  int
+	}{
+		{"not json", works, `{"query": `, http.StatusBadRequest},
+		{"parse error", works, `{"query": "{ stats { count "}`, http.StatusBadRequest},
+		{"unknown field", works, `{"query": "{ stats { nope } }"}`, http.StatusBadRequest},
+		// a resolver turning down an argument is an error in errors, like
+		// graphql has it for anything that goes wrong while running
+		{"bad argument", works, `{"query": "{ patrons(first: 0) { next } }"}`, http.StatusOK},
+		{"backend fails", fails, `{"query": "{ stats { count } }"}`, http.StatusInternalServerError},
+		{"backend too slow", hangs, `{"query": "{ stats { count } }"}`, http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		h := Handler(Backend{Name: "mysql", Query: tt.query}, 50*time.Millisecond)
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// loader batches the lookups of one graphql request. the resolver that
+// returns a list tells the loader every key its items are going to ask for,
+// and the first item that asks fetches all of them in one query, so 30
+// libraries cost one query instead of 30.
+type loader struct {
+	fetch func(ctx context.Context, keys []string) (map[string]interface{}, error)
+
+	mu      sync.Mutex
+	known   map[string]bool // keys that were asked for, fetched or not
+	pending []string        // keys that still have to be fetched
+	values  map[string]interface{}
+	err     error // the fetch failed, every load after it gets this too
+}
+
+func newLoader(fetch func(ctx context.Context, keys []string) (map[string]interface{}, error)) *loader {
+	return &loader{fetch: fetch, known: map[string]bool{}, values: map[string]interface{}{}}
+}
+
+// want adds keys to the next fetch
+func (l *loader) want(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.add(keys)
+}
+
+func (l *loader) add(keys []string) {
+	for _, k := range keys {
+		if !l.known[k] {
+			l.known[k] = true
+			l.pending = append(l.pending, k)
+		}
+	}
+}
+
+// load returns the value of a key, fetching it and everything else that's
+// wanted when it isn't there yet. keys the fetch didn't find are nil.
+func (l *loader) load(ctx context.Context, key string) (interface{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.add([]string{key})
+	if len(l.pending) > 0 && l.err == nil {
+		values, err := l.fetch(ctx, l.pending)
+		l.pending = nil
+		if err != nil {
+			l.err = err
+		}
+		for k, v := range values {
+			l.values[k] = v
+		}
+	}
+	if l.err != nil {
+		return nil, l.err
+	}
+	return l.values[key], nil
+}
+
+// the loaders of one request, by what they load
+type batches struct {
+	backend Backend
+	timeout time.Duration
+	mu      sync.Mutex
+	loaders map[string]*loader
+	failed  bool // a query to the backend failed
+}
+
+// whether a resolver's query failed, the status is then the server's fault
+func (b *batches) hasFailed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failed
+}
+
+type batchesKey struct{}
+
+// the request's loader called name, made with fetch the first time it's asked for
+func loaderFor(ctx context.Context, name string, fetch func(ctx context.Context, keys []string) (map[string]interface{}, error)) *loader {
+	b := ctx.Value(batchesKey{}).(*batches)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l, ok := b.loaders[name]
+	if !ok {
+		l = newLoader(fetch)
+		b.loaders[name] = l
+	}
+	return l
+}
+
+func backendOf(ctx context.Context) Backend {
+	return ctx.Value(batchesKey{}).(*batches).backend
+}
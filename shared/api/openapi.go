@@ -0,0 +1,239 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jacksongodsey/SFILS/shared/report"
+)
+
+// a parameter for the spec
+type param struct {
+	name        string
+	in          string // query or path
+	description string
+	schema      map[string]interface{}
+	example     interface{}
+}
+
+// one endpoint. the spec is built from these, so what's served and what's
+// documented can't drift apart.
+type route struct {
+	pattern  string
+	summary  string
+	filters  bool // takes the patron filters
+	params   []param
+	body     interface{} // a value of the type it takes as a json body, the route is a POST when it has one
+	response interface{} // a value of the type it sends back
+	errors   []int       // status codes it can answer with besides 200
+	handle   http.HandlerFunc
+}
+
+func (r route) method() string {
+	if r.body != nil {
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+func (s *server) routes() []route {
+	reportNames := report.Names()
+	return []route{
+		{
+			pattern: "/patrons",
+			summary: "Patrons that match the filters, a page at a time in the order they were imported",
+			filters: true,
+			params: []param{
+				{name: "limit", in: "query", description: "patrons per page",
+					schema: map[string]interface{}{"type": "integer", "minimum": 1, "maximum": MaxLimit, "default": DefaultLimit}},
+				{name: "cursor", in: "query", description: "next from the page before, leave it out for the first page",
+					schema: map[string]interface{}{"type": "string"}},
+			},
+			response: patronPage{},
+			errors:   []int{400, 500, 504},
+			handle:   s.handle(s.patrons),
+		},
+		{
+			pattern:  "/libraries",
+			summary:  "Home libraries with how many of the filtered patrons use each",
+			filters:  true,
+			response: libraryList{},
+			errors:   []int{400, 500, 504},
+			handle:   s.handle(s.libraries),
+		},
+		{
+			pattern:  "/patron-types",
+			summary:  "Patron types with how many of the filtered patrons have each",
+			filters:  true,
+			response: patronTypeList{},
+			errors:   []int{400, 500, 504},
+			handle:   s.handle(s.patronTypes),
+		},
+		{
+			pattern:  "/stats",
+			summary:  "The stats that are available",
+			response: statsList{},
+			handle:   s.handle(s.statsList),
+		},
+		{
+			pattern: "/stats/{name}",
+			summary: "One of the built-in reports on the filtered patrons, one row per group",
+			filters: true,
+			params: []param{
+				{name: "name", in: "path", description: "which stats",
+					schema: map[string]interface{}{"type": "string", "enum": reportNames}},
+			},
+			response: stats{},
+			errors:   []int{400, 404, 500, 504},
+			handle:   s.handle(s.stats),
+		},
+		{
+			pattern:  "/graphql",
+			summary:  "A GraphQL query over the patrons, the lookup tables and their stats, see the schema by introspection",
+			body:     graphqlRequest{},
+			response: map[string]interface{}{}, // data and errors, data's shape depends on the query
+			errors:   []int{400, 500, 504},
+			handle:   s.graphql,
+		},
+		{
+			pattern:  "/openapi.json",
+			summary:  "This description of the api",
+			response: map[string]interface{}{},
+			handle:   s.handle(s.spec),
+		},
+	}
+}
+
+// Spec is the OpenAPI 3 description of the api served on top of a backend
+func Spec(backend string) map[string]interface{} {
+	s := &server{backend: Backend{Name: backend}}
+	schemas := map[string]interface{}{}
+	errorSchema := schemaOf(reflect.TypeOf(errorResponse{}), schemas)
+
+	paths := map[string]interface{}{}
+	for _, r := range s.routes() {
+		var params []interface{}
+		for _, p := range r.params {
+			params = append(params, paramSpec(p))
+		}
+		if r.filters {
+			for _, f := range filters {
+				p := param{name: f.param, in: "query", description: f.description, example: f.example,
+					schema: map[string]interface{}{"type": "string"}}
+				if f.param == "sf" {
+					p.schema, p.example = map[string]interface{}{"type": "boolean"}, true
+				}
+				params = append(params, paramSpec(p))
+			}
+		}
+
+		responses := map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "OK",
+				"content":     jsonContent(schemaOf(reflect.TypeOf(r.response), schemas)),
+			},
+		}
+		for _, code := range r.errors {
+			responses[strconv.Itoa(code)] = map[string]interface{}{
+				"description": http.StatusText(code),
+				"content":     jsonContent(errorSchema),
+			}
+		}
+
+		op := map[string]interface{}{
+			"summary":     r.summary,
+			"operationId": operationID(r.method(), r.pattern),
+			"responses":   responses,
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if r.body != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemaOf(reflect.TypeOf(r.body), schemas)),
+			}
+		}
+		paths[r.pattern] = map[string]interface{}{strings.ToLower(r.method()): op}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "SFILS patron data",
+			"version":     "1",
+			"description": "Read-only access to the San Francisco library patron data, served from " + backend + ". Errors come back as {\"error\": \"...\"}.",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+func paramSpec(p param) map[string]interface{} {
+	spec := map[string]interface{}{
+		"name":        p.name,
+		"in":          p.in,
+		"description": p.description,
+		"required":    p.in == "path",
+		"schema":      p.schema,
+	}
+	if p.example != nil {
+		spec["example"] = p.example
+	}
+	return spec
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// GET /stats/{name} becomes getStatsName
+func operationID(method, pattern string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(pattern, func(r rune) bool { return strings.ContainsRune("/{}-.", r) }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+// the schema of a go type from its json tags. structs go into schemas under
+// their name and get referenced.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := schemaOf(t.Elem(), schemas)
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": true}
+	case reflect.Struct:
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, done := schemas[name]; !done {
+			schemas[name] = nil // stops a type that contains itself from going round forever
+			props := map[string]interface{}{}
+			var required []string
+			for i := 0; i < t.NumField(); i++ {
+				tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+				if tag == "" || tag == "-" {
+					continue
+				}
+				props[tag] = schemaOf(t.Field(i).Type, schemas)
+				required = append(required, tag)
+			}
+			schemas[name] = map[string]interface{}{"type": "object", "properties": props, "required": required}
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	// anything else, like the values of a stats row
+	return map[string]interface{}{}
+}
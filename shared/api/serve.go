@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Command is the serve command of both apps. it serves the api until ctrl-c
+// or a SIGTERM and then gives the requests that are still running a few
+// seconds to finish.
+//
+//	go run . serve -addr :8080
+func Command(b Backend, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	timeout := flags.Duration("timeout", 30*time.Second, "how long a request can take before it's stopped")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . serve [flags]")
+		fmt.Fprintln(flags.Output(), "       go run . serve spec [-out FILE]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           Handler(b, *timeout),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	failed := make(chan error, 1)
+	go func() {
+		fmt.Printf("serving the %s patron data on %s, the dashboard is at /dashboard/ and the spec at /openapi.json\n", b.Name, *addr)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			failed <- err
+		}
+		close(failed)
+	}()
+
+	select {
+	case err := <-failed:
+		return err
+	case <-ctx.Done():
+	}
+	fmt.Println("\nstopping, waiting for the requests that are running")
+	shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdown)
+}
+
+// SpecCommand is serve spec, it writes the OpenAPI description without
+// needing a database so other teams can generate clients from it
+//
+//	go run . serve spec -out ../docs/openapi.json
+func SpecCommand(backend string, args []string) error {
+	flags := flag.NewFlagSet("serve spec", flag.ExitOnError)
+	out := flags.String("out", "", "file to write it to instead of printing it")
+	flags.Parse(args)
+
+	data, err := json.MarshalIndent(Spec(backend), "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		return fmt.Errorf("couldn't write the spec: %v", err)
+	}
+	fmt.Println("spec written to", *out)
+	return nil
+}
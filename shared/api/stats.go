@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// Group is the count and averages of the patrons that have the same values
+// of the fields a query groups by
+type Group struct {
+	Keys             []*string // the value of each field as text, nil for patrons that don't have one
+	Count            int64
+	AvgCheckoutTotal *float64
+	AvgRenewalTotal  *float64
+}
+
+// Groups counts the patrons that match where and averages their checkouts
+// and renewals, grouped by the fields in that order, or as the one group of
+// everyone without any. run sends the query to the backend, Backend.Query
+// or something wrapped around it. graphql and grpc both get their stats
+// from here.
+func Groups(ctx context.Context, run func(ctx context.Context, q *dsl.Query) ([][]output.Value, error), where dsl.Expr, fields ...string) ([]Group, error) {
+	text := "patrons count, avg checkout_total, avg renewal_total"
+	if len(fields) > 0 {
+		list := strings.Join(fields, ", ")
+		text = fmt.Sprintf("patrons group by %s count, avg checkout_total, avg renewal_total order by %s", list, list)
+	}
+	q, err := dsl.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	q.Where = where
+	rows, err := run(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]Group, len(rows))
+	for i, row := range rows {
+		g := Group{}
+		for _, field := range fields {
+			g.Keys = append(g.Keys, groupKey(field, row[0]))
+			row = row[1:]
+		}
+		g.Count, _ = strconv.ParseInt(row[0].Text, 10, 64)
+		g.AvgCheckoutTotal = floatOf(row[1])
+		g.AvgRenewalTotal = floatOf(row[2])
+		groups[i] = g
+	}
+	return groups, nil
+}
+
+// a group's value as text, booleans come back from mysql as 0 and 1
+func groupKey(field string, v output.Value) *string {
+	if v.Kind == output.Null {
+		return nil
+	}
+	text := v.Text
+	if f, _ := dsl.LookupField(field); f.Type == dsl.Bool && v.Kind == output.Number {
+		text = strconv.FormatBool(text != "0")
+	}
+	return &text
+}
+
+func floatOf(v output.Value) *float64 {
+	f, err := strconv.ParseFloat(v.Text, 64)
+	if v.Kind == output.Null || err != nil {
+		return nil
+	}
+	return &f
+}
@@ -0,0 +1,195 @@
+// Package bench times queries for the benchmark command in both apps. a
+// single run says more about what was in the cache than about the query, so
+// every query gets some warmup runs that aren't counted and then enough
+// measured runs to work out percentiles and how much the timings spread.
+package bench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// Options are how many times to run each query
+type Options struct {
+	Warmup int `json:"warmup"` // runs before measuring that aren't counted
+	Runs   int `json:"runs"`   // measured runs
+
+	// Flush clears the backend's caches before every measured run, for
+	// timings closer to a cold start. what it can clear depends on the backend.
+	Flush bool `json:"flush"`
+}
+
+// DefaultOptions are used by the benchmark command in the text interface
+var DefaultOptions = Options{Warmup: 2, Runs: 10}
+
+// Case is one query to time. Run returns how many rows or documents it got.
+type Case struct {
+	Name string
+	Run  func() (int, error)
+}
+
+// Stats summarise the measured runs of one query
+type Stats struct {
+	Runs   int
+	Min    time.Duration
+	Median time.Duration
+	P95    time.Duration
+	P99    time.Duration
+	Max    time.Duration
+	Mean   time.Duration
+	StdDev time.Duration
+}
+
+// Result is how one query did
+type Result struct {
+	Name     string
+	Rows     int
+	Stats    Stats
+	Err      error
+	Checksum string // Fingerprint of what the query returned, "" when it wasn't checked
+}
+
+// Measure runs the warmup and then the measured runs of a case. flush is
+// called before every measured run when opts.Flush is set and isn't timed.
+func Measure(c Case, opts Options, flush func() error) Result {
+	res := Result{Name: c.Name}
+	for i := 0; i < opts.Warmup; i++ {
+		if _, err := c.Run(); err != nil {
+			res.Err = err
+			return res
+		}
+	}
+
+	samples := make([]time.Duration, 0, opts.Runs)
+	for i := 0; i < opts.Runs; i++ {
+		if opts.Flush && flush != nil {
+			if err := flush(); err != nil {
+				res.Err = fmt.Errorf("couldn't flush caches: %v", err)
+				return res
+			}
+		}
+		start := time.Now()
+		rows, err := c.Run()
+		elapsed := time.Since(start)
+		if err != nil {
+			res.Err = err
+			return res
+		}
+		res.Rows = rows
+		samples = append(samples, elapsed)
+	}
+	res.Stats = Summarize(samples)
+	return res
+}
+
+// Summarize works out the stats for a set of timings
+func Summarize(samples []time.Duration) Stats {
+	if len(samples) == 0 {
+		return Stats{}
+	}
+	sorted := append([]time.Duration{}, samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sum float64
+	for _, d := range sorted {
+		sum += float64(d)
+	}
+	mean := sum / float64(len(sorted))
+
+	// sample standard deviation, nothing to spread with a single run
+	var sq float64
+	for _, d := range sorted {
+		sq += (float64(d) - mean) * (float64(d) - mean)
+	}
+	stddev := 0.0
+	if len(sorted) > 1 {
+		stddev = math.Sqrt(sq / float64(len(sorted)-1))
+	}
+
+	return Stats{
+		Runs:   len(sorted),
+		Min:    sorted[0],
+		Median: median(sorted),
+		P95:    Percentile(sorted, 95),
+		P99:    Percentile(sorted, 99),
+		Max:    sorted[len(sorted)-1],
+		Mean:   time.Duration(mean),
+		StdDev: time.Duration(stddev),
+	}
+}
+
+func median(sorted []time.Duration) time.Duration {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// Percentile is the nearest-rank percentile of timings that are already
+// sorted, so p99 of 10 runs is the slowest one rather than a made up value
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Millis formats a duration as milliseconds with 2 decimals
+func Millis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 2, 64)
+}
+
+// WriteTable prints the results as a table with the timings in milliseconds.
+// queries that failed are listed under it with their error.
+func WriteTable(w io.Writer, results []Result) error {
+	out, err := output.New("table", w, output.Options{MaxWidth: output.DefaultMaxWidth})
+	if err != nil {
+		return err
+	}
+	cols := []output.Column{{Name: "query"}, {Name: "rows", Numeric: true}, {Name: "runs", Numeric: true}}
+	for _, name := range []string{"min_ms", "median_ms", "p95_ms", "p99_ms", "max_ms", "stddev_ms"} {
+		cols = append(cols, output.Column{Name: name, Numeric: true})
+	}
+	if err := out.Begin(cols); err != nil {
+		return err
+	}
+
+	var failed []Result
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+			continue
+		}
+		s := r.Stats
+		row := []output.Value{
+			{Kind: output.String, Text: r.Name},
+			{Kind: output.Number, Text: strconv.Itoa(r.Rows)},
+			{Kind: output.Number, Text: strconv.Itoa(s.Runs)},
+		}
+		for _, d := range []time.Duration{s.Min, s.Median, s.P95, s.P99, s.Max, s.StdDev} {
+			row = append(row, output.Value{Kind: output.Number, Text: Millis(d)})
+		}
+		if err := out.Row(row); err != nil {
+			return err
+		}
+	}
+	if err := out.End(); err != nil {
+		return err
+	}
+
+	for _, r := range failed {
+		fmt.Fprintf(w, "%s: error - %v\n", r.Name, r.Err)
+	}
+	return nil
+}
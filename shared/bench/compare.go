@@ -0,0 +1,179 @@
+package bench
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// DefaultThreshold is how much slower, in percent, a query's median can get
+// before compare calls it a regression
+const DefaultThreshold = 10.0
+
+// Change is how one query's median moved between two runs
+type Change struct {
+	Name     string
+	Old, New float64 // median in ms, 0 when the query is missing or failed
+	Percent  float64
+	Status   string // ok, regressed, faster, new, missing or failed
+}
+
+// Compare lines up the queries of two runs by name. a query regressed when
+// its median got more than threshold percent slower, and is faster when it
+// got more than threshold percent quicker.
+func Compare(base, head *Run, threshold float64) []Change {
+	before := map[string]Query{}
+	for _, q := range base.Queries {
+		before[q.Name] = q
+	}
+
+	var changes []Change
+	seen := map[string]bool{}
+	for _, q := range head.Queries {
+		seen[q.Name] = true
+		c := Change{Name: q.Name, New: q.MedianMs}
+		o, ok := before[q.Name]
+		switch {
+		case q.Error != "" || (ok && o.Error != ""):
+			c.Status = "failed"
+		case !ok:
+			c.Status = "new"
+		default:
+			c.Old = o.MedianMs
+			if o.MedianMs > 0 {
+				c.Percent = (q.MedianMs - o.MedianMs) / o.MedianMs * 100
+			}
+			switch {
+			case c.Percent > threshold:
+				c.Status = "regressed"
+			case c.Percent < -threshold:
+				c.Status = "faster"
+			default:
+				c.Status = "ok"
+			}
+		}
+		changes = append(changes, c)
+	}
+	for _, q := range base.Queries {
+		if !seen[q.Name] {
+			changes = append(changes, Change{Name: q.Name, Old: q.MedianMs, Status: "missing"})
+		}
+	}
+	return changes
+}
+
+// WriteComparison prints the changes as a table, with a warning first when
+// the runs weren't on the same backend, data or machine
+func WriteComparison(w io.Writer, base, head *Run, changes []Change) error {
+	if base.Backend != head.Backend {
+		fmt.Fprintf(w, "warning: comparing %s with %s\n", base.Backend, head.Backend)
+	}
+	if base.Suite != head.Suite {
+		fmt.Fprintf(w, "warning: comparing the %s suite with the %s suite\n", base.Suite, head.Suite)
+	}
+	if base.Dataset != head.Dataset {
+		fmt.Fprintln(w, "warning: the runs were on different data")
+	}
+	if base.Host.Hostname != head.Host.Hostname || base.Host.Server != head.Host.Server {
+		fmt.Fprintf(w, "warning: the runs were on different hosts (%s %s, %s %s)\n",
+			base.Host.Hostname, base.Host.Server, head.Host.Hostname, head.Host.Server)
+	}
+
+	out, err := output.New("table", w, output.Options{MaxWidth: output.DefaultMaxWidth})
+	if err != nil {
+		return err
+	}
+	cols := []output.Column{
+		{Name: "query"},
+		{Name: "old_median_ms", Numeric: true},
+		{Name: "new_median_ms", Numeric: true},
+		{Name: "change_%", Numeric: true},
+		{Name: "status"},
+	}
+	if err := out.Begin(cols); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		row := []output.Value{
+			{Kind: output.String, Text: c.Name},
+			number(c.Old, c.Status != "new" && c.Status != "failed"),
+			number(c.New, c.Status != "missing" && c.Status != "failed"),
+			number(c.Percent, c.Status == "ok" || c.Status == "regressed" || c.Status == "faster"),
+			{Kind: output.String, Text: c.Status},
+		}
+		if err := out.Row(row); err != nil {
+			return err
+		}
+	}
+	return out.End()
+}
+
+func number(v float64, ok bool) output.Value {
+	if !ok {
+		return output.Value{Kind: output.Null}
+	}
+	return output.Value{Kind: output.Number, Text: strconv.FormatFloat(v, 'f', 2, 64)}
+}
+
+// CompareCommand is bench compare, the same in both apps. it fails when a
+// query regressed, failed or is missing from the new run so it can stop a
+// script.
+//
+//	go run . bench compare ../results/bench-mysql-20240101-120000.json ../results/bench-mysql-20240102-120000.json
+func CompareCommand(args []string) error {
+	flags := flag.NewFlagSet("bench compare", flag.ExitOnError)
+	threshold := flags.Float64("threshold", DefaultThreshold, "percent a median can get slower before it counts as a regression")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . bench compare [flags] OLD.json NEW.json")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	base, err := Load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	head, err := Load(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	changes := Compare(base, head, *threshold)
+	if err := WriteComparison(os.Stdout, base, head, changes); err != nil {
+		return err
+	}
+
+	return checkChanges(changes, *threshold)
+}
+
+// the error compare fails with, nil when every query is still there, still
+// works and isn't slower. a query that broke is worse than a slow one.
+func checkChanges(changes []Change, threshold float64) error {
+	count := map[string]int{}
+	for _, c := range changes {
+		count[c.Status]++
+	}
+	var problems []string
+	if n := count["failed"]; n > 0 {
+		problems = append(problems, fmt.Sprintf("%d queries failed", n))
+	}
+	if n := count["missing"]; n > 0 {
+		problems = append(problems, fmt.Sprintf("%d queries are missing from the new run", n))
+	}
+	if n := count["regressed"]; n > 0 {
+		problems = append(problems, fmt.Sprintf("%d queries got more than %g%% slower", n, threshold))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, ", "))
+	}
+	return nil
+}
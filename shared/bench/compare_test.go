@@ -0,0 +1,67 @@
+package bench
+
+import (
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	base := &Run{Queries: []Query{
+		{Name: "same", MedianMs: 10},
+		{Name: "slower", MedianMs: 10},
+		{Name: "quicker", MedianMs: 10},
+		{Name: "broke", MedianMs: 10},
+		{Name: "dropped", MedianMs: 10},
+	}}
+	head := &Run{Queries: []Query{
+		{Name: "same", MedianMs: 10.5},
+		{Name: "slower", MedianMs: 12},
+		{Name: "quicker", MedianMs: 5},
+		{Name: "broke", Error: "table doesn't exist"},
+		{Name: "added", MedianMs: 3},
+	}}
+
+	want := map[string]string{
+		"same":    "ok",
+		"slower":  "regressed",
+		"quicker": "faster",
+		"broke":   "failed",
+		"added":   "new",
+		"dropped": "missing",
+	}
+	changes := Compare(base, head, DefaultThreshold)
+	if len(changes) != len(want) {
+		t.Fatalf("%d changes, want %d", len(changes), len(want))
+	}
+	for _, c := range changes {
+		if c.Status != want[c.Name] {
+			t.Errorf("%s: %s, want %s", c.Name, c.Status, want[c.Name])
+		}
+	}
+}
+
+func TestCheckChanges(t *testing.T) {
+	tests := []struct {
+		statuses []string
+		err      string
+	}{
+		{[]string{"ok", "faster", "new"}, ""},
+		{[]string{"ok", "regressed"}, "1 queries got more than 10% slower"},
+		{[]string{"ok", "failed"}, "1 queries failed"},
+		{[]string{"missing", "ok"}, "1 queries are missing from the new run"},
+		{[]string{"regressed", "failed", "failed", "missing"}, "2 queries failed, 1 queries are missing from the new run, 1 queries got more than 10% slower"},
+	}
+	for _, tt := range tests {
+		var changes []Change
+		for _, s := range tt.statuses {
+			changes = append(changes, Change{Status: s})
+		}
+		err := checkChanges(changes, DefaultThreshold)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.err {
+			t.Errorf("%v: %q, want %q", tt.statuses, got, tt.err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package bench
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// Fingerprint hashes a query's result so the results from mysql and mongodb
+// can be compared without keeping them. the two don't agree on the small
+// things, so before hashing the rows are sorted, booleans become 1 and 0,
+// numbers are rounded to 2 decimals (mysql's AVG has 4, mongodb's $avg all
+// of them) and NULL is the same as an empty string. column names aren't
+// part of it, only the values in column order.
+func Fingerprint(rows [][]output.Value) string {
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		values := make([]string, len(row))
+		for j, v := range row {
+			values[j] = Normalize(v)
+		}
+		lines[i] = strings.Join(values, "\x1f")
+	}
+	sort.Strings(lines)
+
+	h := sha256.New()
+	for _, line := range lines {
+		h.Write([]byte(line))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Normalize is how a value looks when it's compared between the backends
+func Normalize(v output.Value) string {
+	switch v.Kind {
+	case output.Null:
+		return ""
+	case output.Bool:
+		if v.Text == "true" {
+			return "1"
+		}
+		return "0"
+	}
+	// mongodb keeps the numbers from the workbook as strings, so anything
+	// that reads as a number is one
+	if f, err := strconv.ParseFloat(v.Text, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+	}
+	return v.Text
+}
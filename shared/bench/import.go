@@ -0,0 +1,192 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// Strategies are the ways bench import can write the patrons:
+//
+//	single      one insert per row
+//	batched     many rows per insert
+//	concurrent  batched inserts from several workers at once
+var Strategies = []string{"single", "batched", "concurrent"}
+
+// ParseStrategies reads a comma separated list of strategies, "all" for
+// every one of them
+func ParseStrategies(list string) ([]string, error) {
+	if list == "all" {
+		return Strategies, nil
+	}
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		known := false
+		for _, s := range Strategies {
+			known = known || s == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown strategy %q, the strategies are %s", name, strings.Join(Strategies, ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Phase is how long one step of an import took and how much it allocated
+type Phase struct {
+	Name    string
+	Rows    int
+	Elapsed time.Duration
+	Allocs  uint64 // heap objects allocated
+	Bytes   uint64 // heap bytes allocated
+}
+
+// RowsPerSec is how fast the phase got through its rows
+func (p Phase) RowsPerSec() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Rows) / p.Elapsed.Seconds()
+}
+
+// TimePhase runs one step of the import and measures it. fn returns how many
+// rows it handled. the allocations are for the whole program, so anything
+// else running at the same time ends up in them too.
+func TimePhase(name string, fn func() (int, error)) (Phase, error) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	rows, err := fn()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return Phase{
+		Name:    name,
+		Rows:    rows,
+		Elapsed: elapsed,
+		Allocs:  after.Mallocs - before.Mallocs,
+		Bytes:   after.TotalAlloc - before.TotalAlloc,
+	}, err
+}
+
+// ImportResult is the phases of one write strategy. the workbook is only
+// parsed and cleaned once, so those phases aren't in here.
+type ImportResult struct {
+	Strategy string
+	Phases   []Phase
+	Err      error
+}
+
+// WriteImport prints every phase and then the total for each strategy,
+// counting the parse and clean phases they all share
+func WriteImport(w io.Writer, common []Phase, results []ImportResult) error {
+	out, err := output.New("table", w, output.Options{MaxWidth: output.DefaultMaxWidth})
+	if err != nil {
+		return err
+	}
+	cols := []output.Column{
+		{Name: "strategy"},
+		{Name: "phase"},
+		{Name: "rows", Numeric: true},
+		{Name: "ms", Numeric: true},
+		{Name: "rows/s", Numeric: true},
+		{Name: "allocs", Numeric: true},
+		{Name: "alloc_mb", Numeric: true},
+	}
+	if err := out.Begin(cols); err != nil {
+		return err
+	}
+	row := func(strategy string, p Phase) error {
+		return out.Row([]output.Value{
+			{Kind: output.String, Text: strategy},
+			{Kind: output.String, Text: p.Name},
+			{Kind: output.Number, Text: strconv.Itoa(p.Rows)},
+			{Kind: output.Number, Text: Millis(p.Elapsed)},
+			{Kind: output.Number, Text: strconv.FormatFloat(p.RowsPerSec(), 'f', 0, 64)},
+			{Kind: output.Number, Text: strconv.FormatUint(p.Allocs, 10)},
+			{Kind: output.Number, Text: strconv.FormatFloat(float64(p.Bytes)/(1<<20), 'f', 1, 64)},
+		})
+	}
+	for _, p := range common {
+		if err := row("all", p); err != nil {
+			return err
+		}
+	}
+	for _, r := range results {
+		for _, p := range r.Phases {
+			if err := row(r.Strategy, p); err != nil {
+				return err
+			}
+		}
+	}
+	if err := out.End(); err != nil {
+		return err
+	}
+
+	// the whole import for each strategy, rows/s is for the rows written
+	fmt.Fprintln(w)
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(w, "%s failed: %v\n", r.Strategy, r.Err)
+			continue
+		}
+		total := Phase{}
+		for _, p := range append(append([]Phase{}, common...), r.Phases...) {
+			total.Elapsed += p.Elapsed
+			total.Allocs += p.Allocs
+			total.Bytes += p.Bytes
+			total.Rows = p.Rows
+		}
+		fmt.Fprintf(w, "%s: %d rows in %v, %.0f rows/s, %.1f MB allocated\n",
+			r.Strategy, total.Rows, total.Elapsed.Round(time.Millisecond), total.RowsPerSec(), float64(total.Bytes)/(1<<20))
+	}
+	return nil
+}
+
+// StartProfiles starts a cpu profile when cpu isn't "" and returns a
+// function that stops it and writes a heap profile to heap when that isn't
+// "". the files can be opened with go tool pprof.
+func StartProfiles(cpu, heap string) (stop func() error, err error) {
+	var cpuFile *os.File
+	if cpu != "" {
+		cpuFile, err = os.Create(cpu)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, fmt.Errorf("couldn't start the cpu profile: %v", err)
+		}
+	}
+
+	return func() error {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				return err
+			}
+		}
+		if heap == "" {
+			return nil
+		}
+		f, err := os.Create(heap)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		// up to date numbers for what's still in use
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			return fmt.Errorf("couldn't write the heap profile: %v", err)
+		}
+		return nil
+	}, nil
+}
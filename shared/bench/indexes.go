@@ -0,0 +1,335 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// IndexCandidate is an index on the patrons table that bench indexes builds,
+// runs the suite with and drops again:
+//
+//	indexes:
+//	  - name: library_year
+//	    fields: [home_library_code, active_year]
+//	  - name: patron_type
+//	    mysql: [patron_type_id]
+//	    mongodb: [patron_type_code]
+//
+// fields are used on both backends, mysql and mongodb are for when the
+// column is called something else on one of them. a backend with no fields
+// for the candidate skips it.
+type IndexCandidate struct {
+	Name    string   `yaml:"name"`
+	Fields  []string `yaml:"fields"`
+	MySQL   []string `yaml:"mysql"`
+	MongoDB []string `yaml:"mongodb"`
+}
+
+// names and fields end up in CREATE INDEX as they are, so they're kept to
+// plain identifiers
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (c IndexCandidate) check() error {
+	if !identifier.MatchString(c.Name) {
+		return fmt.Errorf("%q isn't a valid index name, use letters, digits and _", c.Name)
+	}
+	if len(c.Fields) == 0 && len(c.MySQL) == 0 && len(c.MongoDB) == 0 {
+		return fmt.Errorf("%s needs fields, mysql or mongodb", c.Name)
+	}
+	for _, list := range [][]string{c.Fields, c.MySQL, c.MongoDB} {
+		for _, f := range list {
+			if !identifier.MatchString(f) {
+				return fmt.Errorf("%s: %q isn't a valid field name", c.Name, f)
+			}
+		}
+	}
+	return nil
+}
+
+// For is the fields of the index on a backend, "mysql" or "mongodb"
+func (c IndexCandidate) For(backend string) []string {
+	switch {
+	case backend == "mysql" && len(c.MySQL) > 0:
+		return c.MySQL
+	case backend == "mongodb" && len(c.MongoDB) > 0:
+		return c.MongoDB
+	}
+	return c.Fields
+}
+
+// IndexRun is how the suite did with one candidate index built
+type IndexRun struct {
+	Candidate IndexCandidate
+	Fields    []string      // the fields the index was built on
+	Bytes     int64         // size of the index, -1 when the backend couldn't tell
+	Build     time.Duration // how long building it took
+	Results   []Result
+	Err       error // the index couldn't be built
+}
+
+// IndexBackend builds and drops the candidates on one backend. Create
+// builds the index and returns its size, Drop takes it away again so the
+// next candidate is measured on its own.
+type IndexBackend struct {
+	Name   string
+	Create func(c IndexCandidate, fields []string) (int64, error)
+	Drop   func(c IndexCandidate) error
+}
+
+// RunIndexes runs the suite once without any of the candidates and then once
+// per candidate with only that index built on top. it stops when an index
+// can't be dropped, the runs after it wouldn't measure what they say.
+func (s *Suite) RunIndexes(backend IndexBackend, opts Options, flush func() error, prepare Prepare) ([]Result, []IndexRun, error) {
+	base := s.Run(opts, flush, prepare)
+	var runs []IndexRun
+	for _, c := range s.Indexes {
+		fields := c.For(backend.Name)
+		if len(fields) == 0 {
+			continue
+		}
+		run := IndexRun{Candidate: c, Fields: fields}
+		start := time.Now()
+		run.Bytes, run.Err = backend.Create(c, fields)
+		run.Build = time.Since(start)
+		if run.Err == nil {
+			run.Results = s.Run(opts, flush, prepare)
+		}
+		runs = append(runs, run)
+		if err := backend.Drop(c); err != nil {
+			return base, runs, fmt.Errorf("couldn't drop index %s: %v", c.Name, err)
+		}
+	}
+	return base, runs, nil
+}
+
+// Advice is whether a candidate is worth keeping for the suite
+type Advice struct {
+	Index  string
+	Keep   bool
+	Reason string
+}
+
+// medians of the queries that worked, by name
+func medians(results []Result) map[string]time.Duration {
+	m := map[string]time.Duration{}
+	for _, r := range results {
+		if r.Err == nil {
+			m[r.Name] = r.Stats.Median
+		}
+	}
+	return m
+}
+
+// how a candidate did compared to the run without it
+type indexGain struct {
+	run      *IndexRun
+	speedups map[string]float64 // by query, only the ones that worked both times
+	base     time.Duration      // weighted time of the suite without the index
+	with     time.Duration      // and with it
+	helped   []string           // queries at least minSpeedup faster, fastest first
+}
+
+func gains(s *Suite, base []Result, runs []IndexRun, minSpeedup float64) []indexGain {
+	before := medians(base)
+	var out []indexGain
+	for i := range runs {
+		g := indexGain{run: &runs[i], speedups: map[string]float64{}}
+		after := medians(runs[i].Results)
+		for _, q := range s.Queries {
+			b, ok1 := before[q.Name]
+			a, ok2 := after[q.Name]
+			if !ok1 || !ok2 || a <= 0 {
+				continue
+			}
+			g.base += time.Duration(q.weight()) * b
+			g.with += time.Duration(q.weight()) * a
+			g.speedups[q.Name] = float64(b) / float64(a)
+			if g.speedups[q.Name] >= minSpeedup {
+				g.helped = append(g.helped, q.Name)
+			}
+		}
+		sort.SliceStable(g.helped, func(a, b int) bool {
+			return g.speedups[g.helped[a]] > g.speedups[g.helped[b]]
+		})
+		out = append(out, g)
+	}
+	return out
+}
+
+// Advise picks the candidates to keep. an index is kept when it makes at
+// least one query minSpeedup times faster, unless a kept index that starts
+// with the same fields is about as fast on those queries, then the longer one
+// does both jobs. the candidates were measured one at a time, so two kept
+// indexes may help the same queries.
+func Advise(s *Suite, base []Result, runs []IndexRun, minSpeedup float64) []Advice {
+	all := gains(s, base, runs, minSpeedup)
+	var advice []Advice
+	for _, g := range all {
+		a := Advice{Index: g.run.Candidate.Name}
+		switch {
+		case g.run.Err != nil:
+			a.Reason = fmt.Sprintf("couldn't be built: %v", g.run.Err)
+		case len(g.helped) == 0:
+			a.Reason = fmt.Sprintf("no query got %sx faster, it costs %s", strconv.FormatFloat(minSpeedup, 'f', 1, 64), sizeText(g.run.Bytes))
+		default:
+			if other := coveredBy(g, all); other != "" {
+				a.Reason = fmt.Sprintf("%s starts with its fields and is as fast", other)
+				break
+			}
+			var parts []string
+			for _, name := range g.helped {
+				parts = append(parts, fmt.Sprintf("%s %sx", name, strconv.FormatFloat(g.speedups[name], 'f', 1, 64)))
+			}
+			a.Keep = true
+			a.Reason = fmt.Sprintf("speeds up %s for %s", strings.Join(parts, ", "), sizeText(g.run.Bytes))
+		}
+		advice = append(advice, a)
+	}
+
+	// the ones to keep first, the ones that save the most time per byte on top
+	saved := map[string]float64{}
+	for _, g := range all {
+		bytes := max(g.run.Bytes, 1)
+		saved[g.run.Candidate.Name] = float64(g.base-g.with) / float64(bytes)
+	}
+	sort.SliceStable(advice, func(i, j int) bool {
+		if advice[i].Keep != advice[j].Keep {
+			return advice[i].Keep
+		}
+		return advice[i].Keep && saved[advice[i].Index] > saved[advice[j].Index]
+	})
+	return advice
+}
+
+// the name of a longer index that helps g's queries about as much, "" when
+// there isn't one
+func coveredBy(g indexGain, all []indexGain) string {
+	for _, other := range all {
+		if other.run == g.run || len(other.helped) == 0 || !isPrefix(g.run.Fields, other.run.Fields) {
+			continue
+		}
+		covers := true
+		for _, name := range g.helped {
+			// within 10% of the shorter index counts as the same
+			if other.speedups[name]*1.1 < g.speedups[name] {
+				covers = false
+			}
+		}
+		if covers {
+			return other.run.Candidate.Name
+		}
+	}
+	return ""
+}
+
+// whether short is the start of long and shorter than it
+func isPrefix(short, long []string) bool {
+	if len(short) >= len(long) {
+		return false
+	}
+	for i := range short {
+		if short[i] != long[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sizeText(bytes int64) string {
+	if bytes < 0 {
+		return "an unknown amount of space"
+	}
+	return strconv.FormatFloat(float64(bytes)/(1<<20), 'f', 2, 64) + " MB"
+}
+
+// WriteIndexes prints a table of what every candidate costs and saves and
+// then the advice. suite_ms is the medians of the queries added up with
+// their weights, so the queries that run most count the most.
+func WriteIndexes(w io.Writer, s *Suite, base []Result, runs []IndexRun, minSpeedup float64) error {
+	var total time.Duration
+	for _, q := range s.Queries {
+		for _, r := range base {
+			if r.Name == q.Name && r.Err == nil {
+				total += time.Duration(q.weight()) * r.Stats.Median
+			}
+		}
+	}
+	fmt.Fprintf(w, "without the candidates the suite takes %s ms\n", Millis(total))
+	for _, r := range base {
+		if r.Err != nil {
+			fmt.Fprintf(w, "%s: error - %v\n", r.Name, r.Err)
+		}
+	}
+	fmt.Fprintln(w)
+
+	out, err := output.New("table", w, output.Options{MaxWidth: output.DefaultMaxWidth})
+	if err != nil {
+		return err
+	}
+	cols := []output.Column{
+		{Name: "index"}, {Name: "fields"}, {Name: "size_mb", Numeric: true}, {Name: "build_ms", Numeric: true},
+		{Name: "suite_ms", Numeric: true}, {Name: "saved_%", Numeric: true}, {Name: "best_query"}, {Name: "speedup", Numeric: true},
+	}
+	if err := out.Begin(cols); err != nil {
+		return err
+	}
+	null := output.Value{Kind: output.Null}
+	for _, g := range gains(s, base, runs, minSpeedup) {
+		r := g.run
+		row := []output.Value{
+			{Kind: output.String, Text: r.Candidate.Name},
+			{Kind: output.String, Text: strings.Join(r.Fields, ", ")},
+			null, null, null, null, null, null,
+		}
+		if r.Err == nil {
+			if r.Bytes >= 0 {
+				row[2] = output.Value{Kind: output.Number, Text: strconv.FormatFloat(float64(r.Bytes)/(1<<20), 'f', 2, 64)}
+			}
+			row[3] = output.Value{Kind: output.Number, Text: Millis(r.Build)}
+			row[4] = output.Value{Kind: output.Number, Text: Millis(g.with)}
+			if g.base > 0 {
+				row[5] = output.Value{Kind: output.Number, Text: strconv.FormatFloat(100*float64(g.base-g.with)/float64(g.base), 'f', 1, 64)}
+			}
+			best, x := "", 0.0
+			for name, sp := range g.speedups {
+				if sp > x || (sp == x && name < best) {
+					best, x = name, sp
+				}
+			}
+			if x > 1 {
+				row[6] = output.Value{Kind: output.String, Text: best}
+				row[7] = output.Value{Kind: output.Number, Text: strconv.FormatFloat(x, 'f', 2, 64)}
+			}
+		}
+		if err := out.Row(row); err != nil {
+			return err
+		}
+	}
+	if err := out.End(); err != nil {
+		return err
+	}
+	for _, r := range runs {
+		for _, res := range r.Results {
+			if res.Err != nil {
+				fmt.Fprintf(w, "%s with %s: error - %v\n", res.Name, r.Candidate.Name, res.Err)
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "\nadvice:")
+	for _, a := range Advise(s, base, runs, minSpeedup) {
+		verb := "drop"
+		if a.Keep {
+			verb = "keep"
+		}
+		fmt.Fprintf(w, "  %s %s: %s\n", verb, a.Index, a.Reason)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// LoadOptions are how hard and how long to run the load test. it stops
+// after Duration or after Requests queries, whichever is set.
+type LoadOptions struct {
+	Clients  int
+	Duration time.Duration
+	Requests int
+}
+
+// LoadQuery is a query in the mix. Weight is how often it gets picked
+// compared to the others, a query with weight 2 runs twice as often as one
+// with weight 1. Run has to be safe to call from several goroutines.
+type LoadQuery struct {
+	Name   string
+	Weight int
+	Run    func() (int, error)
+}
+
+// LoadResult is how the whole mix and each query did
+type LoadResult struct {
+	Clients  int
+	Elapsed  time.Duration
+	Requests int
+	Errors   int
+	Stats    Stats // latency over every request that worked
+	Queries  []QueryLoad
+}
+
+// QueryLoad is one query's share of a load test
+type QueryLoad struct {
+	Name      string
+	Requests  int
+	Errors    int
+	Stats     Stats
+	LastError error
+}
+
+// Throughput is finished requests per second, failed ones included
+func (r LoadResult) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Elapsed.Seconds()
+}
+
+// ParseMix reads weights like "count by library=3,find SF patrons=1" and
+// sets them on the queries. queries the mix leaves out aren't run.
+func ParseMix(mix string, queries []LoadQuery) error {
+	weights := map[string]int{}
+	for _, part := range strings.Split(mix, ",") {
+		name, weight, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		n, err := strconv.Atoi(strings.TrimSpace(weight))
+		if !ok || err != nil || n < 0 {
+			return fmt.Errorf("mix entries look like NAME=WEIGHT, got %q", part)
+		}
+		weights[name] = n
+	}
+	for name := range weights {
+		found := false
+		for _, q := range queries {
+			found = found || q.Name == name
+		}
+		if !found {
+			return fmt.Errorf("the mix has %q but there's no query by that name to run", name)
+		}
+	}
+	for i := range queries {
+		queries[i].Weight = weights[queries[i].Name]
+	}
+	return nil
+}
+
+// a sample from one request
+type sample struct {
+	query   int
+	elapsed time.Duration
+	err     error
+}
+
+// RunLoad runs the queries from opts.Clients goroutines at once, each picking
+// the next query at random by weight, until the time or the request count
+// runs out
+func RunLoad(queries []LoadQuery, opts LoadOptions) (LoadResult, error) {
+	total := 0
+	for _, q := range queries {
+		total += q.Weight
+	}
+	if total == 0 {
+		return LoadResult{}, fmt.Errorf("every query in the mix has a weight of 0")
+	}
+	if opts.Clients < 1 {
+		return LoadResult{}, fmt.Errorf("there has to be at least 1 client")
+	}
+	if opts.Duration <= 0 && opts.Requests <= 0 {
+		return LoadResult{}, fmt.Errorf("set a duration or a number of requests")
+	}
+
+	var deadline time.Time
+	if opts.Duration > 0 {
+		deadline = time.Now().Add(opts.Duration)
+	}
+	var started atomic.Int64
+	more := func() bool {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return false
+		}
+		return opts.Requests <= 0 || started.Add(1) <= int64(opts.Requests)
+	}
+
+	// each client keeps its own samples so they don't fight over a lock
+	perClient := make([][]sample, opts.Clients)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for c := 0; c < opts.Clients; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewPCG(uint64(start.UnixNano()), uint64(c)))
+			for more() {
+				i := pick(queries, rng.IntN(total))
+				t := time.Now()
+				_, err := queries[i].Run()
+				perClient[c] = append(perClient[c], sample{query: i, elapsed: time.Since(t), err: err})
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	res := LoadResult{Clients: opts.Clients, Elapsed: time.Since(start)}
+	var all []time.Duration
+	byQuery := make([][]time.Duration, len(queries))
+	res.Queries = make([]QueryLoad, len(queries))
+	for i, q := range queries {
+		res.Queries[i].Name = q.Name
+	}
+	for _, samples := range perClient {
+		for _, s := range samples {
+			q := &res.Queries[s.query]
+			q.Requests++
+			res.Requests++
+			if s.err != nil {
+				q.Errors++
+				q.LastError = s.err
+				res.Errors++
+				continue
+			}
+			all = append(all, s.elapsed)
+			byQuery[s.query] = append(byQuery[s.query], s.elapsed)
+		}
+	}
+	res.Stats = Summarize(all)
+	for i := range res.Queries {
+		res.Queries[i].Stats = Summarize(byQuery[i])
+	}
+	sort.SliceStable(res.Queries, func(i, j int) bool { return res.Queries[i].Requests > res.Queries[j].Requests })
+	return res, nil
+}
+
+// the query whose share of the total weight n falls in
+func pick(queries []LoadQuery, n int) int {
+	for i, q := range queries {
+		if n < q.Weight {
+			return i
+		}
+		n -= q.Weight
+	}
+	return len(queries) - 1
+}
+
+// WriteLoad prints the totals and then a row per query with its latencies
+func WriteLoad(w io.Writer, res LoadResult) error {
+	fmt.Fprintf(w, "%d clients for %v: %d requests, %.1f requests/s, %d errors\n",
+		res.Clients, res.Elapsed.Round(time.Millisecond), res.Requests, res.Throughput(), res.Errors)
+	fmt.Fprintf(w, "latency ms: median %s, p95 %s, p99 %s, max %s\n",
+		Millis(res.Stats.Median), Millis(res.Stats.P95), Millis(res.Stats.P99), Millis(res.Stats.Max))
+
+	out, err := output.New("table", w, output.Options{MaxWidth: output.DefaultMaxWidth})
+	if err != nil {
+		return err
+	}
+	cols := []output.Column{{Name: "query"}, {Name: "requests", Numeric: true}, {Name: "errors", Numeric: true}}
+	for _, name := range []string{"median_ms", "p95_ms", "p99_ms", "max_ms"} {
+		cols = append(cols, output.Column{Name: name, Numeric: true})
+	}
+	if err := out.Begin(cols); err != nil {
+		return err
+	}
+	for _, q := range res.Queries {
+		if q.Requests == 0 {
+			continue
+		}
+		row := []output.Value{
+			{Kind: output.String, Text: q.Name},
+			{Kind: output.Number, Text: strconv.Itoa(q.Requests)},
+			{Kind: output.Number, Text: strconv.Itoa(q.Errors)},
+		}
+		for _, d := range []time.Duration{q.Stats.Median, q.Stats.P95, q.Stats.P99, q.Stats.Max} {
+			// no latencies when every request failed
+			if q.Stats.Runs == 0 {
+				row = append(row, output.Value{Kind: output.Null})
+				continue
+			}
+			row = append(row, output.Value{Kind: output.Number, Text: Millis(d)})
+		}
+		if err := out.Row(row); err != nil {
+			return err
+		}
+	}
+	if err := out.End(); err != nil {
+		return err
+	}
+
+	for _, q := range res.Queries {
+		if q.LastError != nil {
+			fmt.Fprintf(w, "%s: %d errors, the last was: %v\n", q.Name, q.Errors, q.LastError)
+		}
+	}
+	return nil
+}
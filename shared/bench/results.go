@@ -0,0 +1,181 @@
+package bench
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Run is everything from one benchmark run, saved so it can be compared
+// with later runs instead of copying numbers out of the terminal
+type Run struct {
+	Backend   string    `json:"backend"`
+	Suite     string    `json:"suite"`
+	Dataset   string    `json:"dataset"` // sha256 of the workbook that was imported
+	Host      Host      `json:"host"`
+	Timestamp time.Time `json:"timestamp"`
+	Options   Options   `json:"options"`
+	Queries   []Query   `json:"queries"`
+	Storage   []Table   `json:"storage,omitempty"`
+}
+
+// Host is the machine and database server the run was on
+type Host struct {
+	Hostname  string `json:"hostname"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	CPUs      int    `json:"cpus"`
+	GoVersion string `json:"go_version"`
+	Server    string `json:"server"` // version of mysql or mongodb
+}
+
+// Query is the result of one query with the timings in milliseconds
+type Query struct {
+	Name     string  `json:"name"`
+	Rows     int     `json:"rows"`
+	Runs     int     `json:"runs"`
+	MinMs    float64 `json:"min_ms"`
+	MedianMs float64 `json:"median_ms"`
+	P95Ms    float64 `json:"p95_ms"`
+	P99Ms    float64 `json:"p99_ms"`
+	MaxMs    float64 `json:"max_ms"`
+	MeanMs   float64 `json:"mean_ms"`
+	StdDevMs float64 `json:"stddev_ms"`
+	Checksum string  `json:"checksum,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// Table is how much space a table or collection takes after the import.
+// Data is what's on disk, which for innodb includes the primary key since
+// the rows are stored in it.
+type Table struct {
+	Name       string  `json:"name"`
+	Rows       int64   `json:"rows"`
+	DataBytes  int64   `json:"data_bytes"`
+	IndexBytes int64   `json:"index_bytes"`
+	Indexes    []Index `json:"indexes,omitempty"`
+}
+
+// Index is the size of one index
+type Index struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+}
+
+// NewRun tags the results with where and when they were taken
+func NewRun(backend, suite, dataset, server string, opts Options, results []Result) *Run {
+	hostname, _ := os.Hostname()
+	run := &Run{
+		Backend: backend,
+		Suite:   suite,
+		Dataset: dataset,
+		Host: Host{
+			Hostname:  hostname,
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+			CPUs:      runtime.NumCPU(),
+			GoVersion: runtime.Version(),
+			Server:    server,
+		},
+		Timestamp: time.Now().UTC(),
+		Options:   opts,
+	}
+	for _, r := range results {
+		q := Query{Name: r.Name, Rows: r.Rows, Runs: r.Stats.Runs, Checksum: r.Checksum}
+		if r.Err != nil {
+			q.Error = r.Err.Error()
+		} else {
+			s := r.Stats
+			q.MinMs, q.MedianMs, q.P95Ms = ms(s.Min), ms(s.Median), ms(s.P95)
+			q.P99Ms, q.MaxMs, q.MeanMs, q.StdDevMs = ms(s.P99), ms(s.Max), ms(s.Mean), ms(s.StdDev)
+		}
+		run.Queries = append(run.Queries, q)
+	}
+	return run
+}
+
+func ms(d time.Duration) float64 {
+	v, _ := strconv.ParseFloat(Millis(d), 64)
+	return v
+}
+
+// Checksum is the sha256 of a file, used to tell whether two runs were on
+// the same data. "" when the file can't be read.
+func Checksum(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Save writes the run to dir as json and csv, named after the backend and
+// time so runs never overwrite each other. it returns the files it wrote.
+func (r *Run) Save(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	base := filepath.Join(dir, fmt.Sprintf("bench-%s-%s", r.Backend, r.Timestamp.Format("20060102-150405")))
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(base+".json", append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(base + ".csv")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := r.WriteCSV(f); err != nil {
+		return nil, err
+	}
+	return []string{base + ".json", base + ".csv"}, nil
+}
+
+// WriteCSV writes one line per query, with the run's tags on every line so
+// runs can be pasted under each other in a spreadsheet
+func (r *Run) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"backend", "suite", "dataset", "hostname", "server", "timestamp", "query", "rows", "runs",
+		"min_ms", "median_ms", "p95_ms", "p99_ms", "max_ms", "mean_ms", "stddev_ms", "error"})
+	for _, q := range r.Queries {
+		row := []string{r.Backend, r.Suite, r.Dataset, r.Host.Hostname, r.Host.Server, r.Timestamp.Format(time.RFC3339),
+			q.Name, strconv.Itoa(q.Rows), strconv.Itoa(q.Runs)}
+		for _, v := range []float64{q.MinMs, q.MedianMs, q.P95Ms, q.P99Ms, q.MaxMs, q.MeanMs, q.StdDevMs} {
+			row = append(row, strconv.FormatFloat(v, 'f', 2, 64))
+		}
+		cw.Write(append(row, q.Error))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Load reads a run saved as json
+func Load(path string) (*Run, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Run
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s isn't a saved benchmark run: %v", path, err)
+	}
+	return &r, nil
+}
@@ -0,0 +1,382 @@
+package bench
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// the side by side report is a few sections, each a table, written as
+// markdown or html
+type section struct {
+	Title   string
+	Notes   []string
+	Columns []output.Column
+	Rows    [][]output.Value
+}
+
+// LatestRuns finds the newest saved run of every backend in a folder
+func LatestRuns(dir string) ([]*Run, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "bench-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	latest := map[string]*Run{}
+	for _, file := range files {
+		run, err := Load(file)
+		if err != nil {
+			return nil, err
+		}
+		if old, ok := latest[run.Backend]; !ok || run.Timestamp.After(old.Timestamp) {
+			latest[run.Backend] = run
+		}
+	}
+
+	var runs []*Run
+	for _, run := range latest {
+		runs = append(runs, run)
+	}
+	// reverse order puts mysql before mongodb, like the title of the report
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Backend > runs[j].Backend })
+	return runs, nil
+}
+
+// what a run is called in the column names, the backend unless two runs
+// are on the same one
+func runLabels(runs []*Run) []string {
+	labels := make([]string, len(runs))
+	seen := map[string]int{}
+	for i, r := range runs {
+		seen[r.Backend]++
+		labels[i] = r.Backend
+		if seen[r.Backend] > 1 {
+			labels[i] = fmt.Sprintf("%s#%d", r.Backend, seen[r.Backend])
+		}
+	}
+	return labels
+}
+
+// the queries of every run in the order they first show up
+func queryNames(runs []*Run) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, r := range runs {
+		for _, q := range r.Queries {
+			if !seen[q.Name] {
+				seen[q.Name] = true
+				names = append(names, q.Name)
+			}
+		}
+	}
+	return names
+}
+
+func findQuery(r *Run, name string) (Query, bool) {
+	for _, q := range r.Queries {
+		if q.Name == name {
+			return q, true
+		}
+	}
+	return Query{}, false
+}
+
+func text(s string) output.Value {
+	return output.Value{Kind: output.String, Text: s}
+}
+
+// a cell with nothing to show. not Null since markdown would print NULL.
+var missing = output.Value{Kind: output.String, Text: "-"}
+
+func megabytes(b int64) output.Value {
+	return output.Value{Kind: output.Number, Text: strconv.FormatFloat(float64(b)/(1<<20), 'f', 2, 64)}
+}
+
+// builds the sections of the report
+func sideBySide(runs []*Run) []section {
+	labels := runLabels(runs)
+	names := queryNames(runs)
+
+	runsSection := section{Title: "Runs", Columns: []output.Column{
+		{Name: "backend"}, {Name: "server"}, {Name: "host"}, {Name: "suite"}, {Name: "when"}, {Name: "runs", Numeric: true},
+	}}
+	for i, r := range runs {
+		runsSection.Rows = append(runsSection.Rows, []output.Value{
+			text(labels[i]), text(r.Host.Server), text(fmt.Sprintf("%s (%s/%s, %d cpus)", r.Host.Hostname, r.Host.OS, r.Host.Arch, r.Host.CPUs)),
+			text(r.Suite), text(r.Timestamp.Format(time.RFC3339)),
+			{Kind: output.Number, Text: strconv.Itoa(r.Options.Runs)},
+		})
+		if r.Dataset != runs[0].Dataset {
+			runsSection.Notes = append(runsSection.Notes, fmt.Sprintf("warning: %s ran on different data than %s", labels[i], labels[0]))
+		}
+		if r.Host.Hostname != runs[0].Host.Hostname {
+			runsSection.Notes = append(runsSection.Notes, fmt.Sprintf("warning: %s ran on a different host than %s", labels[i], labels[0]))
+		}
+	}
+
+	timings := section{
+		Title: "Timings",
+		Notes: []string{"median and p95 in milliseconds. fastest is the lowest median and how many times faster it was than the slowest."},
+	}
+	timings.Columns = append(timings.Columns, output.Column{Name: "query"})
+	for _, l := range labels {
+		timings.Columns = append(timings.Columns,
+			output.Column{Name: l + " median", Numeric: true}, output.Column{Name: l + " p95", Numeric: true})
+	}
+	timings.Columns = append(timings.Columns, output.Column{Name: "fastest"})
+	for _, name := range names {
+		row := []output.Value{text(name)}
+		fastest, slowest := -1, -1
+		for i, r := range runs {
+			q, ok := findQuery(r, name)
+			if !ok || q.Error != "" {
+				row = append(row, missing, missing)
+				continue
+			}
+			row = append(row, number(q.MedianMs, true), number(q.P95Ms, true))
+			if m := q.MedianMs; fastest < 0 || m < medianOf(runs[fastest], name) {
+				fastest = i
+			}
+			if m := q.MedianMs; slowest < 0 || m > medianOf(runs[slowest], name) {
+				slowest = i
+			}
+		}
+		switch {
+		case fastest < 0 || fastest == slowest:
+			row = append(row, missing)
+		case medianOf(runs[fastest], name) > 0:
+			times := medianOf(runs[slowest], name) / medianOf(runs[fastest], name)
+			row = append(row, text(fmt.Sprintf("%s, %.1fx", labels[fastest], times)))
+		default:
+			row = append(row, text(labels[fastest]))
+		}
+		timings.Rows = append(timings.Rows, row)
+	}
+
+	parity := section{
+		Title: "Results",
+		Notes: []string{"whether the backends returned the same rows, compared after sorting them and rounding numbers to 2 decimals."},
+	}
+	parity.Columns = append(parity.Columns, output.Column{Name: "query"})
+	for _, l := range labels {
+		parity.Columns = append(parity.Columns, output.Column{Name: l + " rows", Numeric: true})
+	}
+	parity.Columns = append(parity.Columns, output.Column{Name: "result"})
+	different := 0
+	for _, name := range names {
+		row := []output.Value{text(name)}
+		var sums []string
+		for _, r := range runs {
+			q, ok := findQuery(r, name)
+			if !ok || q.Error != "" {
+				row = append(row, missing)
+				sums = append(sums, "")
+				continue
+			}
+			row = append(row, output.Value{Kind: output.Number, Text: strconv.Itoa(q.Rows)})
+			sums = append(sums, q.Checksum)
+		}
+		result := "same"
+		for _, sum := range sums {
+			switch {
+			case sum == "":
+				result = "not checked"
+			case sum != sums[0] && result != "not checked":
+				result = "different"
+			}
+		}
+		if result == "different" {
+			different++
+		}
+		parity.Rows = append(parity.Rows, append(row, text(result)))
+	}
+	if different > 0 {
+		parity.Notes = append(parity.Notes, fmt.Sprintf("warning: %d queries returned different results, their timings aren't comparable", different))
+	}
+
+	storage := section{
+		Title: "Storage",
+		Notes: []string{"sizes in MB on disk. innodb keeps the rows in the primary key, so mysql's data includes it and its index size doesn't."},
+		Columns: []output.Column{
+			{Name: "backend"}, {Name: "table"}, {Name: "rows", Numeric: true},
+			{Name: "data_mb", Numeric: true}, {Name: "index_mb", Numeric: true}, {Name: "total_mb", Numeric: true},
+		},
+	}
+	indexes := section{
+		Title:   "Indexes",
+		Columns: []output.Column{{Name: "backend"}, {Name: "table"}, {Name: "index"}, {Name: "size_mb", Numeric: true}},
+	}
+	for i, r := range runs {
+		if len(r.Storage) == 0 {
+			storage.Notes = append(storage.Notes, fmt.Sprintf("%s has no storage sizes saved", labels[i]))
+		}
+		var data, index int64
+		for _, t := range r.Storage {
+			storage.Rows = append(storage.Rows, []output.Value{
+				text(labels[i]), text(t.Name), {Kind: output.Number, Text: strconv.FormatInt(t.Rows, 10)},
+				megabytes(t.DataBytes), megabytes(t.IndexBytes), megabytes(t.DataBytes + t.IndexBytes),
+			})
+			data += t.DataBytes
+			index += t.IndexBytes
+			for _, ix := range t.Indexes {
+				indexes.Rows = append(indexes.Rows, []output.Value{text(labels[i]), text(t.Name), text(ix.Name), megabytes(ix.Bytes)})
+			}
+		}
+		if len(r.Storage) > 0 {
+			storage.Rows = append(storage.Rows, []output.Value{
+				text(labels[i]), text("total"), missing, megabytes(data), megabytes(index), megabytes(data + index),
+			})
+		}
+	}
+
+	return []section{runsSection, timings, parity, storage, indexes}
+}
+
+func medianOf(r *Run, name string) float64 {
+	q, _ := findQuery(r, name)
+	return q.MedianMs
+}
+
+// WriteSideBySide writes the runs next to each other as "markdown" or "html"
+func WriteSideBySide(w io.Writer, format string, runs []*Run) error {
+	sections := sideBySide(runs)
+	switch format {
+	case "markdown":
+		return writeMarkdown(w, sections)
+	case "html":
+		return writeHTML(w, sections)
+	}
+	return fmt.Errorf("unknown format %q (use markdown or html)", format)
+}
+
+func writeMarkdown(w io.Writer, sections []section) error {
+	fmt.Fprintln(w, "# MySQL vs MongoDB")
+	for _, s := range sections {
+		fmt.Fprintf(w, "\n## %s\n\n", s.Title)
+		for _, note := range s.Notes {
+			fmt.Fprintf(w, "%s\n\n", note)
+		}
+		if len(s.Rows) == 0 {
+			continue
+		}
+		out, err := output.New("markdown", w, output.Options{})
+		if err != nil {
+			return err
+		}
+		if err := out.Begin(s.Columns); err != nil {
+			return err
+		}
+		for _, row := range s.Rows {
+			if err := out.Row(row); err != nil {
+				return err
+			}
+		}
+		if err := out.End(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// one page with the styles inline so it can be opened or mailed on its own
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"numeric": func(v output.Value) bool { return v.Kind == output.Number },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>MySQL vs MongoDB</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f3f3f3; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+p.note { color: #555; }
+</style>
+</head>
+<body>
+<h1>MySQL vs MongoDB</h1>
+{{range .}}
+<h2>{{.Title}}</h2>
+{{range .Notes}}<p class="note">{{.}}</p>
+{{end}}{{if .Rows}}{{$cols := .Columns}}<table>
+<tr>{{range $cols}}<th>{{.Name}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}{{if numeric .}}<td class="num">{{.Text}}</td>{{else}}<td>{{.Text}}</td>{{end}}{{end}}</tr>
+{{end}}</table>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+func writeHTML(w io.Writer, sections []section) error {
+	return htmlReport.Execute(w, sections)
+}
+
+// ReportCommand is bench report, the same in both apps. it takes saved runs,
+// or folders to use the newest run of every backend in, and writes them side
+// by side.
+//
+//	go run . bench report -out ../results/comparison.html ../results
+func ReportCommand(args []string) error {
+	flags := flag.NewFlagSet("bench report", flag.ExitOnError)
+	format := flags.String("format", "", "markdown or html, taken from -out's extension when it's left out")
+	out := flags.String("out", "", "file to write the report to instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . bench report [flags] RUN.json|FOLDER ...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *format == "" {
+		*format = "markdown"
+		if ext := filepath.Ext(*out); ext == ".html" || ext == ".htm" {
+			*format = "html"
+		}
+	}
+
+	var runs []*Run
+	for _, arg := range flags.Args() {
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			latest, err := LatestRuns(arg)
+			if err != nil {
+				return err
+			}
+			runs = append(runs, latest...)
+			continue
+		}
+		run, err := Load(arg)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, run)
+	}
+	if len(runs) < 2 {
+		return fmt.Errorf("the report needs at least 2 runs, found %d", len(runs))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSideBySide(&buf, *format, runs); err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Println("report written to", *out)
+	return nil
+}
@@ -0,0 +1,162 @@
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"gopkg.in/yaml.v3"
+)
+
+// Suite is a set of benchmark queries loaded from a yaml file:
+//
+//	name: default
+//	queries:
+//	  - name: count by age range
+//	    rows: 11
+//	    mysql: SELECT age_range, COUNT(*) FROM patrons GROUP BY age_range
+//	    mongodb: 'db.patrons.aggregate([{$group: {_id: "$age_range", count: {$sum: 1}}}])'
+//	  - name: sf patrons by library
+//	    query: patrons where within_sfc group by home_library count
+//
+// mysql is sql and mongodb is a query in mongo shell syntax. query is the
+// patron query language, which runs on both and is used when a backend
+// doesn't have its own. rows is how many rows the query should return,
+// leave it out to not check. weight is how often bench load picks the
+// query compared to the others, 1 when it's left out and 0 to leave the
+// query out of the load test.
+//
+// indexes are the candidates bench indexes tries on the patrons table one
+// at a time, see IndexCandidate.
+type Suite struct {
+	Name    string           `yaml:"name"`
+	Queries []SuiteQuery     `yaml:"queries"`
+	Indexes []IndexCandidate `yaml:"indexes"`
+}
+
+// SuiteQuery is one query of a suite
+type SuiteQuery struct {
+	Name    string `yaml:"name"`
+	Query   string `yaml:"query"`
+	MySQL   string `yaml:"mysql"`
+	MongoDB string `yaml:"mongodb"`
+	Rows    *int   `yaml:"rows"`
+	Weight  *int   `yaml:"weight"`
+}
+
+// LoadSuite reads a suite and checks every query has a name and something to run
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Suite
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(s.Queries) == 0 {
+		return nil, fmt.Errorf("%s: the suite has no queries", path)
+	}
+
+	seen := map[string]bool{}
+	for i, q := range s.Queries {
+		if q.Name == "" {
+			return nil, fmt.Errorf("%s: query %d has no name", path, i+1)
+		}
+		if seen[q.Name] {
+			return nil, fmt.Errorf("%s: there are two queries called %q", path, q.Name)
+		}
+		seen[q.Name] = true
+		if q.Query == "" && q.MySQL == "" && q.MongoDB == "" {
+			return nil, fmt.Errorf("%s: %s needs a query, mysql or mongodb", path, q.Name)
+		}
+		if q.Query != "" {
+			if _, err := dsl.Parse(q.Query); err != nil {
+				return nil, fmt.Errorf("%s: %s: %v", path, q.Name, err)
+			}
+		}
+		if q.Rows != nil && *q.Rows < 0 {
+			return nil, fmt.Errorf("%s: %s: rows can't be negative", path, q.Name)
+		}
+		if q.Weight != nil && *q.Weight < 0 {
+			return nil, fmt.Errorf("%s: %s: weight can't be negative", path, q.Name)
+		}
+	}
+
+	seen = map[string]bool{}
+	for i, c := range s.Indexes {
+		if err := c.check(); err != nil {
+			return nil, fmt.Errorf("%s: index %d: %v", path, i+1, err)
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("%s: there are two indexes called %q", path, c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return &s, nil
+}
+
+// how often the query runs compared to the others, 1 when the suite doesn't say
+func (q SuiteQuery) weight() int {
+	if q.Weight != nil {
+		return *q.Weight
+	}
+	return 1
+}
+
+// Prepare turns a suite query into something the backend can run. it
+// returns nil when the query has nothing for this backend.
+type Prepare func(q SuiteQuery) (func() (int, error), error)
+
+// Run measures every query in the suite that the backend can run. queries
+// that return a different number of rows than the suite expects fail.
+func (s *Suite) Run(opts Options, flush func() error, prepare Prepare) []Result {
+	var results []Result
+	for _, q := range s.Queries {
+		run, err := prepare(q)
+		if err != nil {
+			results = append(results, Result{Name: q.Name, Err: err})
+			continue
+		}
+		if run == nil {
+			continue
+		}
+		res := Measure(Case{Name: q.Name, Run: run}, opts, flush)
+		if res.Err == nil && q.Rows != nil && res.Rows != *q.Rows {
+			res.Err = fmt.Errorf("expected %d rows, got %d", *q.Rows, res.Rows)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// LoadQueries prepares the suite's queries for a load test with the weights
+// from the suite. queries the backend can't run are left out.
+func (s *Suite) LoadQueries(prepare Prepare) ([]LoadQuery, error) {
+	var queries []LoadQuery
+	for _, q := range s.Queries {
+		run, err := prepare(q)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", q.Name, err)
+		}
+		if run == nil {
+			continue
+		}
+		queries = append(queries, LoadQuery{Name: q.Name, Weight: q.weight(), Run: run})
+	}
+	return queries, nil
+}
+
+// Failed counts the results that have an error
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Err != nil {
+			n++
+		}
+	}
+	return n
+}
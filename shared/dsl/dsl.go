@@ -0,0 +1,571 @@
+// Package dsl is a small query language for the patron data that compiles to
+// sql for the mysql app and to an aggregation pipeline for the mongodb app,
+// so the same question can be asked of either one:
+//
+//	patrons where within_sfc and active_year = 2023 group by home_library count
+//	patrons where age_range = '25 to 34 years' group by patron_type count, avg checkout_total order by count desc
+//	patrons where checkout_total >= 1000 show home_library, checkout_total order by checkout_total desc limit 10
+//
+// a query starts with patrons and then has any of these, in any order:
+//
+//	where CONDITION              comparisons joined with and, or, not and brackets
+//	group by FIELD, ...          one row per group
+//	count, sum F, avg F, ...     what to work out for each group, or for everything without group by
+//	show FIELD, ...              list patrons with these fields instead of grouping
+//	order by NAME [asc|desc]     sort by a field, a group or an aggregate like count or avg_checkout_total
+//	limit N                      at most N rows
+//
+// conditions are FIELD = VALUE (also !=, <, <=, >, >=), FIELD in (V, ...),
+// FIELD like 'pattern%', FIELD is [not] null, or a true/false field on its own.
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultLimit is how many patrons a listing returns when it has no limit
+const DefaultLimit = 100
+
+// Query is a parsed query that's been checked against the fields
+type Query struct {
+	Where      Expr
+	GroupBy    []*Field
+	Aggregates []Aggregate
+	Show       []*Field
+	OrderBy    []Order
+	Limit      int
+}
+
+// Aggregate is count, or sum/avg/min/max of a field
+type Aggregate struct {
+	Func  string
+	Field *Field // nil for count
+}
+
+// Name is the column the aggregate comes out as, like count or avg_checkout_total
+func (a Aggregate) Name() string {
+	if a.Field == nil {
+		return a.Func
+	}
+	return a.Func + "_" + a.Field.Name
+}
+
+// Order is one sort key. Name is a column of the result.
+type Order struct {
+	Name string
+	Desc bool
+}
+
+// Expr is a condition in the where clause
+type Expr interface {
+	expr()
+}
+
+// And and Or join two conditions
+type And struct{ Left, Right Expr }
+type Or struct{ Left, Right Expr }
+
+// Not flips a condition
+type Not struct{ X Expr }
+
+// Compare is FIELD OP VALUE, or FIELD in (VALUES) where Op is "in".
+// the values have already been converted to the field's type.
+type Compare struct {
+	Field  *Field
+	Op     string
+	Values []interface{}
+}
+
+// IsNull is FIELD is null, or FIELD is not null when Not is set
+type IsNull struct {
+	Field *Field
+	Not   bool
+}
+
+func (*And) expr()     {}
+func (*Or) expr()      {}
+func (*Not) expr()     {}
+func (*Compare) expr() {}
+func (*IsNull) expr()  {}
+
+// IsQuery reports whether text looks like a query in this language rather
+// than sql or a mongo query, so the text interfaces can tell them apart
+func IsQuery(text string) bool {
+	fields := strings.Fields(text)
+	return len(fields) > 0 && strings.EqualFold(fields[0], "patrons") && !strings.Contains(text, "|")
+}
+
+// Columns are the names of the result columns in order
+func (q *Query) Columns() []string {
+	var cols []string
+	if q.Show != nil {
+		for _, f := range q.Show {
+			cols = append(cols, f.Name)
+		}
+		return cols
+	}
+	for _, f := range q.GroupBy {
+		cols = append(cols, f.Name)
+	}
+	for _, a := range q.Aggregates {
+		cols = append(cols, a.Name())
+	}
+	return cols
+}
+
+// Parse reads a query and checks its fields and values
+func Parse(text string) (*Query, error) {
+	tokens, err := lex(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+
+	if !p.keyword("patrons") {
+		return nil, fmt.Errorf("queries start with patrons, like: patrons where within_sfc group by home_library count")
+	}
+
+	q := &Query{Limit: -1}
+	seen := map[string]bool{}
+	for !p.done() {
+		t := p.peek()
+		clause := strings.ToLower(t.text)
+		if aggregateFuncs[clause] {
+			clause = "aggregate"
+		}
+		if t.kind != tokWord {
+			return nil, fmt.Errorf("expected where, group by, show, order by, limit or an aggregate, got %q", t.text)
+		}
+		if seen[clause] {
+			return nil, fmt.Errorf("%s is in the query twice", clause)
+		}
+		seen[clause] = true
+
+		switch clause {
+		case "where":
+			p.next()
+			if q.Where, err = p.parseOr(); err != nil {
+				return nil, err
+			}
+		case "group":
+			p.next()
+			if !p.keyword("by") {
+				return nil, fmt.Errorf("expected by after group")
+			}
+			if q.GroupBy, err = p.parseFields(); err != nil {
+				return nil, err
+			}
+		case "show":
+			p.next()
+			if q.Show, err = p.parseFields(); err != nil {
+				return nil, err
+			}
+		case "aggregate":
+			if q.Aggregates, err = p.parseAggregates(); err != nil {
+				return nil, err
+			}
+		case "order":
+			p.next()
+			if !p.keyword("by") {
+				return nil, fmt.Errorf("expected by after order")
+			}
+			if q.OrderBy, err = p.parseOrder(); err != nil {
+				return nil, err
+			}
+		case "limit":
+			p.next()
+			t := p.next()
+			n, err := strconv.Atoi(t.text)
+			if t.kind != tokNumber || err != nil || n < 0 {
+				return nil, fmt.Errorf("limit takes a whole number, got %q", t.text)
+			}
+			q.Limit = n
+		default:
+			return nil, fmt.Errorf("expected where, group by, show, order by, limit or an aggregate, got %q", t.text)
+		}
+	}
+
+	if err := q.check(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// makes sure the clauses fit together and fills in the defaults
+func (q *Query) check() error {
+	grouped := q.GroupBy != nil || q.Aggregates != nil
+	if grouped && q.Show != nil {
+		return fmt.Errorf("show lists patrons, so it can't go with group by or aggregates")
+	}
+	if q.GroupBy != nil && q.Aggregates == nil {
+		// grouping on its own is most likely asking how many are in each group
+		q.Aggregates = []Aggregate{{Func: "count"}}
+	}
+	if !grouped && q.Show == nil {
+		q.Show = Fields
+	}
+	if !grouped && q.Limit < 0 {
+		q.Limit = DefaultLimit
+	}
+
+	columns := map[string]bool{}
+	for _, c := range q.Columns() {
+		if columns[c] {
+			return fmt.Errorf("%s is in the result twice", c)
+		}
+		columns[c] = true
+	}
+
+	for i, o := range q.OrderBy {
+		if columns[o.Name] {
+			continue
+		}
+		// ordering by checkouts when checkout_total is shown
+		if f, err := LookupField(o.Name); err == nil && columns[f.Name] {
+			q.OrderBy[i].Name = f.Name
+			continue
+		}
+		return fmt.Errorf("can only order by a column of the result (%s), not %s", strings.Join(q.Columns(), ", "), o.Name)
+	}
+	if q.OrderBy == nil {
+		// group keys in order so the result is the same every time
+		for _, f := range q.GroupBy {
+			q.OrderBy = append(q.OrderBy, Order{Name: f.Name})
+		}
+	}
+	return nil
+}
+
+// what can go in front of a field to aggregate it
+var aggregateFuncs = map[string]bool{"count": true, "sum": true, "avg": true, "min": true, "max": true}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: tokEnd, text: "the end"}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if !p.done() {
+		p.pos++
+	}
+	return t
+}
+
+// takes the next token if it's the keyword
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokWord && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) symbol(s string) bool {
+	t := p.peek()
+	if t.kind == tokSymbol && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.keyword("not") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{x}, nil
+	}
+	if p.symbol("(") {
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.symbol(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		return x, nil
+	}
+	return p.parseCondition()
+}
+
+// a single comparison, or a true/false field on its own
+func (p *parser) parseCondition() (Expr, error) {
+	t := p.next()
+	if t.kind != tokWord {
+		return nil, fmt.Errorf("expected a field, got %q", t.text)
+	}
+	f, err := LookupField(t.text)
+	if err != nil {
+		return nil, err
+	}
+
+	op := p.peek()
+	switch {
+	case op.kind == tokSymbol && isComparison(op.text):
+		p.next()
+		v, err := p.parseValue(f)
+		if err != nil {
+			return nil, err
+		}
+		name := op.text
+		if name == "<>" {
+			name = "!="
+		}
+		return &Compare{Field: f, Op: name, Values: []interface{}{v}}, nil
+	case p.keyword("in"):
+		if !p.symbol("(") {
+			return nil, fmt.Errorf("in takes a list like (1, 2, 3)")
+		}
+		c := &Compare{Field: f, Op: "in"}
+		for {
+			v, err := p.parseValue(f)
+			if err != nil {
+				return nil, err
+			}
+			c.Values = append(c.Values, v)
+			if p.symbol(")") {
+				return c, nil
+			}
+			if !p.symbol(",") {
+				return nil, fmt.Errorf("expected , or ) in the list after in")
+			}
+		}
+	case p.keyword("like"):
+		if f.Type != String {
+			return nil, fmt.Errorf("like only works on text fields, %s is %s", f.Name, f.Type)
+		}
+		v, err := p.parseValue(f)
+		if err != nil {
+			return nil, err
+		}
+		return &Compare{Field: f, Op: "like", Values: []interface{}{v}}, nil
+	case p.keyword("is"):
+		not := p.keyword("not")
+		if !p.keyword("null") {
+			return nil, fmt.Errorf("expected null after is")
+		}
+		return &IsNull{Field: f, Not: not}, nil
+	}
+
+	if f.Type == Bool {
+		return &Compare{Field: f, Op: "=", Values: []interface{}{true}}, nil
+	}
+	return nil, fmt.Errorf("expected a comparison after %s, like %s = ...", f.Name, f.Name)
+}
+
+func isComparison(s string) bool {
+	switch s {
+	case "=", "!=", "<>", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+// reads a value and converts it to the field's type, so active_year = '2023'
+// and active_year = 2023 mean the same thing
+func (p *parser) parseValue(f *Field) (interface{}, error) {
+	t := p.next()
+	if t.kind != tokNumber && t.kind != tokString && t.kind != tokWord {
+		return nil, fmt.Errorf("expected a value for %s, got %q", f.Name, t.text)
+	}
+	if t.kind == tokWord && f.Type == String {
+		return nil, fmt.Errorf("text values go in quotes, like %s = '%s'", f.Name, t.text)
+	}
+
+	switch f.Type {
+	case Int:
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is a number, got %q", f.Name, t.text)
+		}
+		return n, nil
+	case Bool:
+		switch strings.ToLower(t.text) {
+		case "true", "1", "yes":
+			return true, nil
+		case "false", "0", "no":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%s is true or false, got %q", f.Name, t.text)
+	}
+	return t.text, nil
+}
+
+func (p *parser) parseFields() ([]*Field, error) {
+	var fields []*Field
+	for {
+		t := p.next()
+		if t.kind != tokWord {
+			return nil, fmt.Errorf("expected a field, got %q", t.text)
+		}
+		f, err := LookupField(t.text)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+		if !p.symbol(",") {
+			return fields, nil
+		}
+	}
+}
+
+func (p *parser) parseAggregates() ([]Aggregate, error) {
+	var aggs []Aggregate
+	for {
+		t := p.next()
+		fn := strings.ToLower(t.text)
+		if t.kind != tokWord || !aggregateFuncs[fn] {
+			return nil, fmt.Errorf("expected count, sum, avg, min or max, got %q", t.text)
+		}
+		a := Aggregate{Func: fn}
+		if fn != "count" {
+			ft := p.next()
+			f, err := LookupField(ft.text)
+			if ft.kind != tokWord || err != nil {
+				return nil, fmt.Errorf("%s needs a field, like %s checkout_total", fn, fn)
+			}
+			if f.Type != Int && (fn == "sum" || fn == "avg") {
+				return nil, fmt.Errorf("%s needs a number field, %s is %s", fn, f.Name, f.Type)
+			}
+			a.Field = f
+		}
+		aggs = append(aggs, a)
+		if !p.symbol(",") {
+			return aggs, nil
+		}
+	}
+}
+
+func (p *parser) parseOrder() ([]Order, error) {
+	var order []Order
+	for {
+		t := p.next()
+		if t.kind != tokWord {
+			return nil, fmt.Errorf("expected something to order by, got %q", t.text)
+		}
+		o := Order{Name: strings.ToLower(t.text)}
+		if p.keyword("desc") {
+			o.Desc = true
+		} else {
+			p.keyword("asc")
+		}
+		order = append(order, o)
+		if !p.symbol(",") {
+			return order, nil
+		}
+	}
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokNumber
+	tokString
+	tokSymbol
+	tokEnd
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// splits a query into words, numbers, quoted strings and symbols
+func lex(text string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			var s strings.Builder
+			j := i + 1
+			for ; j < len(text) && text[j] != c; j++ {
+				if text[j] == '\\' && j+1 < len(text) {
+					j++
+				}
+				s.WriteByte(text[j])
+			}
+			if j >= len(text) {
+				return nil, fmt.Errorf("missing closing %c", c)
+			}
+			tokens = append(tokens, token{tokString, s.String()})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(text) && text[i+1] >= '0' && text[i+1] <= '9':
+			j := i + 1
+			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+				j++
+			}
+			tokens = append(tokens, token{tokNumber, text[i:j]})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(text) && (text[j] == '_' || text[j] >= 'a' && text[j] <= 'z' || text[j] >= 'A' && text[j] <= 'Z' || text[j] >= '0' && text[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, token{tokWord, text[i:j]})
+			i = j
+		default:
+			sym := ""
+			for _, candidate := range []string{"<=", ">=", "!=", "<>", "=", "<", ">", "(", ")", ","} {
+				if strings.HasPrefix(text[i:], candidate) {
+					sym = candidate
+					break
+				}
+			}
+			if sym == "" {
+				return nil, fmt.Errorf("unexpected %q", string(c))
+			}
+			tokens = append(tokens, token{tokSymbol, sym})
+			i += len(sym)
+		}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,233 @@
+package dsl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const countStages = `{"$facet":{"rows":[{"$group":{"_id":null,"count":{"$sum":1}}},{"$project":{"_id":0,"count":1}}]}},{"$replaceRoot":{"newRoot":{"$ifNull":[{"$arrayElemAt":["$rows",0]},{"$literal":{"count":0}}]}}}]`
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		sql      string
+		args     []interface{}
+		pipeline string
+	}{
+		{
+			name:     "and binds tighter than or",
+			query:    "patrons where sf or active_year = 2023 and checkouts > 10 count",
+			sql:      "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE (p.within_sfc = ? OR (p.active_year = ? AND p.checkout_total > ?))",
+			args:     []interface{}{true, int64(2023), int64(10)},
+			pipeline: `[{"$match":{"$or":[{"within_sfc":true},{"$and":[{"active_year":"2023"},{"$and":[{"checkout_total":{"$ne":null}},{"$expr":{"$gt":[{"$convert":{"input":"$checkout_total","to":"long","onError":null,"onNull":null}},10]}}]}]}]}},` + countStages,
+		},
+		{
+			name:     "brackets",
+			query:    "patrons where (sf or active_year = 2023) and checkouts > 10 count",
+			sql:      "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE ((p.within_sfc = ? OR p.active_year = ?) AND p.checkout_total > ?)",
+			args:     []interface{}{true, int64(2023), int64(10)},
+			pipeline: `[{"$match":{"$and":[{"$or":[{"within_sfc":true},{"active_year":"2023"}]},{"$and":[{"checkout_total":{"$ne":null}},{"$expr":{"$gt":[{"$convert":{"input":"$checkout_total","to":"long","onError":null,"onNull":null}},10]}}]}]}},` + countStages,
+		},
+		{
+			name:     "not leaves out nulls like sql",
+			query:    "patrons where not active_year = 2023 count",
+			sql:      "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE NOT p.active_year = ?",
+			args:     []interface{}{int64(2023)},
+			pipeline: `[{"$match":{"$and":[{"$and":[{"active_year":{"$ne":null}},{"$expr":{"$ne":[{"$convert":{"input":"$active_year","to":"long","onError":null,"onNull":null}},null]}}]},{"$nor":[{"active_year":"2023"}]}]}},` + countStages,
+		},
+		{
+			name:     "not of and",
+			query:    "patrons where not (active_year = 2023 and sf) count",
+			sql:      "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE NOT (p.active_year = ? AND p.within_sfc = ?)",
+			args:     []interface{}{int64(2023), true},
+			pipeline: `[{"$match":{"$or":[{"$and":[{"$and":[{"active_year":{"$ne":null}},{"$expr":{"$ne":[{"$convert":{"input":"$active_year","to":"long","onError":null,"onNull":null}},null]}}]},{"$nor":[{"active_year":"2023"}]}]},{"$and":[{"within_sfc":{"$ne":null}},{"$nor":[{"within_sfc":true}]}]}]}},` + countStages,
+		},
+		{
+			name:     "not of or",
+			query:    "patrons where not (active_year = 2023 or email is null) count",
+			sql:      "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE NOT (p.active_year = ? OR p.email IS NULL)",
+			args:     []interface{}{int64(2023)},
+			pipeline: `[{"$match":{"$and":[{"$and":[{"$and":[{"active_year":{"$ne":null}},{"$expr":{"$ne":[{"$convert":{"input":"$active_year","to":"long","onError":null,"onNull":null}},null]}}]},{"$nor":[{"active_year":"2023"}]}]},{"email":{"$ne":null}}]}},` + countStages,
+		},
+		{
+			name:     "not not",
+			query:    "patrons where not not sf count",
+			sql:      "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE NOT NOT p.within_sfc = ?",
+			args:     []interface{}{true},
+			pipeline: `[{"$match":{"within_sfc":true}},` + countStages,
+		},
+		{
+			name:     "not is null",
+			query:    "patrons where not email is null count",
+			sql:      "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE NOT p.email IS NULL",
+			pipeline: `[{"$match":{"email":{"$ne":null}}},` + countStages,
+		},
+		{
+			name:     "less than leaves out values that aren't numbers",
+			query:    "patrons where checkouts < 5 count",
+			sql:      "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE p.checkout_total < ?",
+			args:     []interface{}{int64(5)},
+			pipeline: `[{"$match":{"$and":[{"checkout_total":{"$ne":null}},{"$expr":{"$and":[{"$ne":[{"$convert":{"input":"$checkout_total","to":"long","onError":null,"onNull":null}},null]},{"$lt":[{"$convert":{"input":"$checkout_total","to":"long","onError":null,"onNull":null}},5]}]}}]}},` + countStages,
+		},
+		{
+			name:     "not of a range",
+			query:    "patrons where not renewals <= 5 count",
+			sql:      "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE NOT p.renewal_total <= ?",
+			args:     []interface{}{int64(5)},
+			pipeline: `[{"$match":{"$and":[{"$and":[{"renewal_total":{"$ne":null}},{"$expr":{"$ne":[{"$convert":{"input":"$renewal_total","to":"long","onError":null,"onNull":null}},null]}}]},{"$nor":[{"$and":[{"renewal_total":{"$ne":null}},{"$expr":{"$and":[{"$ne":[{"$convert":{"input":"$renewal_total","to":"long","onError":null,"onNull":null}},null]},{"$lte":[{"$convert":{"input":"$renewal_total","to":"long","onError":null,"onNull":null}},5]}]}}]}]}]}},` + countStages,
+		},
+		{
+			name:     "is null and is not null",
+			query:    "patrons where email is null and active_month is not null count",
+			sql:      "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE (p.email IS NULL AND p.active_month IS NOT NULL)",
+			pipeline: `[{"$match":{"$and":[{"email":null},{"active_month":{"$ne":null}}]}},` + countStages,
+		},
+		{
+			name:     "!= never matches null",
+			query:    "patrons where active_month <> 3 count",
+			sql:      "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE p.active_month != ?",
+			args:     []interface{}{int64(3)},
+			pipeline: `[{"$match":{"active_month":{"$nin":[3,null]}}},` + countStages,
+		},
+		{
+			name:     "in with a group",
+			query:    "patrons where library_code in ('X', 'M2') group by library",
+			sql:      "SELECT l.name AS `home_library`, COUNT(*) AS `count`\nFROM patrons p\nJOIN libraries l ON p.home_library_code = l.code\nWHERE p.home_library_code IN (?, ?)\nGROUP BY l.name\nORDER BY `home_library` ASC",
+			args:     []interface{}{"X", "M2"},
+			pipeline: `[{"$match":{"home_library_code":{"$in":["X","M2"]}}},{"$group":{"_id":{"home_library":"$home_library_name"},"count":{"$sum":1}}},{"$project":{"_id":0,"home_library":"$_id.home_library","count":1}},{"$sort":{"home_library":1}}]`,
+		},
+		{
+			name:     "in on a number stored as a string",
+			query:    "patrons where registered in (2019, 2020) count",
+			sql:      "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE p.year_registered IN (?, ?)",
+			args:     []interface{}{int64(2019), int64(2020)},
+			pipeline: `[{"$match":{"year_registered":{"$in":["2019","2020"]}}},` + countStages,
+		},
+		{
+			name:     "like",
+			query:    "patrons where email like '%@gmail.com' show email limit 5",
+			sql:      "SELECT p.email AS `email`\nFROM patrons p\nWHERE p.email LIKE ?\nLIMIT 5",
+			args:     []interface{}{"%@gmail.com"},
+			pipeline: `[{"$match":{"email":{"$regex":"^.*@gmail\\.com$","$options":"i"}}},{"$project":{"_id":0,"email":"$email"}},{"$limit":5}]`,
+		},
+		{
+			name:     "aliases and order by an aggregate",
+			query:    "patrons where age = '25 to 34 years' group by type avg checkouts order by avg_checkout_total desc limit 3",
+			sql:      "SELECT pt.description AS `patron_type`, ROUND(AVG(p.checkout_total), 2) AS `avg_checkout_total`\nFROM patrons p\nJOIN patron_types pt ON p.patron_type_id = pt.id\nWHERE p.age_range = ?\nGROUP BY pt.description\nORDER BY `avg_checkout_total` DESC\nLIMIT 3",
+			args:     []interface{}{"25 to 34 years"},
+			pipeline: `[{"$match":{"age_range":"25 to 34 years"}},{"$group":{"_id":{"patron_type":"$patron_type_desc"},"avg_checkout_total":{"$avg":{"$convert":{"input":"$checkout_total","to":"long","onError":null,"onNull":null}}}}},{"$project":{"_id":0,"patron_type":"$_id.patron_type","avg_checkout_total":{"$round":["$avg_checkout_total",2]}}},{"$sort":{"avg_checkout_total":-1}},{"$limit":3}]`,
+		},
+		{
+			name:     "quoted numbers and keywords in any case",
+			query:    "PATRONS WHERE Active_Year = '2023' COUNT",
+			sql:      "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE p.active_year = ?",
+			args:     []interface{}{int64(2023)},
+			pipeline: `[{"$match":{"active_year":"2023"}},` + countStages,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.query, err)
+			}
+			sql, args := q.SQL()
+			if sql != tt.sql {
+				t.Errorf("sql:\n%s\nwant:\n%s", sql, tt.sql)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args %#v, want %#v", args, tt.args)
+			}
+			if got := q.Pipeline(); got != tt.pipeline {
+				t.Errorf("pipeline:\n%s\nwant:\n%s", got, tt.pipeline)
+			}
+		})
+	}
+}
+
+// aggregates without group by are one row in sql even when nothing matched,
+// so the pipeline has to give back that same row
+func TestEmptyTotals(t *testing.T) {
+	tests := []struct {
+		query string
+		sql   string
+		empty string
+	}{
+		{
+			query: "patrons where active_year = 1900 count",
+			sql:   "SELECT COUNT(*) AS `count`\nFROM patrons p\nWHERE p.active_year = ?",
+			empty: `{"count":0}`,
+		},
+		{
+			query: "patrons where active_year = 1900 count, avg checkouts, sum renewals, max email",
+			sql:   "SELECT COUNT(*) AS `count`, ROUND(AVG(p.checkout_total), 2) AS `avg_checkout_total`, SUM(p.renewal_total) AS `sum_renewal_total`, MAX(p.email) AS `max_email`\nFROM patrons p\nWHERE p.active_year = ?",
+			empty: `{"count":0,"avg_checkout_total":null,"sum_renewal_total":null,"max_email":null}`,
+		},
+	}
+
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.query, err)
+		}
+		// what mysql gives back for no rows: COUNT is 0 and the rest NULL
+		if sql, _ := q.SQL(); sql != tt.sql {
+			t.Errorf("sql:\n%s\nwant:\n%s", sql, tt.sql)
+		}
+		pipeline := q.Pipeline()
+		if want := `{"$literal":` + tt.empty + `}`; !strings.Contains(pipeline, want) {
+			t.Errorf("%q: pipeline %s doesn't fall back to %s", tt.query, pipeline, tt.empty)
+		}
+	}
+
+	// grouped queries have no row for nothing in sql either
+	q, err := Parse("patrons where active_year = 1900 group by library count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pipeline := q.Pipeline(); strings.Contains(pipeline, "$facet") {
+		t.Errorf("grouped pipeline %s has a fallback row", pipeline)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"", "queries start with patrons"},
+		{"people count", "queries start with patrons"},
+		{"patrons where bogus = 1", `unknown field "bogus"`},
+		{"patrons where email = gmail", "text values go in quotes"},
+		{"patrons where active_year = 'soon'", "active_year is a number"},
+		{"patrons where sf = maybe", "within_sfc is true or false"},
+		{"patrons where (sf or active_year = 2023 count", "missing )"},
+		{"patrons where email = 'open", "missing closing '"},
+		{"patrons where active_year like '20%'", "like only works on text fields"},
+		{"patrons where email is 5", "expected null after is"},
+		{"patrons where library_code in 'X'", "in takes a list"},
+		{"patrons where library_code in ('X' 'Y')", "expected , or )"},
+		{"patrons where active_year", "expected a comparison after active_year"},
+		{"patrons where sf; drop table patrons", `unexpected ";"`},
+		{"patrons count count", "aggregate is in the query twice"},
+		{"patrons show email count", "show lists patrons"},
+		{"patrons avg email", "avg needs a number field"},
+		{"patrons limit -1", "limit takes a whole number"},
+		{"patrons group by library order by email", "can only order by a column of the result"},
+		{"patrons show email, email", "email is in the result twice"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		if err == nil {
+			t.Errorf("Parse(%q) worked, want an error with %q", tt.query, tt.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%q): %v, want %q", tt.query, err, tt.err)
+		}
+	}
+}
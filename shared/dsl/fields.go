@@ -0,0 +1,103 @@
+package dsl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Type is the kind of value a field holds
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Bool
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int:
+		return "number"
+	case Bool:
+		return "true/false"
+	}
+	return "text"
+}
+
+// Field is one patron field and where it lives in each backend. mysql keeps
+// the descriptions in lookup tables so some fields need a join, mongodb copies
+// them into every document but stores the number fields as strings.
+type Field struct {
+	Name string
+	Type Type
+
+	SQL  string // column to use in mysql, with its table alias
+	Join string // lookup table the column comes from, "" for patrons itself
+
+	Mongo       string // field in the patron documents
+	MongoString bool   // an Int that mongodb stores as a string
+}
+
+// Fields are the fields a query can use, in the order a plain listing shows them
+var Fields = []*Field{
+	{Name: "patron_type", Type: String, SQL: "pt.description", Join: "patron_types", Mongo: "patron_type_desc"},
+	{Name: "patron_type_code", Type: String, SQL: "pt.code", Join: "patron_types", Mongo: "patron_type_code"},
+	{Name: "age_range", Type: String, SQL: "p.age_range", Mongo: "age_range"},
+	{Name: "home_library", Type: String, SQL: "l.name", Join: "libraries", Mongo: "home_library_name"},
+	{Name: "home_library_code", Type: String, SQL: "p.home_library_code", Mongo: "home_library_code"},
+	{Name: "checkout_total", Type: Int, SQL: "p.checkout_total", Mongo: "checkout_total", MongoString: true},
+	{Name: "renewal_total", Type: Int, SQL: "p.renewal_total", Mongo: "renewal_total", MongoString: true},
+	{Name: "active_month", Type: Int, SQL: "p.active_month", Mongo: "active_month"},
+	{Name: "active_year", Type: Int, SQL: "p.active_year", Mongo: "active_year", MongoString: true},
+	{Name: "notification", Type: String, SQL: "nt.description", Join: "notification_types", Mongo: "notification_type_desc"},
+	{Name: "notification_code", Type: String, SQL: "p.notification_type_code", Mongo: "notification_type_code"},
+	{Name: "email", Type: String, SQL: "p.email", Mongo: "email"},
+	{Name: "within_sfc", Type: Bool, SQL: "p.within_sfc", Mongo: "within_sfc"},
+	{Name: "year_registered", Type: Int, SQL: "p.year_registered", Mongo: "year_registered", MongoString: true},
+}
+
+// shorter names people are likely to type
+var aliases = map[string]string{
+	"checkouts":         "checkout_total",
+	"renewals":          "renewal_total",
+	"library":           "home_library",
+	"library_code":      "home_library_code",
+	"type":              "patron_type",
+	"age":               "age_range",
+	"registered":        "year_registered",
+	"sf":                "within_sfc",
+	"notify":            "notification",
+	"notification_type": "notification",
+}
+
+// how each lookup table gets joined in mysql
+var sqlJoins = map[string]string{
+	"patron_types":       "JOIN patron_types pt ON p.patron_type_id = pt.id",
+	"libraries":          "JOIN libraries l ON p.home_library_code = l.code",
+	"notification_types": "JOIN notification_types nt ON p.notification_type_code = nt.code",
+}
+
+// LookupField finds a field by its name or one of its short names
+func LookupField(name string) (*Field, error) {
+	name = strings.ToLower(name)
+	if full, ok := aliases[name]; ok {
+		name = full
+	}
+	for _, f := range Fields {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown field %q, use one of %s", name, strings.Join(FieldNames(), ", "))
+}
+
+// FieldNames lists every field name, sorted
+func FieldNames() []string {
+	names := make([]string, len(Fields))
+	for i, f := range Fields {
+		names[i] = f.Name
+	}
+	sort.Strings(names)
+	return names
+}
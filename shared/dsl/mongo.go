@@ -0,0 +1,275 @@
+package dsl
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Pipeline compiles the query to an aggregation pipeline on the patrons
+// collection, written as extended json so this package doesn't need the
+// mongo driver. the mongodb app parses it like any other pipeline.
+func (q *Query) Pipeline() string {
+	var stages []interface{}
+
+	if q.Where != nil {
+		stages = append(stages, doc{{"$match", mongoExpr(q.Where)}})
+	}
+
+	if q.Show != nil {
+		project := doc{{"_id", 0}}
+		for _, f := range q.Show {
+			project = append(project, kv{f.Name, mongoValue(f)})
+		}
+		stages = append(stages, doc{{"$project", project}})
+	} else {
+		var id interface{}
+		if len(q.GroupBy) > 0 {
+			key := doc{}
+			for _, f := range q.GroupBy {
+				key = append(key, kv{f.Name, mongoValue(f)})
+			}
+			id = key
+		}
+		group := doc{{"_id", id}}
+		project := doc{{"_id", 0}}
+		for _, f := range q.GroupBy {
+			project = append(project, kv{f.Name, "$_id." + f.Name})
+		}
+		for _, a := range q.Aggregates {
+			if a.Func == "count" {
+				group = append(group, kv{a.Name(), doc{{"$sum", 1}}})
+				project = append(project, kv{a.Name(), 1})
+				continue
+			}
+			group = append(group, kv{a.Name(), doc{{"$" + a.Func, mongoValue(a.Field)}}})
+			if a.Func == "avg" {
+				project = append(project, kv{a.Name(), doc{{"$round", []interface{}{"$" + a.Name(), 2}}}})
+			} else {
+				project = append(project, kv{a.Name(), 1})
+			}
+		}
+		if len(q.GroupBy) > 0 {
+			stages = append(stages, doc{{"$group", group}}, doc{{"$project", project}})
+		} else {
+			stages = append(stages, totalsStages(q.Aggregates, group, project)...)
+		}
+	}
+
+	if len(q.OrderBy) > 0 {
+		sort := doc{}
+		for _, o := range q.OrderBy {
+			dir := 1
+			if o.Desc {
+				dir = -1
+			}
+			sort = append(sort, kv{o.Name, dir})
+		}
+		stages = append(stages, doc{{"$sort", sort}})
+	}
+	if q.Limit >= 0 {
+		stages = append(stages, doc{{"$limit", q.Limit}})
+	}
+
+	data, _ := json.Marshal(stages)
+	return string(data)
+}
+
+// sql always has a row for aggregates without group by, with a count of 0
+// and NULL for the rest when nothing matched. $group has no documents to
+// make one from then, so it runs inside $facet, which always gives back one
+// document, and an empty result is swapped for the row sql would have.
+func totalsStages(aggregates []Aggregate, group, project doc) []interface{} {
+	empty := doc{}
+	for _, a := range aggregates {
+		if a.Func == "count" {
+			empty = append(empty, kv{a.Name(), 0})
+		} else {
+			empty = append(empty, kv{a.Name(), nil})
+		}
+	}
+	return []interface{}{
+		doc{{"$facet", doc{{"rows", []interface{}{doc{{"$group", group}}, doc{{"$project", project}}}}}}},
+		doc{{"$replaceRoot", doc{{"newRoot", doc{{"$ifNull", []interface{}{
+			doc{{"$arrayElemAt", []interface{}{"$rows", 0}}},
+			doc{{"$literal", empty}},
+		}}}}}}},
+	}
+}
+
+// Filter compiles a condition on its own to a find filter in extended json,
+// for code that writes the rest of the query itself
+func Filter(e Expr) string {
+	data, _ := json.Marshal(mongoExpr(e))
+	return string(data)
+}
+
+// the value of a field in an aggregation expression. numbers that are
+// stored as strings get converted, with anything that isn't a number
+// treated like a missing value the same way mysql would have it as NULL.
+func mongoValue(f *Field) interface{} {
+	if !f.MongoString {
+		return "$" + f.Mongo
+	}
+	return doc{{"$convert", doc{
+		{"input", "$" + f.Mongo},
+		{"to", "long"},
+		{"onError", nil},
+		{"onNull", nil},
+	}}}
+}
+
+// a query filter for the $match stage
+func mongoExpr(e Expr) interface{} {
+	switch x := e.(type) {
+	case *And:
+		return doc{{"$and", []interface{}{mongoExpr(x.Left), mongoExpr(x.Right)}}}
+	case *Or:
+		return doc{{"$or", []interface{}{mongoExpr(x.Left), mongoExpr(x.Right)}}}
+	case *Not:
+		return mongoNot(x.X)
+	case *IsNull:
+		if x.Not {
+			return doc{{x.Field.Mongo, doc{{"$ne", nil}}}}
+		}
+		return doc{{x.Field.Mongo, nil}}
+	case *Compare:
+		return mongoCompare(x)
+	}
+	return doc{}
+}
+
+// a filter for the documents where the condition is false. in sql a
+// comparison with NULL is neither true nor false and NOT leaves those rows
+// out, where $nor on its own would let them in.
+func mongoNot(e Expr) interface{} {
+	switch x := e.(type) {
+	case *And:
+		return doc{{"$or", []interface{}{mongoNot(x.Left), mongoNot(x.Right)}}}
+	case *Or:
+		return doc{{"$and", []interface{}{mongoNot(x.Left), mongoNot(x.Right)}}}
+	case *Not:
+		return mongoExpr(x.X)
+	case *IsNull:
+		// is null is always true or false
+		return mongoExpr(&IsNull{Field: x.Field, Not: !x.Not})
+	case *Compare:
+		return doc{{"$and", []interface{}{
+			mongoHasValue(x.Field),
+			doc{{"$nor", []interface{}{mongoCompare(x)}}},
+		}}}
+	}
+	return doc{}
+}
+
+// a filter for the documents where the field isn't what sql would have as
+// NULL. numbers stored as strings also have to convert, "" is NULL in mysql.
+func mongoHasValue(f *Field) interface{} {
+	exists := doc{{f.Mongo, doc{{"$ne", nil}}}}
+	if !f.MongoString {
+		return exists
+	}
+	return doc{{"$and", []interface{}{
+		exists,
+		doc{{"$expr", doc{{"$ne", []interface{}{mongoValue(f), nil}}}}},
+	}}}
+}
+
+var mongoOps = map[string]string{"=": "$eq", "!=": "$ne", "<": "$lt", "<=": "$lte", ">": "$gt", ">=": "$gte"}
+
+func mongoCompare(c *Compare) interface{} {
+	f := c.Field
+	values := c.Values
+
+	if f.MongoString {
+		switch c.Op {
+		case "=", "!=", "in":
+			// equality can compare the stored strings, which keeps the index useful
+			values = make([]interface{}, len(c.Values))
+			for i, v := range c.Values {
+				values[i] = strconv.FormatInt(v.(int64), 10)
+			}
+		default:
+			// ranges have to compare numbers, "900" > "1000" as strings
+			expr := doc{{mongoOps[c.Op], []interface{}{mongoValue(f), values[0]}}}
+			if c.Op == "<" || c.Op == "<=" {
+				// values that aren't numbers convert to null, which sorts
+				// below every number
+				expr = doc{{"$and", []interface{}{
+					doc{{"$ne", []interface{}{mongoValue(f), nil}}},
+					expr,
+				}}}
+			}
+			return doc{{"$and", []interface{}{
+				doc{{f.Mongo, doc{{"$ne", nil}}}},
+				doc{{"$expr", expr}},
+			}}}
+		}
+	}
+
+	switch c.Op {
+	case "in":
+		return doc{{f.Mongo, doc{{"$in", values}}}}
+	case "like":
+		return doc{{f.Mongo, doc{{"$regex", likeToRegex(values[0].(string))}, {"$options", "i"}}}}
+	case "=":
+		return doc{{f.Mongo, values[0]}}
+	case "!=":
+		// sql's != never matches NULL, $ne on its own would
+		return doc{{f.Mongo, doc{{"$nin", []interface{}{values[0], nil}}}}}
+	}
+	return doc{{f.Mongo, doc{{mongoOps[c.Op], values[0]}}}}
+}
+
+// sql LIKE patterns use % and _ as wildcards. mysql compares without caring
+// about case, so the regex doesn't either.
+func likeToRegex(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// doc is a json object that keeps its keys in the order they were added,
+// which matters for $sort and for the order of the result columns
+type doc []kv
+
+type kv struct {
+	key   string
+	value interface{}
+}
+
+func (d doc) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, e := range d {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(e.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(e.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,118 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SQL compiles the query for the mysql schema. values are returned as
+// placeholder arguments rather than written into the sql.
+func (q *Query) SQL() (string, []interface{}) {
+	var args []interface{}
+	joins := map[string]bool{}
+	use := func(f *Field) string {
+		if f.Join != "" {
+			joins[f.Join] = true
+		}
+		return f.SQL
+	}
+
+	var selects []string
+	for _, f := range q.Show {
+		selects = append(selects, fmt.Sprintf("%s AS `%s`", use(f), f.Name))
+	}
+	var groups []string
+	for _, f := range q.GroupBy {
+		selects = append(selects, fmt.Sprintf("%s AS `%s`", use(f), f.Name))
+		groups = append(groups, use(f))
+	}
+	for _, a := range q.Aggregates {
+		var expr string
+		switch a.Func {
+		case "count":
+			expr = "COUNT(*)"
+		case "avg":
+			// rounded so both backends print the same number
+			expr = fmt.Sprintf("ROUND(AVG(%s), 2)", use(a.Field))
+		default:
+			expr = fmt.Sprintf("%s(%s)", strings.ToUpper(a.Func), use(a.Field))
+		}
+		selects = append(selects, fmt.Sprintf("%s AS `%s`", expr, a.Name()))
+	}
+
+	where := ""
+	if q.Where != nil {
+		where = sqlExpr(q.Where, use, &args)
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT " + strings.Join(selects, ", ") + "\nFROM patrons p")
+	// always in the same order so the sql doesn't change from run to run
+	for _, table := range []string{"patron_types", "libraries", "notification_types"} {
+		if joins[table] {
+			b.WriteString("\n" + sqlJoins[table])
+		}
+	}
+	if where != "" {
+		b.WriteString("\nWHERE " + where)
+	}
+	if len(groups) > 0 {
+		b.WriteString("\nGROUP BY " + strings.Join(groups, ", "))
+	}
+	if len(q.OrderBy) > 0 {
+		var order []string
+		for _, o := range q.OrderBy {
+			dir := "ASC"
+			if o.Desc {
+				dir = "DESC"
+			}
+			order = append(order, fmt.Sprintf("`%s` %s", o.Name, dir))
+		}
+		b.WriteString("\nORDER BY " + strings.Join(order, ", "))
+	}
+	if q.Limit >= 0 {
+		b.WriteString(fmt.Sprintf("\nLIMIT %d", q.Limit))
+	}
+	return b.String(), args
+}
+
+func sqlExpr(e Expr, use func(*Field) string, args *[]interface{}) string {
+	switch x := e.(type) {
+	case *And:
+		return "(" + sqlExpr(x.Left, use, args) + " AND " + sqlExpr(x.Right, use, args) + ")"
+	case *Or:
+		return "(" + sqlExpr(x.Left, use, args) + " OR " + sqlExpr(x.Right, use, args) + ")"
+	case *Not:
+		return "NOT " + sqlExpr(x.X, use, args)
+	case *IsNull:
+		if x.Not {
+			return use(x.Field) + " IS NOT NULL"
+		}
+		return use(x.Field) + " IS NULL"
+	case *Compare:
+		*args = append(*args, x.Values...)
+		switch x.Op {
+		case "in":
+			marks := strings.TrimSuffix(strings.Repeat("?, ", len(x.Values)), ", ")
+			return use(x.Field) + " IN (" + marks + ")"
+		case "like":
+			return use(x.Field) + " LIKE ?"
+		}
+		return use(x.Field) + " " + x.Op + " ?"
+	}
+	return ""
+}
+
+// FilterSQL compiles a condition on its own, for code that writes the rest
+// of the query itself. the columns use the same aliases as SQL, p for
+// patrons and pt, l and nt for the lookup tables, so those have to be joined.
+func FilterSQL(e Expr) (string, []interface{}) {
+	var args []interface{}
+	where := sqlExpr(e, func(f *Field) string { return f.SQL }, &args)
+	return where, args
+}
+
+// Joins are the joins of every lookup table in the order SQL puts them
+func Joins() string {
+	return sqlJoins["patron_types"] + "\n" + sqlJoins["libraries"] + "\n" + sqlJoins["notification_types"]
+}
@@ -0,0 +1,107 @@
+// Package interrupt has the parts of stopping queries that both text
+// interfaces share: the timeout every query gets, ctrl-c cancelling the one
+// that's running and what to tell people when either happens. stopping the
+// query on the server is up to each app, mysql runs KILL QUERY on the
+// connection and mongodb kills the operations tagged with the query's comment.
+package interrupt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is how long a query gets before it's stopped, \timeout changes it
+const DefaultTimeout = 60 * time.Second
+
+// Running is the query that's going, if there is one, so ctrl-c can cancel it.
+// the zero value is ready to use.
+type Running struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// Handle catches ctrl-c while the text interface is running. if a query is
+// going it gets cancelled, otherwise we just remind people how to get out.
+func (r *Running) Handle() (stop func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+
+	go func() {
+		for range sigs {
+			r.mu.Lock()
+			cancel := r.cancel
+			r.mu.Unlock()
+
+			if cancel != nil {
+				fmt.Println("\ncancelling query...")
+				cancel()
+			} else {
+				fmt.Print("\n(type 'exit' to quit)\n> ")
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(sigs)
+	}
+}
+
+// Start gives a query its context. it runs out after the timeout, 0 means
+// never, and ctrl-c cancels it until done is called.
+func (r *Running) Start(timeout time.Duration) (ctx context.Context, done func()) {
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	r.mu.Lock()
+	r.cancel = cancel
+	r.mu.Unlock()
+
+	return ctx, func() {
+		r.mu.Lock()
+		r.cancel = nil
+		r.mu.Unlock()
+		cancel()
+	}
+}
+
+// Error turns context errors into something more readable than "context canceled"
+func Error(ctx context.Context, err error) string {
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return "query cancelled"
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return "query timed out, use \\timeout to give it longer"
+	}
+	return fmt.Sprint("query error: ", err)
+}
+
+// ParseTimeout reads a timeout like 30s or 2m. a bare number is taken as seconds.
+func ParseTimeout(arg string) (time.Duration, error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		arg = fmt.Sprintf("%ds", n)
+	}
+	d, err := time.ParseDuration(arg)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("couldn't read timeout %q, try something like 30s or 2m", arg)
+	}
+	return d, nil
+}
+
+// DescribeTimeout is how \timeout shows the timeout
+func DescribeTimeout(d time.Duration) string {
+	if d == 0 {
+		return "off"
+	}
+	return d.String()
+}
@@ -0,0 +1,62 @@
+package interrupt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want time.Duration
+		ok   bool
+	}{
+		{"30", 30 * time.Second, true},
+		{"30s", 30 * time.Second, true},
+		{"2m", 2 * time.Minute, true},
+		{"0", 0, true},
+		{"-5s", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		d, err := ParseTimeout(tt.arg)
+		if (err == nil) != tt.ok || d != tt.want {
+			t.Errorf("ParseTimeout(%q) = %v, %v, want %v", tt.arg, d, err, tt.want)
+		}
+	}
+	if got := DescribeTimeout(0); got != "off" {
+		t.Errorf("DescribeTimeout(0) = %q", got)
+	}
+}
+
+func TestStart(t *testing.T) {
+	var r Running
+	ctx, done := r.Start(0)
+	r.mu.Lock()
+	cancel := r.cancel
+	r.mu.Unlock()
+	if cancel == nil {
+		t.Fatal("no cancel for the running query")
+	}
+	cancel()
+	if got := Error(ctx, ctx.Err()); got != "query cancelled" {
+		t.Errorf("cancelled query: %q", got)
+	}
+	done()
+	if r.cancel != nil {
+		t.Error("cancel still set after done")
+	}
+
+	ctx, done = r.Start(time.Millisecond)
+	defer done()
+	<-ctx.Done()
+	if got := Error(ctx, ctx.Err()); got != "query timed out, use \\timeout to give it longer" {
+		t.Errorf("timed out query: %q", got)
+	}
+
+	if got := Error(context.Background(), errors.New("boom")); got != "query error: boom" {
+		t.Errorf("failed query: %q", got)
+	}
+}
@@ -0,0 +1,154 @@
+// Package metrics has the prometheus metrics of both apps: how the imports
+// went, how long the saved queries and reports take and the connection
+// pools. every metric has a backend label, mysql or mongodb, so one grafana
+// dashboard can show both. serve has them on /metrics, -metrics-addr serves
+// them for any other command and -push-gateway pushes them when a one-shot
+// command like import is done.
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Registry has every metric of the app
+var Registry = prometheus.NewRegistry()
+
+// the registerer that adds the backend label, set by Setup
+var (
+	backend    string
+	registerer prometheus.Registerer = Registry
+)
+
+// why the import turned a row away
+const (
+	ShortRow     = "short_row"     // fewer than the 14 columns
+	LookupFailed = "lookup_failed" // its patron type, library or notification type couldn't be added
+	InsertFailed = "insert_failed" // the insert itself failed
+)
+
+var (
+	rowsProcessed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sfils_import_rows_processed_total",
+		Help: "Workbook rows the import has read, not counting the header.",
+	})
+	rowsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sfils_import_rows_rejected_total",
+		Help: "Workbook rows the import didn't add, by why.",
+	}, []string{"reason"})
+	phaseSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sfils_import_phase_seconds",
+		Help: "How long each phase of the last import took: parse, clean, lookups and writes, the same phases bench import times.",
+	}, []string{"phase"})
+	lastImport = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sfils_import_last_success_timestamp_seconds",
+		Help: "When the last import that went through finished, as a unix time.",
+	})
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "sfils_query_duration_seconds",
+		Help: "How long saved queries and reports took, by name. kind is saved or report.",
+		// 1ms up to about a minute, the full table scans on the workbook are a few seconds
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 9),
+	}, []string{"kind", "query"})
+	queryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sfils_query_errors_total",
+		Help: "Saved queries and reports that failed, by name.",
+	}, []string{"kind", "query"})
+)
+
+// Setup registers the metrics with the backend's label. main calls it
+// before anything is counted.
+func Setup(name string) {
+	backend = name
+	registerer = prometheus.WrapRegistererWith(prometheus.Labels{"backend": name}, Registry)
+	registerer.MustRegister(rowsProcessed, rowsRejected, phaseSeconds, lastImport, queryDuration, queryErrors)
+	Registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+// Register adds a collector of the app's own, like its pool stats, with the
+// backend label
+func Register(c prometheus.Collector) {
+	registerer.MustRegister(c)
+}
+
+// Rows counts the rows an import read and the ones it turned away. rejected
+// is how many for each reason.
+func Rows(processed int, rejected map[string]int) {
+	rowsProcessed.Add(float64(processed))
+	for reason, n := range rejected {
+		rowsRejected.WithLabelValues(reason).Add(float64(n))
+	}
+}
+
+// Phase starts timing a phase of the import, the func it returns stops it
+//
+//	done := metrics.Phase("parse")
+//	rows, err := readWorkbook(file)
+//	done()
+func Phase(name string) func() {
+	start := time.Now()
+	return func() {
+		phaseSeconds.WithLabelValues(name).Set(time.Since(start).Seconds())
+	}
+}
+
+// ImportDone marks an import as finished, for alerts on loads that stopped
+// happening
+func ImportDone() {
+	lastImport.SetToCurrentTime()
+}
+
+// Query starts timing a saved query or report, the func it returns stops it
+// with how the query went
+func Query(kind, name string) func(err error) {
+	start := time.Now()
+	return func(err error) {
+		queryDuration.WithLabelValues(kind, name).Observe(time.Since(start).Seconds())
+		if err != nil {
+			queryErrors.WithLabelValues(kind, name).Inc()
+		}
+	}
+}
+
+// Handler serves the metrics for prometheus to scrape
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Serve serves the metrics on addr at /metrics while the command runs
+func Serve(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("couldn't serve the metrics: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", Handler())
+	go func() {
+		srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		if err := srv.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("metrics server stopped: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// Push sends the metrics to a prometheus pushgateway, for commands that are
+// done before prometheus would get to scrape them. they replace whatever the
+// last run of the same backend pushed. the group is instance and not
+// backend, the pushgateway turns away metrics that already have the
+// grouping label and ours all have backend.
+func Push(url string) error {
+	err := push.New(url, "sfils").Gatherer(Registry).Grouping("instance", backend).Push()
+	if err != nil {
+		return fmt.Errorf("couldn't push the metrics to %s: %v", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPush(t *testing.T) {
+	Setup("mysql")
+	Rows(10, map[string]int{ShortRow: 1})
+	ImportDone()
+
+	var path, body string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		path, body = r.URL.Path, string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	if err := Push(gateway.URL); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if path != "/metrics/job/sfils/instance/mysql" {
+		t.Errorf("pushed to %s", path)
+	}
+	if !strings.Contains(body, "sfils_import_rows_processed_total") {
+		t.Errorf("the push didn't have the import metrics")
+	}
+}
+
+func TestPushFails(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusBadRequest)
+	}))
+	defer gateway.Close()
+
+	if err := Push(gateway.URL); err == nil {
+		t.Fatal("expected an error when the pushgateway turns the push away")
+	}
+}
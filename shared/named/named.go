@@ -0,0 +1,148 @@
+// Package named has the parts of saved queries that both apps share: typed
+// parameters and the key=value arguments that fill them in. how the queries
+// themselves are stored is up to each app.
+package named
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Param is a value a saved query needs. Default is nil when it has to be given.
+type Param struct {
+	Name    string  `json:"name"`
+	Type    string  `json:"type"`
+	Default *string `json:"default,omitempty"`
+}
+
+// Types are the parameter types that Convert understands
+var Types = []string{"int", "float", "string", "bool"}
+
+// Convert turns the text of an argument into a value of the parameter's type
+func (p Param) Convert(text string) (interface{}, error) {
+	switch p.Type {
+	case "int":
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s has to be a whole number, got %q", p.Name, text)
+		}
+		return n, nil
+	case "float":
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s has to be a number, got %q", p.Name, text)
+		}
+		return f, nil
+	case "bool":
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s has to be true or false, got %q", p.Name, text)
+		}
+		return b, nil
+	case "string", "":
+		return text, nil
+	}
+	return nil, fmt.Errorf("%s has unknown type %q (use %s)", p.Name, p.Type, strings.Join(Types, ", "))
+}
+
+// Resolve checks the arguments against the parameters and converts them,
+// filling in defaults for anything left out
+func Resolve(params []Param, args map[string]string) (map[string]interface{}, error) {
+	known := map[string]bool{}
+	values := map[string]interface{}{}
+
+	for _, p := range params {
+		known[p.Name] = true
+		text, ok := args[p.Name]
+		if !ok {
+			if p.Default == nil {
+				return nil, fmt.Errorf("missing %s (%s)", p.Name, p.Type)
+			}
+			text = *p.Default
+		}
+		v, err := p.Convert(text)
+		if err != nil {
+			return nil, err
+		}
+		values[p.Name] = v
+	}
+
+	var unknown []string
+	for name := range args {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown parameter %s", strings.Join(unknown, ", "))
+	}
+	return values, nil
+}
+
+// ParseArgs reads key=value arguments
+func ParseArgs(args []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("arguments look like key=value, got %q", arg)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// SplitArgs splits a line on spaces, keeping anything in quotes together so
+// values like age="25 to 34 years" work
+func SplitArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("missing closing %c", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// Usage is a one line example of how to run a query, like "top_libraries limit=10"
+func Usage(name string, params []Param) string {
+	parts := []string{name}
+	for _, p := range params {
+		if p.Default != nil {
+			parts = append(parts, fmt.Sprintf("[%s=%s]", p.Name, *p.Default))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s=<%s>", p.Name, p.Type))
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,144 @@
+package named
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		typ   string
+		text  string
+		value interface{}
+		err   string
+	}{
+		{"int", "10", int64(10), ""},
+		{"int", "-3", int64(-3), ""},
+		{"int", "1.5", nil, "limit has to be a whole number"},
+		{"int", "10; DROP TABLE patrons", nil, "limit has to be a whole number"},
+		{"int", "", nil, "limit has to be a whole number"},
+		{"float", "1.5", 1.5, ""},
+		{"float", "lots", nil, "limit has to be a number"},
+		{"bool", "true", true, ""},
+		{"bool", "0", false, ""},
+		{"bool", "yes", nil, "limit has to be true or false"},
+		{"string", "' OR 1=1 --", "' OR 1=1 --", ""},
+		{"", "anything", "anything", ""},
+		{"date", "2023-01-01", nil, `limit has unknown type "date"`},
+	}
+	for _, tt := range tests {
+		p := Param{Name: "limit", Type: tt.typ}
+		v, err := p.Convert(tt.text)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Convert(%s, %q): %v, want %q", tt.typ, tt.text, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Convert(%s, %q): %v", tt.typ, tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, tt.value) {
+			t.Errorf("Convert(%s, %q) = %#v, want %#v", tt.typ, tt.text, v, tt.value)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ten := "10"
+	params := []Param{
+		{Name: "library", Type: "string"},
+		{Name: "limit", Type: "int", Default: &ten},
+	}
+
+	tests := []struct {
+		name   string
+		args   map[string]string
+		values map[string]interface{}
+		err    string
+	}{
+		{"default filled in", map[string]string{"library": "X"}, map[string]interface{}{"library": "X", "limit": int64(10)}, ""},
+		{"default overridden", map[string]string{"library": "X", "limit": "3"}, map[string]interface{}{"library": "X", "limit": int64(3)}, ""},
+		{"missing", map[string]string{"limit": "3"}, nil, "missing library (string)"},
+		{"bad type", map[string]string{"library": "X", "limit": "ten"}, nil, "limit has to be a whole number"},
+		{"unknown", map[string]string{"library": "X", "zz": "1", "aa": "2"}, nil, "unknown parameter aa, zz"},
+	}
+	for _, tt := range tests {
+		values, err := Resolve(params, tt.args)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(values, tt.values) {
+			t.Errorf("%s: %#v, want %#v", tt.name, values, tt.values)
+		}
+	}
+
+	bad := "many"
+	if _, err := Resolve([]Param{{Name: "limit", Type: "int", Default: &bad}}, nil); err == nil {
+		t.Error("a default of the wrong type should be an error")
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	values, err := ParseArgs([]string{"limit=5", "age=25 to 34 years", "note=a=b", "empty="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"limit": "5", "age": "25 to 34 years", "note": "a=b", "empty": ""}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("ParseArgs = %#v, want %#v", values, want)
+	}
+	for _, arg := range []string{"limit", "=5"} {
+		if _, err := ParseArgs([]string{arg}); err == nil {
+			t.Errorf("ParseArgs(%q) should be an error", arg)
+		}
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		args []string
+		err  string
+	}{
+		{":run top_libraries limit=5", []string{":run", "top_libraries", "limit=5"}, ""},
+		{`:run by_age age="25 to 34 years"  limit=3`, []string{":run", "by_age", "age=25 to 34 years", "limit=3"}, ""},
+		{":run q note='it said \"hi\"'", []string{":run", "q", `note=it said "hi"`}, ""},
+		{`:run q empty=""`, []string{":run", "q", "empty="}, ""},
+		{"", nil, ""},
+		{`:run q age="25 to`, nil, "missing closing \""},
+	}
+	for _, tt := range tests {
+		args, err := SplitArgs(tt.line)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("SplitArgs(%q): %v, want %q", tt.line, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("SplitArgs(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("SplitArgs(%q) = %q, want %q", tt.line, args, tt.args)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	ten := "10"
+	got := Usage("top_libraries", []Param{{Name: "library", Type: "string"}, {Name: "limit", Type: "int", Default: &ten}})
+	if want := "top_libraries library=<string> [limit=10]"; got != want {
+		t.Errorf("Usage = %q, want %q", got, want)
+	}
+}
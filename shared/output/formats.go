@@ -0,0 +1,184 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// delimitedWriter does csv and tsv. plain encoding/csv can't tell NULL apart
+// from an empty string so the quoting is done by hand:
+//   - csv: NULL is an empty field, an empty string is ""
+//   - tsv: NULL is \N and tabs/newlines are backslash escaped, same as mysql
+type delimitedWriter struct {
+	out io.Writer
+	sep string
+}
+
+func (d *delimitedWriter) Begin(cols []Column) error {
+	names := make([]Value, len(cols))
+	for i, col := range cols {
+		names[i] = Value{Kind: String, Text: col.Name}
+	}
+	return d.Row(names)
+}
+
+func (d *delimitedWriter) Row(vals []Value) error {
+	fields := make([]string, len(vals))
+	for i, v := range vals {
+		if d.sep == "\t" {
+			fields[i] = tsvField(v)
+		} else {
+			fields[i] = csvField(v)
+		}
+	}
+	_, err := fmt.Fprintln(d.out, strings.Join(fields, d.sep))
+	return err
+}
+
+func (d *delimitedWriter) End() error { return nil }
+
+func csvField(v Value) string {
+	if v.Kind == Null {
+		return ""
+	}
+	s := v.Text
+	if s == "" || strings.ContainsAny(s, ",\"\r\n") || strings.TrimSpace(s) != s {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return s
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func tsvField(v Value) string {
+	if v.Kind == Null {
+		return `\N`
+	}
+	return tsvEscaper.Replace(v.Text)
+}
+
+// jsonWriter writes an array of objects, or one object per line for jsonl.
+// keys come out in column order.
+type jsonWriter struct {
+	out   io.Writer
+	lines bool
+	cols  []Column
+	count int
+}
+
+func (j *jsonWriter) Begin(cols []Column) error {
+	j.cols = cols
+	if !j.lines {
+		_, err := fmt.Fprint(j.out, "[")
+		return err
+	}
+	return nil
+}
+
+func (j *jsonWriter) Row(vals []Value) error {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, v := range vals {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(jsonString(j.cols[i].Name))
+		buf.WriteString(": ")
+		buf.WriteString(jsonValue(v))
+	}
+	buf.WriteByte('}')
+
+	var err error
+	switch {
+	case j.lines:
+		_, err = fmt.Fprintln(j.out, buf.String())
+	case j.count == 0:
+		_, err = fmt.Fprint(j.out, "\n  "+buf.String())
+	default:
+		_, err = fmt.Fprint(j.out, ",\n  "+buf.String())
+	}
+	j.count++
+	return err
+}
+
+func (j *jsonWriter) End() error {
+	if j.lines {
+		return nil
+	}
+	if j.count > 0 {
+		_, err := fmt.Fprintln(j.out, "\n]")
+		return err
+	}
+	_, err := fmt.Fprintln(j.out, "]")
+	return err
+}
+
+func jsonValue(v Value) string {
+	switch v.Kind {
+	case Null:
+		return "null"
+	case Number:
+		// decimals come through as text so they keep their precision. if it
+		// somehow isn't a valid json number we quote it rather than break the output
+		if json.Valid([]byte(v.Text)) && v.Text != "" && strings.IndexAny(v.Text[:1], "-0123456789") == 0 {
+			return v.Text
+		}
+	case Bool:
+		if v.Text == "true" || v.Text == "false" {
+			return v.Text
+		}
+	case JSON:
+		if json.Valid([]byte(v.Text)) {
+			return v.Text
+		}
+	}
+	return jsonString(v.Text)
+}
+
+// json.Marshal escapes <, > and & which just makes emails harder to read
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// markdownWriter writes a github style table, numbers aligned right
+type markdownWriter struct {
+	out io.Writer
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+func (m *markdownWriter) Begin(cols []Column) error {
+	names := make([]string, len(cols))
+	aligns := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = markdownEscaper.Replace(col.Name)
+		aligns[i] = "---"
+		if col.Numeric {
+			aligns[i] = "---:"
+		}
+	}
+	_, err := fmt.Fprintf(m.out, "| %s |\n| %s |\n", strings.Join(names, " | "), strings.Join(aligns, " | "))
+	return err
+}
+
+func (m *markdownWriter) Row(vals []Value) error {
+	cells := make([]string, len(vals))
+	for i, v := range vals {
+		if v.Kind == Null {
+			cells[i] = "NULL"
+		} else {
+			cells[i] = markdownEscaper.Replace(v.Text)
+		}
+	}
+	_, err := fmt.Fprintf(m.out, "| %s |\n", strings.Join(cells, " | "))
+	return err
+}
+
+func (m *markdownWriter) End() error { return nil }
@@ -0,0 +1,79 @@
+// Package output writes query results in the formats both text interfaces
+// support: an aligned table, csv, tsv, json, jsonl and markdown.
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Formats lists every format name that New understands
+var Formats = []string{"table", "csv", "tsv", "json", "jsonl", "markdown"}
+
+// Column describes a single column of a result set
+type Column struct {
+	Name    string
+	Numeric bool
+}
+
+// Kind is what sort of value a cell holds. it decides how the value gets
+// quoted, mainly so json keeps numbers as numbers and null as null.
+type Kind int
+
+const (
+	Null Kind = iota
+	String
+	Number
+	Bool
+	// JSON is text that is already valid json, like a nested mongo document
+	JSON
+)
+
+// Value is a single cell. Text is what gets printed for everything but Null.
+type Value struct {
+	Kind Kind
+	Text string
+}
+
+// Writer takes a result set one row at a time
+type Writer interface {
+	Begin(cols []Column) error
+	Row(vals []Value) error
+	End() error
+}
+
+// Options are the display settings the table format cares about
+type Options struct {
+	MaxWidth int // 0 means never truncate
+	Expanded ExpandedMode
+}
+
+// New returns a writer for the named format
+func New(format string, w io.Writer, opts Options) (Writer, error) {
+	switch format {
+	case "table":
+		return &tableWriter{out: w, maxWidth: opts.MaxWidth, expanded: opts.Expanded}, nil
+	case "csv":
+		return &delimitedWriter{out: w, sep: ","}, nil
+	case "tsv":
+		return &delimitedWriter{out: w, sep: "\t"}, nil
+	case "json":
+		return &jsonWriter{out: w}, nil
+	case "jsonl":
+		return &jsonWriter{out: w, lines: true}, nil
+	case "markdown":
+		return &markdownWriter{out: w}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (use %s)", format, strings.Join(Formats, ", "))
+}
+
+// IsFormat reports whether the name is one of Formats
+func IsFormat(name string) bool {
+	for _, f := range Formats {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package output
+
+import (
+	"strings"
+	"testing"
+)
+
+// runs rows through a writer and gives back what it printed
+func render(t *testing.T, format string, opts Options, cols []Column, rows [][]Value) string {
+	t.Helper()
+	var b strings.Builder
+	w, err := New(format, &b, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Begin(cols); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.Row(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.End(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+var (
+	patronCols = []Column{{Name: "name"}, {Name: "checkouts", Numeric: true}}
+	patronRows = [][]Value{
+		{{Kind: String, Text: "Ann"}, {Kind: Number, Text: "5"}},
+		{{Kind: Null}, {Kind: Number, Text: "120"}},
+		{{Kind: String, Text: "a\tb"}, {Kind: Null}},
+	}
+)
+
+func TestTable(t *testing.T) {
+	t.Setenv("COLUMNS", "80")
+	long := []Column{{Name: "description"}}
+	longRows := [][]Value{{{Kind: String, Text: "a very long value"}}}
+
+	tests := []struct {
+		name string
+		opts Options
+		cols []Column
+		rows [][]Value
+		want string
+	}{
+		{
+			name: "numbers go right, text left, nulls and tabs show up",
+			opts: Options{MaxWidth: DefaultMaxWidth},
+			cols: patronCols,
+			rows: patronRows,
+			want: `+------+-----------+
+| name | checkouts |
++------+-----------+
+| Ann  |         5 |
+| NULL |       120 |
+| a b  |      NULL |
++------+-----------+
+`,
+		},
+		{
+			name: "names and cells get cut off at the width",
+			opts: Options{MaxWidth: 5},
+			cols: long,
+			rows: longRows,
+			want: `+-------+
+| desc… |
++-------+
+| a ve… |
++-------+
+`,
+		},
+		{
+			name: "a width of 0 never cuts off",
+			cols: long,
+			rows: longRows,
+			want: `+-------------------+
+| description       |
++-------------------+
+| a very long value |
++-------------------+
+`,
+		},
+		{
+			name: "no rows",
+			opts: Options{MaxWidth: DefaultMaxWidth},
+			cols: patronCols,
+			want: `+------+-----------+
+| name | checkouts |
++------+-----------+
++------+-----------+
+`,
+		},
+		{
+			name: "expanded",
+			opts: Options{MaxWidth: 2, Expanded: ExpandedOn},
+			cols: patronCols,
+			rows: patronRows[:2],
+			want: `-[ RECORD 1 ]
+name      | Ann
+checkouts | 5
+-[ RECORD 2 ]
+name      | NULL
+checkouts | 120
+`,
+		},
+		{
+			name: "expanded with no rows",
+			opts: Options{Expanded: ExpandedOn},
+			cols: patronCols,
+			want: "(no rows)\n",
+		},
+		{
+			name: "auto stays a table when it fits",
+			opts: Options{MaxWidth: DefaultMaxWidth, Expanded: ExpandedAuto},
+			cols: patronCols,
+			rows: patronRows[:1],
+			want: `+------+-----------+
+| name | checkouts |
++------+-----------+
+| Ann  |         5 |
++------+-----------+
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, "table", tt.opts, tt.cols, tt.rows); got != tt.want {
+			t.Errorf("%s:\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTableAutoExpands(t *testing.T) {
+	// the table is 20 wide, more than the terminal
+	t.Setenv("COLUMNS", "10")
+	got := render(t, "table", Options{MaxWidth: DefaultMaxWidth, Expanded: ExpandedAuto}, patronCols, patronRows[:1])
+	want := "-[ RECORD 1 ]\nname      | Ann\ncheckouts | 5\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// NULL and empty strings have to stay different in every format, and json
+// keeps numbers, bools and nested documents as they are
+func TestFormats(t *testing.T) {
+	cols := []Column{{Name: "s"}, {Name: "n", Numeric: true}, {Name: "b"}, {Name: "j"}}
+	rows := [][]Value{
+		{{Kind: String, Text: ""}, {Kind: Number, Text: "1.50"}, {Kind: Bool, Text: "true"}, {Kind: JSON, Text: `{"a":1}`}},
+		{{Kind: Null}, {Kind: Null}, {Kind: Null}, {Kind: Null}},
+		{{Kind: String, Text: `say "hi", ok`}, {Kind: Number, Text: "-2"}, {Kind: Bool, Text: "false"}, {Kind: JSON, Text: "[1,2]"}},
+		{{Kind: String, Text: "a|b\nc\td"}, {Kind: Number, Text: "NaN"}, {Kind: String, Text: "<x@y.com>"}, {Kind: JSON, Text: "{broken"}},
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"csv", `s,n,b,j
+"",1.50,true,"{""a"":1}"
+,,,
+"say ""hi"", ok",-2,false,"[1,2]"
+"a|b
+c	d",NaN,<x@y.com>,{broken
+`},
+		{"tsv", "s\tn\tb\tj\n" +
+			"\t1.50\ttrue\t{\"a\":1}\n" +
+			"\\N\t\\N\t\\N\t\\N\n" +
+			"say \"hi\", ok\t-2\tfalse\t[1,2]\n" +
+			"a|b\\nc\\td\tNaN\t<x@y.com>\t{broken\n"},
+		{"json", `[
+  {"s": "", "n": 1.50, "b": true, "j": {"a":1}},
+  {"s": null, "n": null, "b": null, "j": null},
+  {"s": "say \"hi\", ok", "n": -2, "b": false, "j": [1,2]},
+  {"s": "a|b\nc\td", "n": "NaN", "b": "<x@y.com>", "j": "{broken"}
+]
+`},
+		{"jsonl", `{"s": "", "n": 1.50, "b": true, "j": {"a":1}}
+{"s": null, "n": null, "b": null, "j": null}
+{"s": "say \"hi\", ok", "n": -2, "b": false, "j": [1,2]}
+{"s": "a|b\nc\td", "n": "NaN", "b": "<x@y.com>", "j": "{broken"}
+`},
+		{"markdown", `| s | n | b | j |
+| --- | ---: | --- | --- |
+|  | 1.50 | true | {"a":1} |
+| NULL | NULL | NULL | NULL |
+| say "hi", ok | -2 | false | [1,2] |
+| a\|b<br>c	d | NaN | <x@y.com> | {broken |
+`},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, tt.format, Options{}, cols, rows); got != tt.want {
+			t.Errorf("%s:\n%s\nwant:\n%s", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestEmptyJSON(t *testing.T) {
+	if got := render(t, "json", Options{}, patronCols, nil); got != "[]\n" {
+		t.Errorf("json with no rows: %q", got)
+	}
+	if got := render(t, "jsonl", Options{}, patronCols, nil); got != "" {
+		t.Errorf("jsonl with no rows: %q", got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, f := range Formats {
+		if _, err := New(f, &strings.Builder{}, Options{}); err != nil {
+			t.Errorf("New(%q): %v", f, err)
+		}
+	}
+	if _, err := New("xml", &strings.Builder{}, Options{}); err == nil || !strings.Contains(err.Error(), `unknown format "xml"`) {
+		t.Errorf("New(xml): %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Settings are the display options that can be changed from a text interface
+type Settings struct {
+	Format   string
+	MaxWidth int
+	Expanded ExpandedMode
+
+	file     *os.File // set while \o is sending results to a file
+	fileName string
+}
+
+// NewSettings starts out printing tables to stdout
+func NewSettings() *Settings {
+	return &Settings{Format: "table", MaxWidth: DefaultMaxWidth}
+}
+
+// Out is where results should be written
+func (s *Settings) Out() io.Writer {
+	if s.file != nil {
+		return s.file
+	}
+	return os.Stdout
+}
+
+// Redirected returns the file results are going to, or "" for stdout
+func (s *Settings) Redirected() string {
+	return s.fileName
+}
+
+// NewWriter returns a writer for the current format and destination
+func (s *Settings) NewWriter() (Writer, error) {
+	return New(s.Format, s.Out(), Options{MaxWidth: s.MaxWidth, Expanded: s.Expanded})
+}
+
+// Close closes the \o file if there is one
+func (s *Settings) Close() {
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+		s.fileName = ""
+	}
+}
+
+// Command handles \x, \width, \format and \o. it returns false when cmd is
+// not one of those so the caller can try its own commands.
+func (s *Settings) Command(cmd string, args []string) bool {
+	switch cmd {
+	case `\x`:
+		if len(args) == 0 {
+			// plain \x flips between on and off
+			if s.Expanded == ExpandedOff {
+				s.Expanded = ExpandedOn
+			} else {
+				s.Expanded = ExpandedOff
+			}
+		} else {
+			switch args[0] {
+			case "on":
+				s.Expanded = ExpandedOn
+			case "off":
+				s.Expanded = ExpandedOff
+			case "auto":
+				s.Expanded = ExpandedAuto
+			default:
+				fmt.Println(`usage: \x [on|off|auto]`)
+				return true
+			}
+		}
+		fmt.Println("expanded display is", s.Expanded)
+	case `\width`:
+		if len(args) != 1 {
+			fmt.Println(`usage: \width N (0 turns truncation off)`)
+			return true
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			fmt.Println("width has to be a number 0 or higher")
+			return true
+		}
+		s.MaxWidth = n
+		fmt.Println("max column width is", n)
+	case `\format`:
+		if len(args) == 0 {
+			fmt.Println("output format is", s.Format)
+			return true
+		}
+		if !IsFormat(args[0]) {
+			fmt.Printf("unknown format %q, use one of %s\n", args[0], strings.Join(Formats, "|"))
+			return true
+		}
+		s.Format = args[0]
+		fmt.Println("output format is", s.Format)
+	case `\o`:
+		s.Close()
+		if len(args) == 0 {
+			fmt.Println("results go to the terminal")
+			return true
+		}
+		name := strings.Join(args, " ")
+		f, err := os.Create(name)
+		if err != nil {
+			fmt.Println("couldn't open output file:", err)
+			return true
+		}
+		s.file = f
+		s.fileName = name
+		fmt.Println("results go to", name)
+	default:
+		return false
+	}
+	return true
+}
+
+// Help describes the commands Command understands
+func (s *Settings) Help() []string {
+	return []string{
+		`\format ` + strings.Join(Formats, "|") + ` - change the output format`,
+		`\o FILE - send results to a file, plain \o goes back to the terminal`,
+		`\x [on|off|auto] - expanded output, one field per line`,
+		`\width N - max column width in tables, 0 turns truncation off`,
+	}
+}
@@ -0,0 +1,224 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// how many rows we look at before deciding on column widths. anything after
+// this gets streamed out using the widths we already picked.
+const tableSampleRows = 1000
+
+// DefaultMaxWidth is the widest a table cell gets before it is cut off
+const DefaultMaxWidth = 40
+
+// ExpandedMode controls when results are printed one field per line like psql's \x
+type ExpandedMode int
+
+const (
+	ExpandedOff ExpandedMode = iota
+	ExpandedOn
+	ExpandedAuto
+)
+
+func (m ExpandedMode) String() string {
+	switch m {
+	case ExpandedOn:
+		return "on"
+	case ExpandedAuto:
+		return "auto"
+	}
+	return "off"
+}
+
+// width of the terminal. COLUMNS is set by most shells, otherwise 80.
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 80
+}
+
+// tableWriter prints rows as an aligned table. the first tableSampleRows rows
+// are held back so the column widths can be worked out, everything after
+// that is printed straight away using those widths.
+type tableWriter struct {
+	out      io.Writer
+	cols     []Column
+	maxWidth int
+	expanded ExpandedMode
+
+	widths   []int
+	pending  [][]string
+	started  bool
+	vertical bool
+	count    int
+}
+
+func (t *tableWriter) Begin(cols []Column) error {
+	t.cols = cols
+	t.widths = make([]int, len(cols))
+	for i, col := range cols {
+		t.widths[i] = t.cellWidth(col.Name)
+	}
+	return nil
+}
+
+// adds a row to the table, printing it once the widths are known
+func (t *tableWriter) Row(vals []Value) error {
+	cells := make([]string, len(vals))
+	for i, v := range vals {
+		if v.Kind == Null {
+			cells[i] = "NULL"
+		} else {
+			cells[i] = flattenCell(v.Text)
+		}
+	}
+
+	if t.started {
+		t.printRow(cells)
+		return nil
+	}
+
+	for i, cell := range cells {
+		if w := t.cellWidth(cell); w > t.widths[i] {
+			t.widths[i] = w
+		}
+	}
+	t.pending = append(t.pending, cells)
+	if len(t.pending) >= tableSampleRows {
+		t.start()
+	}
+	return nil
+}
+
+// prints whatever is still buffered and the closing rule
+func (t *tableWriter) End() error {
+	if !t.started {
+		t.start()
+	}
+	if !t.vertical {
+		fmt.Fprintln(t.out, t.rule("+"))
+	} else if t.count == 0 {
+		fmt.Fprintln(t.out, "(no rows)")
+	}
+	return nil
+}
+
+// picks the layout and prints the header along with the buffered rows
+func (t *tableWriter) start() {
+	t.started = true
+
+	switch t.expanded {
+	case ExpandedOn:
+		t.vertical = true
+	case ExpandedAuto:
+		t.vertical = len(t.rule("+")) > terminalWidth()
+	}
+
+	if !t.vertical {
+		fmt.Fprintln(t.out, t.rule("+"))
+		names := make([]string, len(t.cols))
+		for i, col := range t.cols {
+			// names get cut off at the same width as the cells
+			names[i] = t.pad(truncate(col.Name, t.widths[i]), i, false)
+		}
+		fmt.Fprintln(t.out, "| "+strings.Join(names, " | ")+" |")
+		fmt.Fprintln(t.out, t.rule("+"))
+	}
+
+	for _, cells := range t.pending {
+		t.printRow(cells)
+	}
+	t.pending = nil
+}
+
+func (t *tableWriter) printRow(cells []string) {
+	t.count++
+
+	if t.vertical {
+		t.printRecord(cells)
+		return
+	}
+
+	padded := make([]string, len(cells))
+	for i, cell := range cells {
+		padded[i] = t.pad(truncate(cell, t.widths[i]), i, t.cols[i].Numeric)
+	}
+	fmt.Fprintln(t.out, "| "+strings.Join(padded, " | ")+" |")
+}
+
+// expanded output, one line per column. values are never cut off here since
+// the whole point is to see the wide ones.
+func (t *tableWriter) printRecord(cells []string) {
+	nameWidth := 0
+	for _, col := range t.cols {
+		if n := utf8.RuneCountInString(col.Name); n > nameWidth {
+			nameWidth = n
+		}
+	}
+
+	header := fmt.Sprintf("-[ RECORD %d ]", t.count)
+	if n := nameWidth + 3 - utf8.RuneCountInString(header); n > 0 {
+		header += strings.Repeat("-", n)
+	}
+	fmt.Fprintln(t.out, header)
+
+	for i, cell := range cells {
+		name := t.cols[i].Name
+		fmt.Fprintf(t.out, "%s%s | %s\n", name, strings.Repeat(" ", nameWidth-utf8.RuneCountInString(name)), cell)
+	}
+}
+
+// a horizontal line like +------+-----+
+func (t *tableWriter) rule(joint string) string {
+	parts := make([]string, len(t.widths))
+	for i, w := range t.widths {
+		parts[i] = strings.Repeat("-", w+2)
+	}
+	return joint + strings.Join(parts, joint) + joint
+}
+
+// how wide a cell will end up once it's been truncated
+func (t *tableWriter) cellWidth(s string) int {
+	n := utf8.RuneCountInString(s)
+	if t.maxWidth > 0 && n > t.maxWidth {
+		return t.maxWidth
+	}
+	return n
+}
+
+func (t *tableWriter) pad(s string, i int, right bool) string {
+	gap := t.widths[i] - utf8.RuneCountInString(s)
+	if gap <= 0 {
+		return s
+	}
+	if right {
+		return strings.Repeat(" ", gap) + s
+	}
+	return s + strings.Repeat(" ", gap)
+}
+
+// cuts a cell down to the width and puts an ellipsis on the end
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	if width <= 1 {
+		return "…"
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
+
+// newlines and tabs would break the table so they become spaces
+func flattenCell(s string) string {
+	if !strings.ContainsAny(s, "\n\r\t") {
+		return s
+	}
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ").Replace(s)
+}
@@ -0,0 +1,55 @@
+package output
+
+import (
+	"fmt"
+	"io"
+)
+
+// PlanNode is one step of a query plan. both apps turn their explain output
+// into these so plans look the same whichever database they came from.
+type PlanNode struct {
+	Label    string
+	Details  []string
+	Warnings []string
+	Children []*PlanNode
+}
+
+// WriteTree prints a plan as a tree, warnings are marked with a "!" so full
+// scans and unused indexes stand out. it returns how many warnings there were.
+func WriteTree(w io.Writer, root *PlanNode) int {
+	fmt.Fprintln(w, root.Label)
+	count := writeNodeInfo(w, root, "")
+	for i, child := range root.Children {
+		count += writeNode(w, child, "", i == len(root.Children)-1)
+	}
+	return count
+}
+
+func writeNode(w io.Writer, n *PlanNode, prefix string, last bool) int {
+	branch, indent := "├─ ", "│  "
+	if last {
+		branch, indent = "└─ ", "   "
+	}
+	fmt.Fprintln(w, prefix+branch+n.Label)
+
+	count := writeNodeInfo(w, n, prefix+indent)
+	for i, child := range n.Children {
+		count += writeNode(w, child, prefix+indent, i == len(n.Children)-1)
+	}
+	return count
+}
+
+func writeNodeInfo(w io.Writer, n *PlanNode, prefix string) int {
+	// keeps the details lined up under the label when there are children below
+	bar := "   "
+	if len(n.Children) > 0 {
+		bar = "│  "
+	}
+	for _, d := range n.Details {
+		fmt.Fprintln(w, prefix+bar+d)
+	}
+	for _, warning := range n.Warnings {
+		fmt.Fprintln(w, prefix+bar+"! "+warning)
+	}
+	return len(n.Warnings)
+}
@@ -0,0 +1,5 @@
+// Package patronpb is the generated code for patrons.proto, the grpc api
+// the rpc package serves
+package patronpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative patrons.proto
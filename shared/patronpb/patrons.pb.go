@@ -0,0 +1,1395 @@
+// the grpc api for the data-sync jobs. Patron mirrors the patron documents
+// of the mongodb app, with the numbers as numbers. regenerate the go code
+// with go generate in this folder after changing it.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: patrons.proto
+
+package patronpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GroupBy int32
+
+const (
+	GroupBy_GROUP_BY_NONE              GroupBy = 0
+	GroupBy_GROUP_BY_AGE_RANGE         GroupBy = 1
+	GroupBy_GROUP_BY_HOME_LIBRARY      GroupBy = 2
+	GroupBy_GROUP_BY_PATRON_TYPE       GroupBy = 3
+	GroupBy_GROUP_BY_NOTIFICATION_TYPE GroupBy = 4
+	GroupBy_GROUP_BY_WITHIN_SFC        GroupBy = 5
+	GroupBy_GROUP_BY_ACTIVE_YEAR       GroupBy = 6
+)
+
+// Enum value maps for GroupBy.
+var (
+	GroupBy_name = map[int32]string{
+		0: "GROUP_BY_NONE",
+		1: "GROUP_BY_AGE_RANGE",
+		2: "GROUP_BY_HOME_LIBRARY",
+		3: "GROUP_BY_PATRON_TYPE",
+		4: "GROUP_BY_NOTIFICATION_TYPE",
+		5: "GROUP_BY_WITHIN_SFC",
+		6: "GROUP_BY_ACTIVE_YEAR",
+	}
+	GroupBy_value = map[string]int32{
+		"GROUP_BY_NONE":              0,
+		"GROUP_BY_AGE_RANGE":         1,
+		"GROUP_BY_HOME_LIBRARY":      2,
+		"GROUP_BY_PATRON_TYPE":       3,
+		"GROUP_BY_NOTIFICATION_TYPE": 4,
+		"GROUP_BY_WITHIN_SFC":        5,
+		"GROUP_BY_ACTIVE_YEAR":       6,
+	}
+)
+
+func (x GroupBy) Enum() *GroupBy {
+	p := new(GroupBy)
+	*p = x
+	return p
+}
+
+func (x GroupBy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (GroupBy) Descriptor() protoreflect.EnumDescriptor {
+	return file_patrons_proto_enumTypes[0].Descriptor()
+}
+
+func (GroupBy) Type() protoreflect.EnumType {
+	return &file_patrons_proto_enumTypes[0]
+}
+
+func (x GroupBy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use GroupBy.Descriptor instead.
+func (GroupBy) EnumDescriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{0}
+}
+
+// every field that's set has to match, a list matches any of its values
+type Filter struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	HomeLibraryCode []string               `protobuf:"bytes,1,rep,name=home_library_code,json=homeLibraryCode,proto3" json:"home_library_code,omitempty"`
+	AgeRange        []string               `protobuf:"bytes,2,rep,name=age_range,json=ageRange,proto3" json:"age_range,omitempty"`
+	PatronTypeCode  []string               `protobuf:"bytes,3,rep,name=patron_type_code,json=patronTypeCode,proto3" json:"patron_type_code,omitempty"`
+	WithinSfc       *bool                  `protobuf:"varint,4,opt,name=within_sfc,json=withinSfc,proto3,oneof" json:"within_sfc,omitempty"`
+	ActiveYear      []int64                `protobuf:"varint,5,rep,packed,name=active_year,json=activeYear,proto3" json:"active_year,omitempty"`
+	// at least this many checkouts
+	MinCheckouts  *int64 `protobuf:"varint,6,opt,name=min_checkouts,json=minCheckouts,proto3,oneof" json:"min_checkouts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Filter) Reset() {
+	*x = Filter{}
+	mi := &file_patrons_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Filter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Filter) ProtoMessage() {}
+
+func (x *Filter) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Filter.ProtoReflect.Descriptor instead.
+func (*Filter) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Filter) GetHomeLibraryCode() []string {
+	if x != nil {
+		return x.HomeLibraryCode
+	}
+	return nil
+}
+
+func (x *Filter) GetAgeRange() []string {
+	if x != nil {
+		return x.AgeRange
+	}
+	return nil
+}
+
+func (x *Filter) GetPatronTypeCode() []string {
+	if x != nil {
+		return x.PatronTypeCode
+	}
+	return nil
+}
+
+func (x *Filter) GetWithinSfc() bool {
+	if x != nil && x.WithinSfc != nil {
+		return *x.WithinSfc
+	}
+	return false
+}
+
+func (x *Filter) GetActiveYear() []int64 {
+	if x != nil {
+		return x.ActiveYear
+	}
+	return nil
+}
+
+func (x *Filter) GetMinCheckouts() int64 {
+	if x != nil && x.MinCheckouts != nil {
+		return *x.MinCheckouts
+	}
+	return 0
+}
+
+type StatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *Filter                `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	GroupBy       GroupBy                `protobuf:"varint,2,opt,name=group_by,json=groupBy,proto3,enum=sfils.patrons.v1.GroupBy" json:"group_by,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsRequest) Reset() {
+	*x = StatsRequest{}
+	mi := &file_patrons_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsRequest) ProtoMessage() {}
+
+func (x *StatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsRequest.ProtoReflect.Descriptor instead.
+func (*StatsRequest) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StatsRequest) GetFilter() *Filter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+func (x *StatsRequest) GetGroupBy() GroupBy {
+	if x != nil {
+		return x.GroupBy
+	}
+	return GroupBy_GROUP_BY_NONE
+}
+
+type Stats struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Count            int64                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	AvgCheckoutTotal *float64               `protobuf:"fixed64,2,opt,name=avg_checkout_total,json=avgCheckoutTotal,proto3,oneof" json:"avg_checkout_total,omitempty"`
+	AvgRenewalTotal  *float64               `protobuf:"fixed64,3,opt,name=avg_renewal_total,json=avgRenewalTotal,proto3,oneof" json:"avg_renewal_total,omitempty"`
+	// only there when group_by was set, sorted by key
+	Groups        []*Group `protobuf:"bytes,4,rep,name=groups,proto3" json:"groups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stats) Reset() {
+	*x = Stats{}
+	mi := &file_patrons_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stats) ProtoMessage() {}
+
+func (x *Stats) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stats.ProtoReflect.Descriptor instead.
+func (*Stats) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Stats) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Stats) GetAvgCheckoutTotal() float64 {
+	if x != nil && x.AvgCheckoutTotal != nil {
+		return *x.AvgCheckoutTotal
+	}
+	return 0
+}
+
+func (x *Stats) GetAvgRenewalTotal() float64 {
+	if x != nil && x.AvgRenewalTotal != nil {
+		return *x.AvgRenewalTotal
+	}
+	return 0
+}
+
+func (x *Stats) GetGroups() []*Group {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+type Group struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// the group's value as text, not set for patrons that don't have one
+	Key              *string  `protobuf:"bytes,1,opt,name=key,proto3,oneof" json:"key,omitempty"`
+	Count            int64    `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	AvgCheckoutTotal *float64 `protobuf:"fixed64,3,opt,name=avg_checkout_total,json=avgCheckoutTotal,proto3,oneof" json:"avg_checkout_total,omitempty"`
+	AvgRenewalTotal  *float64 `protobuf:"fixed64,4,opt,name=avg_renewal_total,json=avgRenewalTotal,proto3,oneof" json:"avg_renewal_total,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Group) Reset() {
+	*x = Group{}
+	mi := &file_patrons_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Group) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Group) ProtoMessage() {}
+
+func (x *Group) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Group.ProtoReflect.Descriptor instead.
+func (*Group) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Group) GetKey() string {
+	if x != nil && x.Key != nil {
+		return *x.Key
+	}
+	return ""
+}
+
+func (x *Group) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Group) GetAvgCheckoutTotal() float64 {
+	if x != nil && x.AvgCheckoutTotal != nil {
+		return *x.AvgCheckoutTotal
+	}
+	return 0
+}
+
+func (x *Group) GetAvgRenewalTotal() float64 {
+	if x != nil && x.AvgRenewalTotal != nil {
+		return *x.AvgRenewalTotal
+	}
+	return 0
+}
+
+type ListReportsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListReportsRequest) Reset() {
+	*x = ListReportsRequest{}
+	mi := &file_patrons_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListReportsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListReportsRequest) ProtoMessage() {}
+
+func (x *ListReportsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListReportsRequest.ProtoReflect.Descriptor instead.
+func (*ListReportsRequest) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{4}
+}
+
+type ReportList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reports       []*ReportInfo          `protobuf:"bytes,1,rep,name=reports,proto3" json:"reports,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportList) Reset() {
+	*x = ReportList{}
+	mi := &file_patrons_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportList) ProtoMessage() {}
+
+func (x *ReportList) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportList.ProtoReflect.Descriptor instead.
+func (*ReportList) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ReportList) GetReports() []*ReportInfo {
+	if x != nil {
+		return x.Reports
+	}
+	return nil
+}
+
+type ReportInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportInfo) Reset() {
+	*x = ReportInfo{}
+	mi := &file_patrons_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportInfo) ProtoMessage() {}
+
+func (x *ReportInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportInfo.ProtoReflect.Descriptor instead.
+func (*ReportInfo) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ReportInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ReportInfo) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+type ReportRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Filter        *Filter                `protobuf:"bytes,2,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportRequest) Reset() {
+	*x = ReportRequest{}
+	mi := &file_patrons_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportRequest) ProtoMessage() {}
+
+func (x *ReportRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportRequest.ProtoReflect.Descriptor instead.
+func (*ReportRequest) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ReportRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ReportRequest) GetFilter() *Filter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type Report struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Columns       []string               `protobuf:"bytes,3,rep,name=columns,proto3" json:"columns,omitempty"`
+	Rows          []*Row                 `protobuf:"bytes,4,rep,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Report) Reset() {
+	*x = Report{}
+	mi := &file_patrons_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Report) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Report) ProtoMessage() {}
+
+func (x *Report) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Report.ProtoReflect.Descriptor instead.
+func (*Report) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Report) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Report) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Report) GetColumns() []string {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *Report) GetRows() []*Row {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+type Row struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []*Value               `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Row) Reset() {
+	*x = Row{}
+	mi := &file_patrons_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Row) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Row) ProtoMessage() {}
+
+func (x *Row) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Row.ProtoReflect.Descriptor instead.
+func (*Row) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Row) GetValues() []*Value {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+// a cell of a report, none of them is set for null
+type Value struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*Value_Text
+	//	*Value_Number
+	//	*Value_Flag
+	Kind          isValue_Kind `protobuf_oneof:"kind"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Value) Reset() {
+	*x = Value{}
+	mi := &file_patrons_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Value) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Value) ProtoMessage() {}
+
+func (x *Value) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Value.ProtoReflect.Descriptor instead.
+func (*Value) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Value) GetKind() isValue_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *Value) GetText() string {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Text); ok {
+			return x.Text
+		}
+	}
+	return ""
+}
+
+func (x *Value) GetNumber() float64 {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Number); ok {
+			return x.Number
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetFlag() bool {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_Flag); ok {
+			return x.Flag
+		}
+	}
+	return false
+}
+
+type isValue_Kind interface {
+	isValue_Kind()
+}
+
+type Value_Text struct {
+	Text string `protobuf:"bytes,1,opt,name=text,proto3,oneof"`
+}
+
+type Value_Number struct {
+	Number float64 `protobuf:"fixed64,2,opt,name=number,proto3,oneof"`
+}
+
+type Value_Flag struct {
+	Flag bool `protobuf:"varint,3,opt,name=flag,proto3,oneof"`
+}
+
+func (*Value_Text) isValue_Kind() {}
+
+func (*Value_Number) isValue_Kind() {}
+
+func (*Value_Flag) isValue_Kind() {}
+
+type ExportRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Filter *Filter                `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	// the id of the last patron that came through, empty from the start
+	After         string `protobuf:"bytes,2,opt,name=after,proto3" json:"after,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportRequest) Reset() {
+	*x = ExportRequest{}
+	mi := &file_patrons_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportRequest) ProtoMessage() {}
+
+func (x *ExportRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportRequest.ProtoReflect.Descriptor instead.
+func (*ExportRequest) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ExportRequest) GetFilter() *Filter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+func (x *ExportRequest) GetAfter() string {
+	if x != nil {
+		return x.After
+	}
+	return ""
+}
+
+type Patron struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// a mysql id or mongodb object id, depending on the store
+	Id                   string  `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	PatronTypeCode       string  `protobuf:"bytes,2,opt,name=patron_type_code,json=patronTypeCode,proto3" json:"patron_type_code,omitempty"`
+	PatronTypeDesc       string  `protobuf:"bytes,3,opt,name=patron_type_desc,json=patronTypeDesc,proto3" json:"patron_type_desc,omitempty"`
+	CheckoutTotal        *int64  `protobuf:"varint,4,opt,name=checkout_total,json=checkoutTotal,proto3,oneof" json:"checkout_total,omitempty"`
+	RenewalTotal         *int64  `protobuf:"varint,5,opt,name=renewal_total,json=renewalTotal,proto3,oneof" json:"renewal_total,omitempty"`
+	AgeRange             string  `protobuf:"bytes,6,opt,name=age_range,json=ageRange,proto3" json:"age_range,omitempty"`
+	HomeLibraryCode      string  `protobuf:"bytes,7,opt,name=home_library_code,json=homeLibraryCode,proto3" json:"home_library_code,omitempty"`
+	HomeLibraryName      string  `protobuf:"bytes,8,opt,name=home_library_name,json=homeLibraryName,proto3" json:"home_library_name,omitempty"`
+	ActiveMonth          *int32  `protobuf:"varint,9,opt,name=active_month,json=activeMonth,proto3,oneof" json:"active_month,omitempty"`
+	ActiveYear           *int64  `protobuf:"varint,10,opt,name=active_year,json=activeYear,proto3,oneof" json:"active_year,omitempty"`
+	NotificationTypeCode string  `protobuf:"bytes,11,opt,name=notification_type_code,json=notificationTypeCode,proto3" json:"notification_type_code,omitempty"`
+	NotificationTypeDesc string  `protobuf:"bytes,12,opt,name=notification_type_desc,json=notificationTypeDesc,proto3" json:"notification_type_desc,omitempty"`
+	Email                *string `protobuf:"bytes,13,opt,name=email,proto3,oneof" json:"email,omitempty"`
+	WithinSfc            bool    `protobuf:"varint,14,opt,name=within_sfc,json=withinSfc,proto3" json:"within_sfc,omitempty"`
+	YearRegistered       *int64  `protobuf:"varint,15,opt,name=year_registered,json=yearRegistered,proto3,oneof" json:"year_registered,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *Patron) Reset() {
+	*x = Patron{}
+	mi := &file_patrons_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Patron) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Patron) ProtoMessage() {}
+
+func (x *Patron) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Patron.ProtoReflect.Descriptor instead.
+func (*Patron) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Patron) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Patron) GetPatronTypeCode() string {
+	if x != nil {
+		return x.PatronTypeCode
+	}
+	return ""
+}
+
+func (x *Patron) GetPatronTypeDesc() string {
+	if x != nil {
+		return x.PatronTypeDesc
+	}
+	return ""
+}
+
+func (x *Patron) GetCheckoutTotal() int64 {
+	if x != nil && x.CheckoutTotal != nil {
+		return *x.CheckoutTotal
+	}
+	return 0
+}
+
+func (x *Patron) GetRenewalTotal() int64 {
+	if x != nil && x.RenewalTotal != nil {
+		return *x.RenewalTotal
+	}
+	return 0
+}
+
+func (x *Patron) GetAgeRange() string {
+	if x != nil {
+		return x.AgeRange
+	}
+	return ""
+}
+
+func (x *Patron) GetHomeLibraryCode() string {
+	if x != nil {
+		return x.HomeLibraryCode
+	}
+	return ""
+}
+
+func (x *Patron) GetHomeLibraryName() string {
+	if x != nil {
+		return x.HomeLibraryName
+	}
+	return ""
+}
+
+func (x *Patron) GetActiveMonth() int32 {
+	if x != nil && x.ActiveMonth != nil {
+		return *x.ActiveMonth
+	}
+	return 0
+}
+
+func (x *Patron) GetActiveYear() int64 {
+	if x != nil && x.ActiveYear != nil {
+		return *x.ActiveYear
+	}
+	return 0
+}
+
+func (x *Patron) GetNotificationTypeCode() string {
+	if x != nil {
+		return x.NotificationTypeCode
+	}
+	return ""
+}
+
+func (x *Patron) GetNotificationTypeDesc() string {
+	if x != nil {
+		return x.NotificationTypeDesc
+	}
+	return ""
+}
+
+func (x *Patron) GetEmail() string {
+	if x != nil && x.Email != nil {
+		return *x.Email
+	}
+	return ""
+}
+
+func (x *Patron) GetWithinSfc() bool {
+	if x != nil {
+		return x.WithinSfc
+	}
+	return false
+}
+
+func (x *Patron) GetYearRegistered() int64 {
+	if x != nil && x.YearRegistered != nil {
+		return *x.YearRegistered
+	}
+	return 0
+}
+
+// one row of the workbook, the cells as text the way the sheet has them,
+// like a month name for active_month and TRUE or FALSE for within_sfc
+type ImportRow struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	PatronTypeCode       string                 `protobuf:"bytes,1,opt,name=patron_type_code,json=patronTypeCode,proto3" json:"patron_type_code,omitempty"`
+	PatronTypeDesc       string                 `protobuf:"bytes,2,opt,name=patron_type_desc,json=patronTypeDesc,proto3" json:"patron_type_desc,omitempty"`
+	CheckoutTotal        string                 `protobuf:"bytes,3,opt,name=checkout_total,json=checkoutTotal,proto3" json:"checkout_total,omitempty"`
+	RenewalTotal         string                 `protobuf:"bytes,4,opt,name=renewal_total,json=renewalTotal,proto3" json:"renewal_total,omitempty"`
+	AgeRange             string                 `protobuf:"bytes,5,opt,name=age_range,json=ageRange,proto3" json:"age_range,omitempty"`
+	HomeLibraryCode      string                 `protobuf:"bytes,6,opt,name=home_library_code,json=homeLibraryCode,proto3" json:"home_library_code,omitempty"`
+	HomeLibraryName      string                 `protobuf:"bytes,7,opt,name=home_library_name,json=homeLibraryName,proto3" json:"home_library_name,omitempty"`
+	ActiveMonth          string                 `protobuf:"bytes,8,opt,name=active_month,json=activeMonth,proto3" json:"active_month,omitempty"`
+	ActiveYear           string                 `protobuf:"bytes,9,opt,name=active_year,json=activeYear,proto3" json:"active_year,omitempty"`
+	NotificationTypeCode string                 `protobuf:"bytes,10,opt,name=notification_type_code,json=notificationTypeCode,proto3" json:"notification_type_code,omitempty"`
+	NotificationTypeDesc string                 `protobuf:"bytes,11,opt,name=notification_type_desc,json=notificationTypeDesc,proto3" json:"notification_type_desc,omitempty"`
+	Email                string                 `protobuf:"bytes,12,opt,name=email,proto3" json:"email,omitempty"`
+	WithinSfc            string                 `protobuf:"bytes,13,opt,name=within_sfc,json=withinSfc,proto3" json:"within_sfc,omitempty"`
+	YearRegistered       string                 `protobuf:"bytes,14,opt,name=year_registered,json=yearRegistered,proto3" json:"year_registered,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *ImportRow) Reset() {
+	*x = ImportRow{}
+	mi := &file_patrons_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportRow) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportRow) ProtoMessage() {}
+
+func (x *ImportRow) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportRow.ProtoReflect.Descriptor instead.
+func (*ImportRow) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ImportRow) GetPatronTypeCode() string {
+	if x != nil {
+		return x.PatronTypeCode
+	}
+	return ""
+}
+
+func (x *ImportRow) GetPatronTypeDesc() string {
+	if x != nil {
+		return x.PatronTypeDesc
+	}
+	return ""
+}
+
+func (x *ImportRow) GetCheckoutTotal() string {
+	if x != nil {
+		return x.CheckoutTotal
+	}
+	return ""
+}
+
+func (x *ImportRow) GetRenewalTotal() string {
+	if x != nil {
+		return x.RenewalTotal
+	}
+	return ""
+}
+
+func (x *ImportRow) GetAgeRange() string {
+	if x != nil {
+		return x.AgeRange
+	}
+	return ""
+}
+
+func (x *ImportRow) GetHomeLibraryCode() string {
+	if x != nil {
+		return x.HomeLibraryCode
+	}
+	return ""
+}
+
+func (x *ImportRow) GetHomeLibraryName() string {
+	if x != nil {
+		return x.HomeLibraryName
+	}
+	return ""
+}
+
+func (x *ImportRow) GetActiveMonth() string {
+	if x != nil {
+		return x.ActiveMonth
+	}
+	return ""
+}
+
+func (x *ImportRow) GetActiveYear() string {
+	if x != nil {
+		return x.ActiveYear
+	}
+	return ""
+}
+
+func (x *ImportRow) GetNotificationTypeCode() string {
+	if x != nil {
+		return x.NotificationTypeCode
+	}
+	return ""
+}
+
+func (x *ImportRow) GetNotificationTypeDesc() string {
+	if x != nil {
+		return x.NotificationTypeDesc
+	}
+	return ""
+}
+
+func (x *ImportRow) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *ImportRow) GetWithinSfc() string {
+	if x != nil {
+		return x.WithinSfc
+	}
+	return ""
+}
+
+func (x *ImportRow) GetYearRegistered() string {
+	if x != nil {
+		return x.YearRegistered
+	}
+	return ""
+}
+
+type ImportSummary struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// rows that came in
+	Received int64 `protobuf:"varint,1,opt,name=received,proto3" json:"received,omitempty"`
+	// patrons that were added
+	Imported int64 `protobuf:"varint,2,opt,name=imported,proto3" json:"imported,omitempty"`
+	// rows that weren't, see the server's log for why
+	Failed        int64 `protobuf:"varint,3,opt,name=failed,proto3" json:"failed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportSummary) Reset() {
+	*x = ImportSummary{}
+	mi := &file_patrons_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportSummary) ProtoMessage() {}
+
+func (x *ImportSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_patrons_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportSummary.ProtoReflect.Descriptor instead.
+func (*ImportSummary) Descriptor() ([]byte, []int) {
+	return file_patrons_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ImportSummary) GetReceived() int64 {
+	if x != nil {
+		return x.Received
+	}
+	return 0
+}
+
+func (x *ImportSummary) GetImported() int64 {
+	if x != nil {
+		return x.Imported
+	}
+	return 0
+}
+
+func (x *ImportSummary) GetFailed() int64 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+var File_patrons_proto protoreflect.FileDescriptor
+
+const file_patrons_proto_rawDesc = "" +
+	"\n" +
+	"\rpatrons.proto\x12\x10sfils.patrons.v1\"\x8b\x02\n" +
+	"\x06Filter\x12*\n" +
+	"\x11home_library_code\x18\x01 \x03(\tR\x0fhomeLibraryCode\x12\x1b\n" +
+	"\tage_range\x18\x02 \x03(\tR\bageRange\x12(\n" +
+	"\x10patron_type_code\x18\x03 \x03(\tR\x0epatronTypeCode\x12\"\n" +
+	"\n" +
+	"within_sfc\x18\x04 \x01(\bH\x00R\twithinSfc\x88\x01\x01\x12\x1f\n" +
+	"\vactive_year\x18\x05 \x03(\x03R\n" +
+	"activeYear\x12(\n" +
+	"\rmin_checkouts\x18\x06 \x01(\x03H\x01R\fminCheckouts\x88\x01\x01B\r\n" +
+	"\v_within_sfcB\x10\n" +
+	"\x0e_min_checkouts\"v\n" +
+	"\fStatsRequest\x120\n" +
+	"\x06filter\x18\x01 \x01(\v2\x18.sfils.patrons.v1.FilterR\x06filter\x124\n" +
+	"\bgroup_by\x18\x02 \x01(\x0e2\x19.sfils.patrons.v1.GroupByR\agroupBy\"\xdf\x01\n" +
+	"\x05Stats\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x03R\x05count\x121\n" +
+	"\x12avg_checkout_total\x18\x02 \x01(\x01H\x00R\x10avgCheckoutTotal\x88\x01\x01\x12/\n" +
+	"\x11avg_renewal_total\x18\x03 \x01(\x01H\x01R\x0favgRenewalTotal\x88\x01\x01\x12/\n" +
+	"\x06groups\x18\x04 \x03(\v2\x17.sfils.patrons.v1.GroupR\x06groupsB\x15\n" +
+	"\x13_avg_checkout_totalB\x14\n" +
+	"\x12_avg_renewal_total\"\xcd\x01\n" +
+	"\x05Group\x12\x15\n" +
+	"\x03key\x18\x01 \x01(\tH\x00R\x03key\x88\x01\x01\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x03R\x05count\x121\n" +
+	"\x12avg_checkout_total\x18\x03 \x01(\x01H\x01R\x10avgCheckoutTotal\x88\x01\x01\x12/\n" +
+	"\x11avg_renewal_total\x18\x04 \x01(\x01H\x02R\x0favgRenewalTotal\x88\x01\x01B\x06\n" +
+	"\x04_keyB\x15\n" +
+	"\x13_avg_checkout_totalB\x14\n" +
+	"\x12_avg_renewal_total\"\x14\n" +
+	"\x12ListReportsRequest\"D\n" +
+	"\n" +
+	"ReportList\x126\n" +
+	"\areports\x18\x01 \x03(\v2\x1c.sfils.patrons.v1.ReportInfoR\areports\"6\n" +
+	"\n" +
+	"ReportInfo\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\"U\n" +
+	"\rReportRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x120\n" +
+	"\x06filter\x18\x02 \x01(\v2\x18.sfils.patrons.v1.FilterR\x06filter\"w\n" +
+	"\x06Report\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x18\n" +
+	"\acolumns\x18\x03 \x03(\tR\acolumns\x12)\n" +
+	"\x04rows\x18\x04 \x03(\v2\x15.sfils.patrons.v1.RowR\x04rows\"6\n" +
+	"\x03Row\x12/\n" +
+	"\x06values\x18\x01 \x03(\v2\x17.sfils.patrons.v1.ValueR\x06values\"U\n" +
+	"\x05Value\x12\x14\n" +
+	"\x04text\x18\x01 \x01(\tH\x00R\x04text\x12\x18\n" +
+	"\x06number\x18\x02 \x01(\x01H\x00R\x06number\x12\x14\n" +
+	"\x04flag\x18\x03 \x01(\bH\x00R\x04flagB\x06\n" +
+	"\x04kind\"W\n" +
+	"\rExportRequest\x120\n" +
+	"\x06filter\x18\x01 \x01(\v2\x18.sfils.patrons.v1.FilterR\x06filter\x12\x14\n" +
+	"\x05after\x18\x02 \x01(\tR\x05after\"\xbd\x05\n" +
+	"\x06Patron\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12(\n" +
+	"\x10patron_type_code\x18\x02 \x01(\tR\x0epatronTypeCode\x12(\n" +
+	"\x10patron_type_desc\x18\x03 \x01(\tR\x0epatronTypeDesc\x12*\n" +
+	"\x0echeckout_total\x18\x04 \x01(\x03H\x00R\rcheckoutTotal\x88\x01\x01\x12(\n" +
+	"\rrenewal_total\x18\x05 \x01(\x03H\x01R\frenewalTotal\x88\x01\x01\x12\x1b\n" +
+	"\tage_range\x18\x06 \x01(\tR\bageRange\x12*\n" +
+	"\x11home_library_code\x18\a \x01(\tR\x0fhomeLibraryCode\x12*\n" +
+	"\x11home_library_name\x18\b \x01(\tR\x0fhomeLibraryName\x12&\n" +
+	"\factive_month\x18\t \x01(\x05H\x02R\vactiveMonth\x88\x01\x01\x12$\n" +
+	"\vactive_year\x18\n" +
+	" \x01(\x03H\x03R\n" +
+	"activeYear\x88\x01\x01\x124\n" +
+	"\x16notification_type_code\x18\v \x01(\tR\x14notificationTypeCode\x124\n" +
+	"\x16notification_type_desc\x18\f \x01(\tR\x14notificationTypeDesc\x12\x19\n" +
+	"\x05email\x18\r \x01(\tH\x04R\x05email\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"within_sfc\x18\x0e \x01(\bR\twithinSfc\x12,\n" +
+	"\x0fyear_registered\x18\x0f \x01(\x03H\x05R\x0eyearRegistered\x88\x01\x01B\x11\n" +
+	"\x0f_checkout_totalB\x10\n" +
+	"\x0e_renewal_totalB\x0f\n" +
+	"\r_active_monthB\x0e\n" +
+	"\f_active_yearB\b\n" +
+	"\x06_emailB\x12\n" +
+	"\x10_year_registered\"\xae\x04\n" +
+	"\tImportRow\x12(\n" +
+	"\x10patron_type_code\x18\x01 \x01(\tR\x0epatronTypeCode\x12(\n" +
+	"\x10patron_type_desc\x18\x02 \x01(\tR\x0epatronTypeDesc\x12%\n" +
+	"\x0echeckout_total\x18\x03 \x01(\tR\rcheckoutTotal\x12#\n" +
+	"\rrenewal_total\x18\x04 \x01(\tR\frenewalTotal\x12\x1b\n" +
+	"\tage_range\x18\x05 \x01(\tR\bageRange\x12*\n" +
+	"\x11home_library_code\x18\x06 \x01(\tR\x0fhomeLibraryCode\x12*\n" +
+	"\x11home_library_name\x18\a \x01(\tR\x0fhomeLibraryName\x12!\n" +
+	"\factive_month\x18\b \x01(\tR\vactiveMonth\x12\x1f\n" +
+	"\vactive_year\x18\t \x01(\tR\n" +
+	"activeYear\x124\n" +
+	"\x16notification_type_code\x18\n" +
+	" \x01(\tR\x14notificationTypeCode\x124\n" +
+	"\x16notification_type_desc\x18\v \x01(\tR\x14notificationTypeDesc\x12\x14\n" +
+	"\x05email\x18\f \x01(\tR\x05email\x12\x1d\n" +
+	"\n" +
+	"within_sfc\x18\r \x01(\tR\twithinSfc\x12'\n" +
+	"\x0fyear_registered\x18\x0e \x01(\tR\x0eyearRegistered\"_\n" +
+	"\rImportSummary\x12\x1a\n" +
+	"\breceived\x18\x01 \x01(\x03R\breceived\x12\x1a\n" +
+	"\bimported\x18\x02 \x01(\x03R\bimported\x12\x16\n" +
+	"\x06failed\x18\x03 \x01(\x03R\x06failed*\xbc\x01\n" +
+	"\aGroupBy\x12\x11\n" +
+	"\rGROUP_BY_NONE\x10\x00\x12\x16\n" +
+	"\x12GROUP_BY_AGE_RANGE\x10\x01\x12\x19\n" +
+	"\x15GROUP_BY_HOME_LIBRARY\x10\x02\x12\x18\n" +
+	"\x14GROUP_BY_PATRON_TYPE\x10\x03\x12\x1e\n" +
+	"\x1aGROUP_BY_NOTIFICATION_TYPE\x10\x04\x12\x17\n" +
+	"\x13GROUP_BY_WITHIN_SFC\x10\x05\x12\x18\n" +
+	"\x14GROUP_BY_ACTIVE_YEAR\x10\x062\x88\x03\n" +
+	"\aPatrons\x12C\n" +
+	"\bGetStats\x12\x1e.sfils.patrons.v1.StatsRequest\x1a\x17.sfils.patrons.v1.Stats\x12Q\n" +
+	"\vListReports\x12$.sfils.patrons.v1.ListReportsRequest\x1a\x1c.sfils.patrons.v1.ReportList\x12F\n" +
+	"\tRunReport\x12\x1f.sfils.patrons.v1.ReportRequest\x1a\x18.sfils.patrons.v1.Report\x12L\n" +
+	"\rExportPatrons\x12\x1f.sfils.patrons.v1.ExportRequest\x1a\x18.sfils.patrons.v1.Patron0\x01\x12O\n" +
+	"\rImportPatrons\x12\x1b.sfils.patrons.v1.ImportRow\x1a\x1f.sfils.patrons.v1.ImportSummary(\x01B0Z.github.com/jacksongodsey/SFILS/shared/patronpbb\x06proto3"
+
+var (
+	file_patrons_proto_rawDescOnce sync.Once
+	file_patrons_proto_rawDescData []byte
+)
+
+func file_patrons_proto_rawDescGZIP() []byte {
+	file_patrons_proto_rawDescOnce.Do(func() {
+		file_patrons_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_patrons_proto_rawDesc), len(file_patrons_proto_rawDesc)))
+	})
+	return file_patrons_proto_rawDescData
+}
+
+var file_patrons_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_patrons_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_patrons_proto_goTypes = []any{
+	(GroupBy)(0),               // 0: sfils.patrons.v1.GroupBy
+	(*Filter)(nil),             // 1: sfils.patrons.v1.Filter
+	(*StatsRequest)(nil),       // 2: sfils.patrons.v1.StatsRequest
+	(*Stats)(nil),              // 3: sfils.patrons.v1.Stats
+	(*Group)(nil),              // 4: sfils.patrons.v1.Group
+	(*ListReportsRequest)(nil), // 5: sfils.patrons.v1.ListReportsRequest
+	(*ReportList)(nil),         // 6: sfils.patrons.v1.ReportList
+	(*ReportInfo)(nil),         // 7: sfils.patrons.v1.ReportInfo
+	(*ReportRequest)(nil),      // 8: sfils.patrons.v1.ReportRequest
+	(*Report)(nil),             // 9: sfils.patrons.v1.Report
+	(*Row)(nil),                // 10: sfils.patrons.v1.Row
+	(*Value)(nil),              // 11: sfils.patrons.v1.Value
+	(*ExportRequest)(nil),      // 12: sfils.patrons.v1.ExportRequest
+	(*Patron)(nil),             // 13: sfils.patrons.v1.Patron
+	(*ImportRow)(nil),          // 14: sfils.patrons.v1.ImportRow
+	(*ImportSummary)(nil),      // 15: sfils.patrons.v1.ImportSummary
+}
+var file_patrons_proto_depIdxs = []int32{
+	1,  // 0: sfils.patrons.v1.StatsRequest.filter:type_name -> sfils.patrons.v1.Filter
+	0,  // 1: sfils.patrons.v1.StatsRequest.group_by:type_name -> sfils.patrons.v1.GroupBy
+	4,  // 2: sfils.patrons.v1.Stats.groups:type_name -> sfils.patrons.v1.Group
+	7,  // 3: sfils.patrons.v1.ReportList.reports:type_name -> sfils.patrons.v1.ReportInfo
+	1,  // 4: sfils.patrons.v1.ReportRequest.filter:type_name -> sfils.patrons.v1.Filter
+	10, // 5: sfils.patrons.v1.Report.rows:type_name -> sfils.patrons.v1.Row
+	11, // 6: sfils.patrons.v1.Row.values:type_name -> sfils.patrons.v1.Value
+	1,  // 7: sfils.patrons.v1.ExportRequest.filter:type_name -> sfils.patrons.v1.Filter
+	2,  // 8: sfils.patrons.v1.Patrons.GetStats:input_type -> sfils.patrons.v1.StatsRequest
+	5,  // 9: sfils.patrons.v1.Patrons.ListReports:input_type -> sfils.patrons.v1.ListReportsRequest
+	8,  // 10: sfils.patrons.v1.Patrons.RunReport:input_type -> sfils.patrons.v1.ReportRequest
+	12, // 11: sfils.patrons.v1.Patrons.ExportPatrons:input_type -> sfils.patrons.v1.ExportRequest
+	14, // 12: sfils.patrons.v1.Patrons.ImportPatrons:input_type -> sfils.patrons.v1.ImportRow
+	3,  // 13: sfils.patrons.v1.Patrons.GetStats:output_type -> sfils.patrons.v1.Stats
+	6,  // 14: sfils.patrons.v1.Patrons.ListReports:output_type -> sfils.patrons.v1.ReportList
+	9,  // 15: sfils.patrons.v1.Patrons.RunReport:output_type -> sfils.patrons.v1.Report
+	13, // 16: sfils.patrons.v1.Patrons.ExportPatrons:output_type -> sfils.patrons.v1.Patron
+	15, // 17: sfils.patrons.v1.Patrons.ImportPatrons:output_type -> sfils.patrons.v1.ImportSummary
+	13, // [13:18] is the sub-list for method output_type
+	8,  // [8:13] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_patrons_proto_init() }
+func file_patrons_proto_init() {
+	if File_patrons_proto != nil {
+		return
+	}
+	file_patrons_proto_msgTypes[0].OneofWrappers = []any{}
+	file_patrons_proto_msgTypes[2].OneofWrappers = []any{}
+	file_patrons_proto_msgTypes[3].OneofWrappers = []any{}
+	file_patrons_proto_msgTypes[10].OneofWrappers = []any{
+		(*Value_Text)(nil),
+		(*Value_Number)(nil),
+		(*Value_Flag)(nil),
+	}
+	file_patrons_proto_msgTypes[12].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_patrons_proto_rawDesc), len(file_patrons_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_patrons_proto_goTypes,
+		DependencyIndexes: file_patrons_proto_depIdxs,
+		EnumInfos:         file_patrons_proto_enumTypes,
+		MessageInfos:      file_patrons_proto_msgTypes,
+	}.Build()
+	File_patrons_proto = out.File
+	file_patrons_proto_goTypes = nil
+	file_patrons_proto_depIdxs = nil
+}
@@ -0,0 +1,290 @@
+// the grpc api for the data-sync jobs. Patron mirrors the patron documents
+// of the mongodb app, with the numbers as numbers. regenerate the go code
+// with go generate in this folder after changing it.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: patrons.proto
+
+package patronpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Patrons_GetStats_FullMethodName      = "/sfils.patrons.v1.Patrons/GetStats"
+	Patrons_ListReports_FullMethodName   = "/sfils.patrons.v1.Patrons/ListReports"
+	Patrons_RunReport_FullMethodName     = "/sfils.patrons.v1.Patrons/RunReport"
+	Patrons_ExportPatrons_FullMethodName = "/sfils.patrons.v1.Patrons/ExportPatrons"
+	Patrons_ImportPatrons_FullMethodName = "/sfils.patrons.v1.Patrons/ImportPatrons"
+)
+
+// PatronsClient is the client API for Patrons service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PatronsClient interface {
+	// counts and averages over the patrons that match the filter, in groups
+	// when group_by is set
+	GetStats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*Stats, error)
+	// the built-in reports
+	ListReports(ctx context.Context, in *ListReportsRequest, opts ...grpc.CallOption) (*ReportList, error)
+	RunReport(ctx context.Context, in *ReportRequest, opts ...grpc.CallOption) (*Report, error)
+	// every patron that matches the filter, in the order they were imported.
+	// after resumes an export that was cut off.
+	ExportPatrons(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Patron], error)
+	// adds patrons from rows as they come out of the workbook. they're
+	// cleaned up and checked the same way the import cleans the workbook, so
+	// a row the import would skip is skipped here too.
+	ImportPatrons(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportRow, ImportSummary], error)
+}
+
+type patronsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPatronsClient(cc grpc.ClientConnInterface) PatronsClient {
+	return &patronsClient{cc}
+}
+
+func (c *patronsClient) GetStats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*Stats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Stats)
+	err := c.cc.Invoke(ctx, Patrons_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *patronsClient) ListReports(ctx context.Context, in *ListReportsRequest, opts ...grpc.CallOption) (*ReportList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReportList)
+	err := c.cc.Invoke(ctx, Patrons_ListReports_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *patronsClient) RunReport(ctx context.Context, in *ReportRequest, opts ...grpc.CallOption) (*Report, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Report)
+	err := c.cc.Invoke(ctx, Patrons_RunReport_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *patronsClient) ExportPatrons(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Patron], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Patrons_ServiceDesc.Streams[0], Patrons_ExportPatrons_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportRequest, Patron]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Patrons_ExportPatronsClient = grpc.ServerStreamingClient[Patron]
+
+func (c *patronsClient) ImportPatrons(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportRow, ImportSummary], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Patrons_ServiceDesc.Streams[1], Patrons_ImportPatrons_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImportRow, ImportSummary]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Patrons_ImportPatronsClient = grpc.ClientStreamingClient[ImportRow, ImportSummary]
+
+// PatronsServer is the server API for Patrons service.
+// All implementations must embed UnimplementedPatronsServer
+// for forward compatibility.
+type PatronsServer interface {
+	// counts and averages over the patrons that match the filter, in groups
+	// when group_by is set
+	GetStats(context.Context, *StatsRequest) (*Stats, error)
+	// the built-in reports
+	ListReports(context.Context, *ListReportsRequest) (*ReportList, error)
+	RunReport(context.Context, *ReportRequest) (*Report, error)
+	// every patron that matches the filter, in the order they were imported.
+	// after resumes an export that was cut off.
+	ExportPatrons(*ExportRequest, grpc.ServerStreamingServer[Patron]) error
+	// adds patrons from rows as they come out of the workbook. they're
+	// cleaned up and checked the same way the import cleans the workbook, so
+	// a row the import would skip is skipped here too.
+	ImportPatrons(grpc.ClientStreamingServer[ImportRow, ImportSummary]) error
+	mustEmbedUnimplementedPatronsServer()
+}
+
+// UnimplementedPatronsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPatronsServer struct{}
+
+func (UnimplementedPatronsServer) GetStats(context.Context, *StatsRequest) (*Stats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedPatronsServer) ListReports(context.Context, *ListReportsRequest) (*ReportList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListReports not implemented")
+}
+func (UnimplementedPatronsServer) RunReport(context.Context, *ReportRequest) (*Report, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RunReport not implemented")
+}
+func (UnimplementedPatronsServer) ExportPatrons(*ExportRequest, grpc.ServerStreamingServer[Patron]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportPatrons not implemented")
+}
+func (UnimplementedPatronsServer) ImportPatrons(grpc.ClientStreamingServer[ImportRow, ImportSummary]) error {
+	return status.Errorf(codes.Unimplemented, "method ImportPatrons not implemented")
+}
+func (UnimplementedPatronsServer) mustEmbedUnimplementedPatronsServer() {}
+func (UnimplementedPatronsServer) testEmbeddedByValue()                 {}
+
+// UnsafePatronsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PatronsServer will
+// result in compilation errors.
+type UnsafePatronsServer interface {
+	mustEmbedUnimplementedPatronsServer()
+}
+
+func RegisterPatronsServer(s grpc.ServiceRegistrar, srv PatronsServer) {
+	// If the following call pancis, it indicates UnimplementedPatronsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Patrons_ServiceDesc, srv)
+}
+
+func _Patrons_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PatronsServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Patrons_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PatronsServer).GetStats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Patrons_ListReports_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListReportsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PatronsServer).ListReports(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Patrons_ListReports_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PatronsServer).ListReports(ctx, req.(*ListReportsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Patrons_RunReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PatronsServer).RunReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Patrons_RunReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PatronsServer).RunReport(ctx, req.(*ReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Patrons_ExportPatrons_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PatronsServer).ExportPatrons(m, &grpc.GenericServerStream[ExportRequest, Patron]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Patrons_ExportPatronsServer = grpc.ServerStreamingServer[Patron]
+
+func _Patrons_ImportPatrons_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PatronsServer).ImportPatrons(&grpc.GenericServerStream[ImportRow, ImportSummary]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Patrons_ImportPatronsServer = grpc.ClientStreamingServer[ImportRow, ImportSummary]
+
+// Patrons_ServiceDesc is the grpc.ServiceDesc for Patrons service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Patrons_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sfils.patrons.v1.Patrons",
+	HandlerType: (*PatronsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetStats",
+			Handler:    _Patrons_GetStats_Handler,
+		},
+		{
+			MethodName: "ListReports",
+			Handler:    _Patrons_ListReports_Handler,
+		},
+		{
+			MethodName: "RunReport",
+			Handler:    _Patrons_RunReport_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportPatrons",
+			Handler:       _Patrons_ExportPatrons_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportPatrons",
+			Handler:       _Patrons_ImportPatrons_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "patrons.proto",
+}
@@ -0,0 +1,261 @@
+// Package report has the built-in analyses of the patron data. each one is a
+// query in the patron query language, so the mysql and mongodb apps run the
+// same reports and only differ in how they fetch the rows.
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/metrics"
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// Formats are the formats a report can be written in
+var Formats = []string{"table", "json", "markdown"}
+
+// Report is one built-in analysis
+type Report struct {
+	Name  string
+	Title string
+	Query string
+}
+
+// Reports are the built-in reports in the order they're printed
+var Reports = []*Report{
+	{
+		Name:  "summary",
+		Title: "All patrons",
+		Query: "patrons count, avg checkout_total, avg renewal_total",
+	},
+	{
+		Name:  "libraries",
+		Title: "Patrons by home library",
+		Query: "patrons group by home_library count, avg checkout_total, avg renewal_total order by count desc",
+	},
+	{
+		Name:  "ages",
+		Title: "Age distribution",
+		Query: "patrons group by age_range count, avg checkout_total, avg renewal_total order by age_range",
+	},
+	{
+		Name:  "patron_types",
+		Title: "Patron type mix",
+		Query: "patrons group by patron_type count, avg checkout_total, avg renewal_total order by count desc",
+	},
+	{
+		Name:  "residency",
+		Title: "San Francisco residents and everyone else",
+		Query: "patrons group by within_sfc count, avg checkout_total, avg renewal_total order by within_sfc desc",
+	},
+	{
+		Name:  "notifications",
+		Title: "How patrons like to be contacted",
+		Query: "patrons group by notification count, avg checkout_total, avg renewal_total order by count desc",
+	},
+	{
+		Name:  "registrations",
+		Title: "Registrations per year",
+		Query: "patrons group by year_registered count, avg checkout_total, avg renewal_total order by year_registered",
+	},
+	{
+		Name:  "activity",
+		Title: "When patrons were last active, by year and month",
+		Query: "patrons group by active_year, active_month count, avg checkout_total, avg renewal_total order by active_year, active_month",
+	},
+}
+
+// Find looks a report up by name, nil if there isn't one
+func Find(name string) *Report {
+	for _, r := range Reports {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Names lists the report names in order
+func Names() []string {
+	names := make([]string, len(Reports))
+	for i, r := range Reports {
+		names[i] = r.Name
+	}
+	return names
+}
+
+// Select finds the named reports, or returns all of them when there are no names
+func Select(names []string) ([]*Report, error) {
+	if len(names) == 0 {
+		return Reports, nil
+	}
+	var reports []*Report
+	for _, name := range names {
+		r := Find(name)
+		if r == nil {
+			return nil, fmt.Errorf("no report called %q, use one of %s", name, strings.Join(Names(), ", "))
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+// IsFormat reports whether the name is one of Formats
+func IsFormat(name string) bool {
+	for _, f := range Formats {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Runner runs a query on one of the backends and returns its rows, with
+// the values in the same order as the query's columns
+type Runner func(q *dsl.Query) ([][]output.Value, error)
+
+// Result is a report that has been run
+type Result struct {
+	Report  *Report
+	Columns []output.Column
+	Rows    [][]output.Value
+}
+
+// Run runs a report and adds a share column next to the count, so the
+// split between groups can be read off without doing the sums
+func Run(r *Report, run Runner) (*Result, error) {
+	return RunWhere(r, nil, run)
+}
+
+// RunWhere runs a report on only the patrons that match where, nil for all of them
+func RunWhere(r *Report, where dsl.Expr, run Runner) (*Result, error) {
+	q, err := dsl.Parse(r.Query)
+	if err != nil {
+		return nil, fmt.Errorf("report %s: %v", r.Name, err)
+	}
+	if where != nil && q.Where != nil {
+		q.Where = &dsl.And{Left: q.Where, Right: where}
+	} else if where != nil {
+		q.Where = where
+	}
+	done := metrics.Query("report", r.Name)
+	rows, err := run(q)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{Report: r}
+	countCol := -1
+	for i, name := range q.Columns() {
+		numeric := i >= len(q.GroupBy) || q.GroupBy[i].Type == dsl.Int
+		res.Columns = append(res.Columns, output.Column{Name: name, Numeric: numeric})
+		if name == "count" {
+			countCol = i
+		}
+	}
+
+	// mysql hands booleans back as 0 and 1, mongodb as true and false
+	for i, f := range q.GroupBy {
+		if f.Type == dsl.Bool {
+			for _, row := range rows {
+				if row[i].Kind == output.Number {
+					row[i] = output.Value{Kind: output.Bool, Text: strconv.FormatBool(row[i].Text != "0")}
+				}
+			}
+		}
+	}
+
+	if countCol < 0 || len(q.GroupBy) == 0 {
+		res.Rows = rows
+		return res, nil
+	}
+	total := 0.0
+	for _, row := range rows {
+		n, _ := strconv.ParseFloat(row[countCol].Text, 64)
+		total += n
+	}
+	res.Columns = insertColumn(res.Columns, countCol+1, output.Column{Name: "percent", Numeric: true})
+	for _, row := range rows {
+		share := output.Value{Kind: output.Null}
+		if n, err := strconv.ParseFloat(row[countCol].Text, 64); err == nil && total > 0 {
+			share = output.Value{Kind: output.Number, Text: strconv.FormatFloat(n/total*100, 'f', 1, 64)}
+		}
+		res.Rows = append(res.Rows, insertValue(row, countCol+1, share))
+	}
+	return res, nil
+}
+
+func insertColumn(cols []output.Column, i int, col output.Column) []output.Column {
+	out := append([]output.Column{}, cols[:i]...)
+	return append(append(out, col), cols[i:]...)
+}
+
+func insertValue(row []output.Value, i int, v output.Value) []output.Value {
+	out := append([]output.Value{}, row[:i]...)
+	return append(append(out, v), row[i:]...)
+}
+
+// Write writes the results one after another. table and markdown put the
+// title above each one, json writes an array with an object per report.
+func Write(w io.Writer, format string, results []*Result) error {
+	if !IsFormat(format) {
+		return fmt.Errorf("unknown format %q, use one of %s", format, strings.Join(Formats, ", "))
+	}
+	if format == "json" {
+		return writeJSON(w, results)
+	}
+
+	for _, res := range results {
+		if format == "markdown" {
+			fmt.Fprintf(w, "## %s\n\n", res.Report.Title)
+		} else {
+			fmt.Fprintf(w, "%s\n%s\n", res.Report.Title, strings.Repeat("=", len(res.Report.Title)))
+		}
+		if err := writeRows(w, format, res); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, results []*Result) error {
+	fmt.Fprint(w, "[")
+	for i, res := range results {
+		var rows bytes.Buffer
+		if err := writeRows(&rows, "json", res); err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+		body := strings.ReplaceAll(strings.TrimSpace(rows.String()), "\n", "\n  ")
+		name, _ := json.Marshal(res.Report.Name)
+		title, _ := json.Marshal(res.Report.Title)
+		fmt.Fprintf(w, "\n  {\"report\": %s, \"title\": %s, \"rows\": %s}", name, title, body)
+	}
+	_, err := fmt.Fprintln(w, "\n]")
+	return err
+}
+
+func writeRows(w io.Writer, format string, res *Result) error {
+	out, err := output.New(format, w, output.Options{MaxWidth: output.DefaultMaxWidth})
+	if err != nil {
+		return err
+	}
+	if err := out.Begin(res.Columns); err != nil {
+		return err
+	}
+	for _, row := range res.Rows {
+		if err := out.Row(row); err != nil {
+			return err
+		}
+	}
+	return out.End()
+}
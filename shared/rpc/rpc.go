@@ -0,0 +1,351 @@
+// Package rpc serves the patron data over grpc for the data-sync jobs, the
+// service is in patronpb/patrons.proto. like the json api the stats and
+// reports are patron queries, so the apps only bring the export's listing
+// and the import.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"github.com/jacksongodsey/SFILS/shared/api"
+	"github.com/jacksongodsey/SFILS/shared/dsl"
+	"github.com/jacksongodsey/SFILS/shared/output"
+	"github.com/jacksongodsey/SFILS/shared/patronpb"
+	"github.com/jacksongodsey/SFILS/shared/report"
+)
+
+// Backend is how the grpc server reads and writes one of the stores
+type Backend struct {
+	// Query and Patrons, the same ones the json api uses
+	api.Backend
+
+	// Import cleans rows of the workbook, the 14 cells of each in the sheet's
+	// order, and adds them like the import does. it returns how many patrons
+	// went in and how many rows didn't.
+	Import func(rows [][]string) (imported, failed int, err error)
+}
+
+// the patrons ExportPatrons reads per query and the rows ImportPatrons
+// collects before it writes them
+const (
+	exportBatch = 1000
+	importBatch = 1000
+)
+
+// Command is the grpc command of both apps. it serves until ctrl-c or a
+// SIGTERM and then lets the calls that are running finish.
+//
+//	go run . grpc -addr :9090
+func Command(b Backend, args []string) error {
+	flags := flag.NewFlagSet("grpc", flag.ExitOnError)
+	addr := flags.String("addr", ":9090", "address to listen on")
+	flags.Parse(args)
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(logUnary), grpc.ChainStreamInterceptor(logStream))
+	patronpb.RegisterPatronsServer(srv, &server{backend: b})
+	// lets grpcurl and the like list the service without the .proto
+	reflection.Register(srv)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	failed := make(chan error, 1)
+	go func() {
+		fmt.Printf("serving the %s patron data over grpc on %s\n", b.Name, *addr)
+		failed <- srv.Serve(lis)
+	}()
+
+	select {
+	case err := <-failed:
+		return err
+	case <-ctx.Done():
+	}
+	fmt.Println("\nstopping, waiting for the calls that are running")
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		// a long export would hold the graceful stop up forever
+		srv.Stop()
+	}
+	return nil
+}
+
+type server struct {
+	patronpb.UnimplementedPatronsServer
+	backend Backend
+}
+
+// the status a failed query goes back with. the details stay in the
+// server's log, they can have table names and the like.
+func (s *server) failed(ctx context.Context, method string, err error) error {
+	switch {
+	case errors.Is(err, api.ErrBadCursor):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case ctx.Err() == context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, "the deadline passed before the query finished")
+	case ctx.Err() != nil:
+		return status.Error(codes.Canceled, "the call was cancelled")
+	}
+	fmt.Printf("%s: %v\n", method, err)
+	return status.Errorf(codes.Internal, "the %s query failed", s.backend.Name)
+}
+
+// the filter as a condition, nil when nothing in it is set. the json api
+// and graphql read theirs into the same api.Filter.
+func where(f *patronpb.Filter) dsl.Expr {
+	if f == nil {
+		return nil
+	}
+	return api.Filter{
+		Library:      f.HomeLibraryCode,
+		AgeRange:     f.AgeRange,
+		PatronType:   f.PatronTypeCode,
+		SF:           f.WithinSfc,
+		ActiveYear:   f.ActiveYear,
+		MinCheckouts: f.MinCheckouts,
+	}.Where()
+}
+
+// the field each grouping is by
+var groupFields = map[patronpb.GroupBy]string{
+	patronpb.GroupBy_GROUP_BY_AGE_RANGE:         "age_range",
+	patronpb.GroupBy_GROUP_BY_HOME_LIBRARY:      "home_library",
+	patronpb.GroupBy_GROUP_BY_PATRON_TYPE:       "patron_type",
+	patronpb.GroupBy_GROUP_BY_NOTIFICATION_TYPE: "notification",
+	patronpb.GroupBy_GROUP_BY_WITHIN_SFC:        "within_sfc",
+	patronpb.GroupBy_GROUP_BY_ACTIVE_YEAR:       "active_year",
+}
+
+// GetStats works out the totals, and the groups when they're asked for
+func (s *server) GetStats(ctx context.Context, req *patronpb.StatsRequest) (*patronpb.Stats, error) {
+	group, ok := groupFields[req.GroupBy]
+	if !ok && req.GroupBy != patronpb.GroupBy_GROUP_BY_NONE {
+		return nil, status.Errorf(codes.InvalidArgument, "can't group by %v", req.GroupBy)
+	}
+	cond := where(req.Filter)
+
+	totals, err := s.groups(ctx, cond, "")
+	if err != nil {
+		return nil, s.failed(ctx, "GetStats", err)
+	}
+	stats := &patronpb.Stats{}
+	if len(totals) > 0 {
+		stats.Count = totals[0].Count
+		stats.AvgCheckoutTotal = totals[0].AvgCheckoutTotal
+		stats.AvgRenewalTotal = totals[0].AvgRenewalTotal
+	}
+	if group != "" {
+		if stats.Groups, err = s.groups(ctx, cond, group); err != nil {
+			return nil, s.failed(ctx, "GetStats", err)
+		}
+	}
+	return stats, nil
+}
+
+// count and the averages by a field, or the one row for everything when
+// field is ""
+func (s *server) groups(ctx context.Context, cond dsl.Expr, field string) ([]*patronpb.Group, error) {
+	var fields []string
+	if field != "" {
+		fields = append(fields, field)
+	}
+	rows, err := api.Groups(ctx, s.backend.Query, cond, fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []*patronpb.Group
+	for _, row := range rows {
+		g := &patronpb.Group{Count: row.Count, AvgCheckoutTotal: row.AvgCheckoutTotal, AvgRenewalTotal: row.AvgRenewalTotal}
+		if field != "" {
+			g.Key = row.Keys[0]
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// ListReports lists the built-in reports
+func (s *server) ListReports(ctx context.Context, req *patronpb.ListReportsRequest) (*patronpb.ReportList, error) {
+	list := &patronpb.ReportList{}
+	for _, r := range report.Reports {
+		list.Reports = append(list.Reports, &patronpb.ReportInfo{Name: r.Name, Title: r.Title})
+	}
+	return list, nil
+}
+
+// RunReport runs one of the built-in reports on the filtered patrons
+func (s *server) RunReport(ctx context.Context, req *patronpb.ReportRequest) (*patronpb.Report, error) {
+	rep := report.Find(req.Name)
+	if rep == nil {
+		return nil, status.Errorf(codes.NotFound, "no report called %q, ListReports has them", req.Name)
+	}
+	res, err := report.RunWhere(rep, where(req.Filter), func(q *dsl.Query) ([][]output.Value, error) {
+		return s.backend.Query(ctx, q)
+	})
+	if err != nil {
+		return nil, s.failed(ctx, "RunReport", err)
+	}
+
+	out := &patronpb.Report{Name: rep.Name, Title: rep.Title}
+	for _, c := range res.Columns {
+		out.Columns = append(out.Columns, c.Name)
+	}
+	for _, row := range res.Rows {
+		r := &patronpb.Row{}
+		for _, v := range row {
+			r.Values = append(r.Values, value(v))
+		}
+		out.Rows = append(out.Rows, r)
+	}
+	return out, nil
+}
+
+// a cell as a report value, numbers stay numbers
+func value(v output.Value) *patronpb.Value {
+	switch v.Kind {
+	case output.Null:
+		return &patronpb.Value{}
+	case output.Number:
+		if f, err := strconv.ParseFloat(v.Text, 64); err == nil {
+			return &patronpb.Value{Kind: &patronpb.Value_Number{Number: f}}
+		}
+	case output.Bool:
+		return &patronpb.Value{Kind: &patronpb.Value_Flag{Flag: v.Text == "true"}}
+	}
+	return &patronpb.Value{Kind: &patronpb.Value_Text{Text: v.Text}}
+}
+
+// ExportPatrons sends the patrons a page at a time, so the export only
+// holds one page in memory however many patrons there are
+func (s *server) ExportPatrons(req *patronpb.ExportRequest, stream patronpb.Patrons_ExportPatronsServer) error {
+	ctx := stream.Context()
+	cond := where(req.Filter)
+	after := req.After
+	for {
+		patrons, err := s.backend.Patrons(ctx, cond, after, exportBatch)
+		if err != nil {
+			return s.failed(ctx, "ExportPatrons", err)
+		}
+		for _, p := range patrons {
+			if err := stream.Send(toProto(p)); err != nil {
+				return err
+			}
+		}
+		if len(patrons) < exportBatch {
+			return nil
+		}
+		after = patrons[len(patrons)-1].ID
+	}
+}
+
+func toProto(p api.Patron) *patronpb.Patron {
+	out := &patronpb.Patron{
+		Id:                   p.ID,
+		PatronTypeCode:       p.PatronTypeCode,
+		PatronTypeDesc:       p.PatronType,
+		CheckoutTotal:        p.CheckoutTotal,
+		RenewalTotal:         p.RenewalTotal,
+		AgeRange:             p.AgeRange,
+		HomeLibraryCode:      p.HomeLibraryCode,
+		HomeLibraryName:      p.HomeLibrary,
+		ActiveYear:           p.ActiveYear,
+		NotificationTypeCode: p.NotificationCode,
+		NotificationTypeDesc: p.Notification,
+		Email:                p.Email,
+		WithinSfc:            p.WithinSFC,
+		YearRegistered:       p.YearRegistered,
+	}
+	if p.ActiveMonth != nil {
+		month := int32(*p.ActiveMonth)
+		out.ActiveMonth = &month
+	}
+	return out
+}
+
+// ImportPatrons writes the rows a batch at a time as they come in
+func (s *server) ImportPatrons(stream patronpb.Patrons_ImportPatronsServer) error {
+	summary := &patronpb.ImportSummary{}
+	var rows [][]string
+	write := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		good, bad, err := s.backend.Import(rows)
+		if err != nil {
+			fmt.Printf("ImportPatrons: %v\n", err)
+			return status.Errorf(codes.Internal, "writing the patrons to %s failed after %d were imported", s.backend.Name, summary.Imported)
+		}
+		summary.Imported += int64(good)
+		summary.Failed += int64(bad)
+		rows = rows[:0]
+		return nil
+	}
+
+	for {
+		row, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		summary.Received++
+		// in the order of the workbook's columns
+		rows = append(rows, []string{
+			row.PatronTypeCode, row.PatronTypeDesc, row.CheckoutTotal, row.RenewalTotal,
+			row.AgeRange, row.HomeLibraryCode, row.HomeLibraryName, row.ActiveMonth,
+			row.ActiveYear, row.NotificationTypeCode, row.NotificationTypeDesc, row.Email,
+			row.WithinSfc, row.YearRegistered,
+		})
+		if len(rows) == importBatch {
+			if err := write(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	fmt.Printf("ImportPatrons: %d rows, %d imported, %d failed\n", summary.Received, summary.Imported, summary.Failed)
+	return stream.SendAndClose(summary)
+}
+
+// a line per call so it's clear what the jobs are doing, like the json api's
+func logUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	fmt.Printf("%s %v %v\n", info.FullMethod, status.Code(err), time.Since(start).Round(time.Millisecond))
+	return resp, err
+}
+
+func logStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	fmt.Printf("%s %v %v\n", info.FullMethod, status.Code(err), time.Since(start).Round(time.Millisecond))
+	return err
+}
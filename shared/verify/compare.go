@@ -0,0 +1,272 @@
+package verify
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/jacksongodsey/SFILS/shared/output"
+)
+
+// Difference is a value of a field the two stores have a different count of
+type Difference struct {
+	Field      string
+	Value      string
+	Base, Head int64
+}
+
+// Result is what Compare found
+type Result struct {
+	Base, Head  *Snapshot
+	Differences []Difference
+
+	// rows whose fingerprint is only in one of the snapshots, or in it more
+	// often than in the other
+	OnlyBase, OnlyHead int
+}
+
+// Same reports whether the stores hold the same data
+func (r *Result) Same() bool {
+	return len(r.Differences) == 0 && r.OnlyBase == 0 && r.OnlyHead == 0
+}
+
+// Compare lines up the aggregates and the row fingerprints of two snapshots
+func Compare(base, head *Snapshot) *Result {
+	res := &Result{Base: base, Head: head}
+	for _, field := range Fields {
+		values := map[string]bool{}
+		for v := range base.Aggregates[field] {
+			values[v] = true
+		}
+		for v := range head.Aggregates[field] {
+			values[v] = true
+		}
+		var sorted []string
+		for v := range values {
+			sorted = append(sorted, v)
+		}
+		sort.Strings(sorted)
+		for _, v := range sorted {
+			b, h := base.Aggregates[field][v], head.Aggregates[field][v]
+			if b != h {
+				res.Differences = append(res.Differences, Difference{Field: field, Value: v, Base: b, Head: h})
+			}
+		}
+	}
+
+	// both lists are sorted, so walking them together finds the rows that
+	// don't have a partner on the other side
+	i, j := 0, 0
+	for i < len(base.Fingerprints) || j < len(head.Fingerprints) {
+		switch {
+		case j == len(head.Fingerprints) || (i < len(base.Fingerprints) && base.Fingerprints[i] < head.Fingerprints[j]):
+			res.OnlyBase++
+			i++
+		case i == len(base.Fingerprints) || head.Fingerprints[j] < base.Fingerprints[i]:
+			res.OnlyHead++
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return res
+}
+
+// Write prints the row counts and every value the stores disagree on
+func (r *Result) Write(w io.Writer) error {
+	b, h := r.Base.Backend, r.Head.Backend
+	if r.Base.Dataset != r.Head.Dataset {
+		fmt.Fprintln(w, "warning: the snapshots were taken from different workbooks")
+	}
+	fmt.Fprintf(w, "%s has %d rows, %s has %d\n", b, r.Base.Rows, h, r.Head.Rows)
+	fmt.Fprintf(w, "%d rows are only in %s and %d only in %s\n", r.OnlyBase, b, r.OnlyHead, h)
+	if len(r.Differences) == 0 {
+		fmt.Fprintln(w, "every field has the same values on both sides")
+		return nil
+	}
+
+	fmt.Fprintf(w, "\n%d values differ:\n", len(r.Differences))
+	out, err := output.New("table", w, output.Options{MaxWidth: output.DefaultMaxWidth})
+	if err != nil {
+		return err
+	}
+	cols := []output.Column{{Name: "field"}, {Name: "value"}, {Name: b, Numeric: true}, {Name: h, Numeric: true}}
+	if b == h {
+		cols[3].Name = h + " (2)"
+	}
+	if err := out.Begin(cols); err != nil {
+		return err
+	}
+	for _, d := range r.Differences {
+		value := output.Value{Kind: output.String, Text: d.Value}
+		if d.Value == "" {
+			value = output.Value{Kind: output.Null}
+		}
+		row := []output.Value{
+			{Kind: output.String, Text: d.Field},
+			value,
+			{Kind: output.Number, Text: strconv.FormatInt(d.Base, 10)},
+			{Kind: output.Number, Text: strconv.FormatInt(d.Head, 10)},
+		}
+		if err := out.Row(row); err != nil {
+			return err
+		}
+	}
+	return out.End()
+}
+
+// Unmatched finds the rows of one store that another snapshot doesn't have
+type Unmatched struct {
+	counts map[string]int
+}
+
+// NewUnmatched remembers how often every row shows up in the other snapshot
+func NewUnmatched(other *Snapshot) *Unmatched {
+	u := &Unmatched{counts: map[string]int{}}
+	for _, f := range other.Fingerprints {
+		u.counts[f]++
+	}
+	return u
+}
+
+// Missing reports whether the row has no partner left in the other
+// snapshot. every call uses a partner up, so duplicates are matched one to one.
+func (u *Unmatched) Missing(row []string) bool {
+	row, err := Canonicalize(row)
+	if err != nil {
+		return true
+	}
+	f := RowFingerprint(row)
+	if u.counts[f] == 0 {
+		return true
+	}
+	u.counts[f]--
+	return false
+}
+
+// Command is the verify command of both apps. scan has to call add with
+// every patron in the store, the fields in the order of Fields. the snapshot
+// is saved to -out, and with -against it's compared with the other store's
+// snapshot right away and the rows the other store doesn't have are printed.
+func Command(backend, dataset, defaultOut string, args []string, scan func(add func(row []string) error) error) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	out := flags.String("out", defaultOut, "file the snapshot is saved to")
+	against := flags.String("against", "", "snapshot of the other store to compare with")
+	show := flags.Int("show", 10, "how many of the rows the other store doesn't have to print")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . verify [flags]")
+		fmt.Fprintln(flags.Output(), "       go run . verify compare BASE.json HEAD.json")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var other *Snapshot
+	var unmatched *Unmatched
+	if *against != "" {
+		var err error
+		if other, err = Load(*against); err != nil {
+			return err
+		}
+		unmatched = NewUnmatched(other)
+	}
+
+	snap := NewSnapshot(backend, dataset)
+	var extra [][]string
+	err := scan(func(row []string) error {
+		if err := snap.Add(row); err != nil {
+			return err
+		}
+		if unmatched != nil && unmatched.Missing(row) && len(extra) < *show {
+			extra = append(extra, append([]string(nil), row...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := snap.Save(*out); err != nil {
+		return fmt.Errorf("couldn't save the snapshot: %v", err)
+	}
+	fmt.Printf("%d rows read, snapshot saved to %s\n", snap.Rows, *out)
+	if other == nil {
+		return nil
+	}
+
+	fmt.Println()
+	res := Compare(other, snap)
+	if err := res.Write(os.Stdout); err != nil {
+		return err
+	}
+	if len(extra) > 0 {
+		fmt.Printf("\nrows in %s that %s doesn't have:\n", backend, other.Backend)
+		if err := writeRows(os.Stdout, extra); err != nil {
+			return err
+		}
+	}
+	if !res.Same() {
+		return fmt.Errorf("%s and %s don't hold the same data", other.Backend, backend)
+	}
+	return nil
+}
+
+// prints rows one field per line, they're too wide for a table
+func writeRows(w io.Writer, rows [][]string) error {
+	out, err := output.New("table", w, output.Options{MaxWidth: output.DefaultMaxWidth, Expanded: output.ExpandedOn})
+	if err != nil {
+		return err
+	}
+	cols := make([]output.Column, len(Fields))
+	for i, f := range Fields {
+		cols[i] = output.Column{Name: f}
+	}
+	if err := out.Begin(cols); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		values := make([]output.Value, len(row))
+		for i, v := range row {
+			values[i] = output.Value{Kind: output.String, Text: v}
+		}
+		if err := out.Row(values); err != nil {
+			return err
+		}
+	}
+	return out.End()
+}
+
+// CompareCommand is verify compare, the same in both apps. it doesn't need
+// a database and fails when the snapshots differ.
+//
+//	go run . verify compare ../results/verify-mysql.json ../results/verify-mongodb.json
+func CompareCommand(args []string) error {
+	flags := flag.NewFlagSet("verify compare", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: go run . verify compare BASE.json HEAD.json")
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	base, err := Load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	head, err := Load(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	res := Compare(base, head)
+	if err := res.Write(os.Stdout); err != nil {
+		return err
+	}
+	if !res.Same() {
+		return fmt.Errorf("%s and %s don't hold the same data", base.Backend, head.Backend)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Package verify checks that the mysql and mongodb imports hold the same
+// data. each app reads every patron back out of its store, puts the fields in
+// one shape and saves a snapshot: counts of the values of every field, sums
+// of the totals and a fingerprint of every row. two snapshots can then be
+// compared without either database.
+package verify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields are the fields of a patron in the order the apps hand them over.
+// the descriptions and names come from the lookup tables in mysql and are
+// embedded in every document in mongodb.
+var Fields = []string{
+	"patron_type_code", "patron_type_desc", "checkout_total", "renewal_total",
+	"age_range", "home_library_code", "home_library_name", "active_month",
+	"active_year", "notification_type_code", "notification_type_desc",
+	"email", "within_sfc", "year_registered",
+}
+
+// how each field is summed up. the totals have too many values to count
+// them one by one and emails are only counted as there or not.
+var summaries = map[string]string{
+	"checkout_total": "sum",
+	"renewal_total":  "sum",
+	"email":          "present",
+}
+
+// Snapshot is what one store holds
+type Snapshot struct {
+	Backend   string    `json:"backend"`
+	Dataset   string    `json:"dataset"` // sha256 of the workbook that was imported
+	Timestamp time.Time `json:"timestamp"`
+	Rows      int       `json:"rows"`
+
+	// Aggregates has the count of every value of a field, "present" and
+	// "missing" for the email and "sum" and "not numbers" for the totals
+	Aggregates map[string]map[string]int64 `json:"aggregates"`
+
+	// Fingerprints has one hash per row, sorted
+	Fingerprints []string `json:"fingerprints"`
+}
+
+// NewSnapshot starts an empty snapshot, rows get added with Add
+func NewSnapshot(backend, dataset string) *Snapshot {
+	s := &Snapshot{
+		Backend:    backend,
+		Dataset:    dataset,
+		Timestamp:  time.Now().UTC(),
+		Aggregates: map[string]map[string]int64{},
+	}
+	for _, f := range Fields {
+		s.Aggregates[f] = map[string]int64{}
+	}
+	return s
+}
+
+// Canonical is how a value looks when the stores are compared. one side
+// keeps numbers as strings and the other as ints, and within_sfc is a bool
+// in mongodb and 0 or 1 in mysql, so those are made the same. NULL and a
+// missing field are both "".
+func Canonical(field, value string) string {
+	value = strings.TrimSpace(value)
+	if field == "within_sfc" {
+		switch strings.ToLower(value) {
+		case "true":
+			return "1"
+		case "false":
+			return "0"
+		}
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return strconv.FormatInt(n, 10)
+	}
+	return value
+}
+
+// RowFingerprint is the hash of a row after Canonical
+func RowFingerprint(row []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(row, "\x1f")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Canonicalize puts every value of the row through Canonical
+func Canonicalize(row []string) ([]string, error) {
+	if len(row) != len(Fields) {
+		return nil, fmt.Errorf("a row has %d fields, expected %d", len(row), len(Fields))
+	}
+	out := make([]string, len(row))
+	for i, v := range row {
+		out[i] = Canonical(Fields[i], v)
+	}
+	return out, nil
+}
+
+// Add counts a row, which has the values in the order of Fields
+func (s *Snapshot) Add(row []string) error {
+	row, err := Canonicalize(row)
+	if err != nil {
+		return err
+	}
+	s.Rows++
+	for i, v := range row {
+		counts := s.Aggregates[Fields[i]]
+		switch summaries[Fields[i]] {
+		case "sum":
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				counts["sum"] += n
+			} else {
+				counts["not numbers"]++
+			}
+		case "present":
+			if v == "" {
+				counts["missing"]++
+			} else {
+				counts["present"]++
+			}
+		default:
+			counts[v]++
+		}
+	}
+	s.Fingerprints = append(s.Fingerprints, RowFingerprint(row))
+	return nil
+}
+
+// Save sorts the fingerprints and writes the snapshot as json
+func (s *Snapshot) Save(path string) error {
+	sort.Strings(s.Fingerprints)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Load reads a saved snapshot
+func Load(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s isn't a saved verify snapshot: %v", path, err)
+	}
+	sort.Strings(s.Fingerprints)
+	return &s, nil
+}